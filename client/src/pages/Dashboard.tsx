@@ -36,6 +36,24 @@ const Dashboard = () => {
     }
 
     fetchTasks()
+
+    // Keep the list in sync with changes made in other tabs and devices
+    return api.subscribeToTasks(
+      token,
+      (event) => {
+        if (event.type === 'task.deleted') {
+          setTasks((prev) => prev.filter((t) => t.id !== event.data.id))
+          return
+        }
+        const task = event.data
+        setTasks((prev) =>
+          prev.some((t) => t.id === task.id)
+            ? prev.map((t) => (t.id === task.id ? task : t))
+            : [task, ...prev],
+        )
+      },
+      fetchTasks,
+    )
   }, [token])
 
   const stats = useMemo(() => {
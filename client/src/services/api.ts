@@ -8,10 +8,11 @@ import type {
   Task,
   CreateTaskRequest,
   UpdateTaskRequest,
+  TaskEvent,
 } from '../types/task'
 
 const API_BASE_URL =
-  import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080/api'
+  import.meta.env.VITE_API_BASE_URL || 'http://localhost:8080/api/v1'
 
 async function request<TResponse>(
   path: string,
@@ -25,15 +26,20 @@ async function request<TResponse>(
     ...options,
   })
 
+  // Errors are sent as application/problem+json (RFC 7807)
+  const contentType = response.headers.get('content-type') ?? ''
   const isJson =
-    response.headers.get('content-type')?.includes('application/json')
+    contentType.includes('application/json') ||
+    contentType.includes('application/problem+json')
 
   if (!response.ok) {
     let errorMessage = 'Request failed'
     if (isJson) {
       const data = await response.json().catch(() => null)
-      if (data && typeof data.message === 'string') {
-        errorMessage = data.message
+      if (data && typeof data.detail === 'string') {
+        errorMessage = data.detail
+      } else if (data && typeof data.title === 'string') {
+        errorMessage = data.title
       }
     }
     throw new Error(errorMessage)
@@ -103,5 +109,25 @@ export const api = {
       },
     })
   },
+  // Streams the user's task changes. EventSource cannot send headers, so the
+  // token goes in the query string. It reconnects on its own and resumes
+  // from the last event; onReset means events were missed and the tasks
+  // should be refetched. Returns a function that closes the stream.
+  subscribeToTasks(
+    token: string,
+    onEvent: (event: TaskEvent) => void,
+    onReset: () => void,
+  ): () => void {
+    const source = new EventSource(
+      `${API_BASE_URL}/events?access_token=${encodeURIComponent(token)}`,
+    )
+    const handle = (message: MessageEvent<string>) => {
+      onEvent(JSON.parse(message.data) as TaskEvent)
+    }
+    source.addEventListener('task.created', handle)
+    source.addEventListener('task.updated', handle)
+    source.addEventListener('task.deleted', handle)
+    source.addEventListener('reset', onReset)
+    return () => source.close()
+  },
 }
-
@@ -4,6 +4,8 @@ export type Task = {
   description?: string
   done: boolean
   userId: number
+  parentId?: number | null
+  version: number
   createdAt: string
 }
 
@@ -17,3 +19,7 @@ export type UpdateTaskRequest = {
   description?: string
   done?: boolean
 }
+
+export type TaskEvent =
+  | { id: string; type: 'task.created' | 'task.updated'; data: Task; time: string }
+  | { id: string; type: 'task.deleted'; data: { id: number; version: number }; time: string }
@@ -1,41 +1,141 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"time"
 
+	"task-manager-server/internal/collab"
 	"task-manager-server/internal/config"
+	"task-manager-server/internal/graph"
 	"task-manager-server/internal/handlers"
-	"task-manager-server/internal/middleware"
+	"task-manager-server/internal/metrics"
+	"task-manager-server/internal/repository"
 	"task-manager-server/internal/routes"
+	"task-manager-server/internal/rpc"
 	"task-manager-server/internal/services"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 func main() {
+	config.LoadEnv()
+	slog.SetDefault(config.NewLogger())
+
+	if err := run(); err != nil {
+		slog.Error("server stopped", "err", err)
+		os.Exit(1)
+	}
+}
+
+// run serves until a server fails. It returns instead of exiting, so the
+// deferred cleanup, such as flushing the traces, runs on the way out.
+func run() error {
+	// Traces continue the W3C trace context of incoming requests.
+	tracerProvider, shutdownTracing, err := config.NewTracerProvider()
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "err", err)
+		}
+	}()
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
 	db, err := config.NewDB()
 	if err != nil {
-		log.Fatalf("failed to connect DB: %v", err)
+		return fmt.Errorf("failed to connect DB: %w", err)
 	}
 	defer db.Close()
 
-	authService := services.NewAuthService(db)
-	taskService := services.NewTaskService(db)
+	txConfig, err := config.NewTxConfig()
+	if err != nil {
+		return fmt.Errorf("invalid transaction settings: %w", err)
+	}
+	uow := repository.NewUnitOfWork(db, txConfig)
+
+	authService := services.NewAuthService(db, uow, config.NewMailer(), config.NewLockoutStore(db), config.NewAuthConfig())
+	broker, err := config.NewEventBroker()
+	if err != nil {
+		return fmt.Errorf("invalid event settings: %w", err)
+	}
+	taskService := services.NewTaskService(db, uow, broker, config.NewUndoConfig())
+	go taskService.RunTombstonePurge(config.NewSyncConfig().TombstoneRetention, time.Hour)
 
-	authHandler := handlers.NewAuthHandler(authService, taskService)
+	authHandler := handlers.NewAuthHandler(authService)
 	taskHandler := handlers.NewTaskHandler(taskService)
+	eventsHandler := handlers.NewEventsHandler(broker)
+
+	hub := collab.NewMemoryHub()
+	go collab.Relay(broker, hub)
+	projectService := services.NewProjectService(db, uow)
+	collabHandler := handlers.NewCollabHandler(hub, taskService, projectService)
+	projectHandler := handlers.NewProjectHandler(projectService, taskService, collabHandler)
+	syncHandler := handlers.NewSyncHandler(taskService)
+
+	webhookService := services.NewWebhookService(db, uow, config.NewWebhookConfig())
+	go webhookService.Relay(broker)
+	go webhookService.RunDeliveries()
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	auditHandler := handlers.NewAuditHandler(services.NewAuditService(db))
+
+	schema, err := graph.NewSchema(taskService, authService, projectService, broker, config.NewGraphQLConfig())
+	if err != nil {
+		return fmt.Errorf("failed to build GraphQL schema: %w", err)
+	}
+	graphqlHandler := handlers.NewGraphQLHandler(schema)
 
 	// Setup routes
-	router := routes.SetupRoutes(authHandler, taskHandler)
+	router := routes.SetupRoutes(authHandler, taskHandler, eventsHandler, collabHandler, syncHandler, webhookHandler, projectHandler, auditHandler, graphqlHandler, config.NewIdempotencyStore(db), config.NewAPIConfig())
 
-	// Apply CORS middleware
-	finalHandler := middleware.CORSMiddleware(router)
+	// The gRPC API shares the services with the HTTP server on its own port.
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
+	grpcServer := rpc.NewServer(taskService, authService, broker)
+	lis, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC: %w", err)
+	}
+
+	// The first server to stop ends the process.
+	stopped := make(chan error, 3)
+	go func() {
+		slog.Info("Starting gRPC server", "port", grpcPort)
+		stopped <- fmt.Errorf("gRPC server stopped: %w", grpcServer.Serve(lis))
+	}()
+
+	// Prometheus scrapes the metrics from a listener of their own, which
+	// only the internal network should reach.
+	metricsAddr := os.Getenv("METRICS_ADDR")
+	if metricsAddr == "" {
+		metricsAddr = "localhost:9464"
+	}
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("GET /metrics", metrics.Handler(db))
+	go func() {
+		slog.Info("Starting metrics server", "addr", metricsAddr)
+		stopped <- fmt.Errorf("metrics server stopped: %w", http.ListenAndServe(metricsAddr, metricsMux))
+	}()
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	log.Printf("Starting server on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, finalHandler))
+	go func() {
+		slog.Info("Starting server", "port", port)
+		stopped <- fmt.Errorf("HTTP server stopped: %w", http.ListenAndServe(":"+port, router))
+	}()
+	return <-stopped
 }
@@ -0,0 +1,70 @@
+// Package audit carries who a request comes from down to the services,
+// which record every change they make in the audit log, and computes the
+// field changes kept with each entry.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+
+	"task-manager-server/internal/models"
+)
+
+// Client identifies where a request came from.
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type contextKey struct{}
+
+// WithClient returns a copy of ctx carrying c.
+func WithClient(ctx context.Context, c Client) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// ClientFrom returns the client of a request; zero for work the server
+// does on its own.
+func ClientFrom(ctx context.Context) Client {
+	c, _ := ctx.Value(contextKey{}).(Client)
+	return c
+}
+
+// Diff returns the fields that differ between the JSON forms of before and
+// after, either of which may be nil for a creation or a removal. Fields in
+// ignore, such as bookkeeping timestamps, are left out.
+func Diff(before, after any, ignore ...string) (map[string]models.AuditChange, error) {
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]models.AuditChange{}
+	names := slices.Concat(slices.Collect(maps.Keys(from)), slices.Collect(maps.Keys(to)))
+	for _, name := range names {
+		if slices.Contains(ignore, name) || reflect.DeepEqual(from[name], to[name]) {
+			continue
+		}
+		changes[name] = models.AuditChange{From: from[name], To: to[name]}
+	}
+	return changes, nil
+}
+
+func fields(v any) (map[string]any, error) {
+	m := map[string]any{}
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return m, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return m, json.Unmarshal(data, &m)
+}
@@ -0,0 +1,165 @@
+package collab
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait bounds a single write to the client.
+	writeWait = 10 * time.Second
+	// pongWait is how long a client may stay silent before it is dropped.
+	pongWait = 60 * time.Second
+	// pingPeriod must be shorter than pongWait.
+	pingPeriod = 50 * time.Second
+	// maxMessageSize leaves room for a task with a full-size description.
+	maxMessageSize = 256 << 10
+	// sendBuffer is how many messages a client may fall behind. Past it,
+	// typing indicators are dropped and anything else disconnects the
+	// client, which reconnects and refetches.
+	sendBuffer = 64
+)
+
+// Conn is one client connection.
+type Conn struct {
+	id     string
+	userID int
+	since  time.Time
+
+	ws   *websocket.Conn
+	send chan []byte
+
+	closeOnce   sync.Once
+	done        chan struct{}
+	closeCode   int
+	closeReason string
+}
+
+func NewConn(ws *websocket.Conn, userID int) *Conn {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+
+	return &Conn{
+		id:     hex.EncodeToString(buf),
+		userID: userID,
+		since:  time.Now().UTC(),
+		ws:     ws,
+		send:   make(chan []byte, sendBuffer),
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *Conn) UserID() int {
+	return c.userID
+}
+
+// Member describes c in presence lists.
+func (c *Conn) Member() Member {
+	return Member{ConnectionID: c.id, UserID: c.userID, Since: c.since}
+}
+
+// Send queues m without blocking. A client that falls behind loses its
+// typing indicators; for any other message it is disconnected, since it
+// would otherwise silently miss state.
+func (c *Conn) Send(m Message) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		slog.Error("Collab: encoding message", "type", m.Type, "err", err)
+		return
+	}
+
+	select {
+	case <-c.done:
+	case c.send <- data:
+	default:
+		if m.Type != TypeTyping {
+			c.Close(websocket.CloseTryAgainLater, "client too slow")
+		}
+	}
+}
+
+// Close ends the connection with a close frame. It is safe to call more
+// than once; the first code wins.
+func (c *Conn) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.done)
+	})
+}
+
+// Run serves the connection until it is closed, passing every request to
+// handle. Requests are handled one at a time, in order.
+func (c *Conn) Run(handle func(Request)) {
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		c.writePump()
+	}()
+
+	c.readPump(handle)
+	c.Close(websocket.CloseNormalClosure, "")
+	<-written
+}
+
+func (c *Conn) readPump(handle func(Request)) {
+	c.ws.SetReadLimit(maxMessageSize)
+	_ = c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				slog.Warn("Collab: connection closed unexpectedly", "conn", c.id, "err", err)
+			}
+			return
+		}
+		_ = c.ws.SetReadDeadline(time.Now().Add(pongWait))
+
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil || req.Type == "" {
+			c.Send(errorMessage("", "invalid-message", "Messages must be JSON objects with a type"))
+			continue
+		}
+		handle(req)
+	}
+}
+
+func (c *Conn) writePump() {
+	ping := time.NewTicker(pingPeriod)
+	defer func() {
+		ping.Stop()
+		c.ws.Close()
+	}()
+
+	for {
+		select {
+		case data := <-c.send:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.Close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ping.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.Close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.done:
+			if c.closeCode != websocket.CloseAbnormalClosure {
+				msg := websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+				_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+			}
+			return
+		}
+	}
+}
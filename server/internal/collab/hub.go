@@ -0,0 +1,157 @@
+// Package collab runs the WebSocket collaboration channel: connections join
+// topics, see who else is present, exchange typing indicators and receive
+// task changes as they happen.
+package collab
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Member is one connection present on a topic.
+type Member struct {
+	ConnectionID string    `json:"connectionId"`
+	UserID       int       `json:"userId"`
+	Since        time.Time `json:"since"`
+}
+
+// Hub fans messages out to the connections that joined a topic. MemoryHub
+// serves a single instance; an implementation backed by a message broker can
+// replace it to span several instances.
+type Hub interface {
+	// Join adds c to topic and returns the members present afterwards.
+	Join(topic string, c *Conn) []Member
+	// Leave removes c from topic and returns the members left.
+	Leave(topic string, c *Conn) []Member
+	// Topics lists the topics c joined.
+	Topics(c *Conn) []string
+	// Members lists the connections on topic.
+	Members(topic string) []Member
+	// Evict removes the connections of a user from topic, sends them m and
+	// returns the members left.
+	Evict(topic string, userID int, m Message) []Member
+	// Publish sends m to every connection on topic except skip, which may
+	// be nil.
+	Publish(topic string, m Message, skip *Conn)
+}
+
+// Topic returns the hub topic for a name a user subscribed to. "tasks", the
+// user's own task list, is scoped per user. The topics of a task
+// ("task:<id>") and of a project ("project:<id>") are shared by everyone
+// who may see them, so collaborators meet there.
+func Topic(userID int, name string) string {
+	if name == "tasks" {
+		return "user:" + strconv.Itoa(userID) + "/" + name
+	}
+	return name
+}
+
+// TopicName returns the name a client subscribed to for a hub topic.
+func TopicName(topic string) string {
+	if scoped, ok := strings.CutPrefix(topic, "user:"); ok {
+		_, name, _ := strings.Cut(scoped, "/")
+		return name
+	}
+	return topic
+}
+
+// MemoryHub keeps topics in process memory.
+type MemoryHub struct {
+	mu     sync.RWMutex
+	topics map[string]map[*Conn]struct{}
+	joined map[*Conn][]string
+}
+
+func NewMemoryHub() *MemoryHub {
+	return &MemoryHub{
+		topics: make(map[string]map[*Conn]struct{}),
+		joined: make(map[*Conn][]string),
+	}
+}
+
+func (h *MemoryHub) Join(topic string, c *Conn) []Member {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	conns, ok := h.topics[topic]
+	if !ok {
+		conns = make(map[*Conn]struct{})
+		h.topics[topic] = conns
+	}
+	if _, ok := conns[c]; !ok {
+		conns[c] = struct{}{}
+		h.joined[c] = append(h.joined[c], topic)
+	}
+	return members(conns)
+}
+
+func (h *MemoryHub) Leave(topic string, c *Conn) []Member {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	conns := h.topics[topic]
+	delete(conns, c)
+	if len(conns) == 0 {
+		delete(h.topics, topic)
+	}
+
+	h.joined[c] = slices.DeleteFunc(h.joined[c], func(t string) bool { return t == topic })
+	if len(h.joined[c]) == 0 {
+		delete(h.joined, c)
+	}
+	return members(conns)
+}
+
+func (h *MemoryHub) Topics(c *Conn) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return slices.Clone(h.joined[c])
+}
+
+func (h *MemoryHub) Members(topic string) []Member {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return members(h.topics[topic])
+}
+
+func (h *MemoryHub) Evict(topic string, userID int, m Message) []Member {
+	var evicted []*Conn
+	h.mu.RLock()
+	for c := range h.topics[topic] {
+		if c.userID == userID {
+			evicted = append(evicted, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	left := h.Members(topic)
+	for _, c := range evicted {
+		left = h.Leave(topic, c)
+		c.Send(m)
+	}
+	return left
+}
+
+func (h *MemoryHub) Publish(topic string, m Message, skip *Conn) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.topics[topic] {
+		if c != skip {
+			c.Send(m)
+		}
+	}
+}
+
+// members lists conns oldest first.
+func members(conns map[*Conn]struct{}) []Member {
+	list := make([]Member, 0, len(conns))
+	for c := range conns {
+		list = append(list, c.Member())
+	}
+	slices.SortFunc(list, func(a, b Member) int { return a.Since.Compare(b.Since) })
+	return list
+}
@@ -0,0 +1,101 @@
+package collab
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// received returns the messages queued for c.
+func received(t *testing.T, c *Conn) []Message {
+	t.Helper()
+	var msgs []Message
+	for {
+		select {
+		case data := <-c.send:
+			var m Message
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatalf("decoding message: %v", err)
+			}
+			msgs = append(msgs, m)
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestTopicSharesTasksAndProjectsOnly(t *testing.T) {
+	for _, name := range []string{"task:42", "project:7"} {
+		if Topic(1, name) != Topic(2, name) {
+			t.Errorf("%s: users 1 and 2 are on different topics %q and %q", name, Topic(1, name), Topic(2, name))
+		}
+	}
+	if Topic(1, "tasks") == Topic(2, "tasks") {
+		t.Errorf("tasks: users 1 and 2 share the topic %q", Topic(1, "tasks"))
+	}
+
+	for _, name := range []string{"tasks", "task:42", "project:7"} {
+		if got := TopicName(Topic(3, name)); got != name {
+			t.Errorf("TopicName(Topic(3, %q)) = %q", name, got)
+		}
+	}
+}
+
+func TestMemoryHubTwoUsersOnOneTopic(t *testing.T) {
+	hub := NewMemoryHub()
+	alice, bob := NewConn(nil, 1), NewConn(nil, 2)
+	topic := Topic(alice.UserID(), "task:42")
+
+	hub.Join(topic, alice)
+	members := hub.Join(Topic(bob.UserID(), "task:42"), bob)
+	if len(members) != 2 || members[0].UserID != 1 || members[1].UserID != 2 {
+		t.Fatalf("members = %+v, want users 1 and 2", members)
+	}
+
+	typing := true
+	from := alice.Member()
+	hub.Publish(topic, Message{Type: TypeTyping, Topic: "task:42", From: &from, Typing: &typing}, alice)
+	if msgs := received(t, alice); len(msgs) != 0 {
+		t.Errorf("sender got its own typing indicator: %+v", msgs)
+	}
+	msgs := received(t, bob)
+	if len(msgs) != 1 || msgs[0].Type != TypeTyping || msgs[0].From.UserID != 1 {
+		t.Fatalf("bob got %+v, want alice's typing indicator", msgs)
+	}
+
+	hub.Publish(topic, Message{Type: "task.updated", Topic: "task:42"}, nil)
+	for _, c := range []*Conn{alice, bob} {
+		if msgs := received(t, c); len(msgs) != 1 || msgs[0].Type != "task.updated" {
+			t.Errorf("user %d got %+v, want the task event", c.UserID(), msgs)
+		}
+	}
+}
+
+func TestMemoryHubEvict(t *testing.T) {
+	hub := NewMemoryHub()
+	alice, bob, bobPhone := NewConn(nil, 1), NewConn(nil, 2), NewConn(nil, 2)
+	for _, c := range []*Conn{alice, bob, bobPhone} {
+		hub.Join("project:7", c)
+	}
+
+	left := hub.Evict("project:7", 2, Message{Type: TypeUnsubscribed, Topic: "project:7"})
+	if len(left) != 1 || left[0].UserID != 1 {
+		t.Fatalf("members left = %+v, want only user 1", left)
+	}
+	for _, c := range []*Conn{bob, bobPhone} {
+		msgs := received(t, c)
+		if len(msgs) != 1 || msgs[0].Type != TypeUnsubscribed {
+			t.Errorf("evicted connection got %+v, want an unsubscribed message", msgs)
+		}
+		if topics := hub.Topics(c); len(topics) != 0 {
+			t.Errorf("evicted connection is still on %v", topics)
+		}
+	}
+
+	hub.Publish("project:7", Message{Type: "task.created"}, nil)
+	if msgs := received(t, bob); len(msgs) != 0 {
+		t.Errorf("evicted connection got %+v", msgs)
+	}
+	if msgs := received(t, alice); len(msgs) != 1 {
+		t.Errorf("remaining member got %d messages, want 1", len(msgs))
+	}
+}
@@ -0,0 +1,68 @@
+package collab
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"task-manager-server/internal/response"
+)
+
+// Message types. Clients send the request types; the server sends the
+// others, plus task events under their event type (task.created, ...).
+const (
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypeTyping      = "typing"
+	TypeTaskCreate  = "task.create"
+	TypeTaskUpdate  = "task.update"
+	TypeTaskDelete  = "task.delete"
+
+	TypePresence     = "presence"
+	TypeUnsubscribed = "unsubscribed"
+	TypeAck          = "ack"
+	TypeError        = "error"
+)
+
+// Request is a message from a client. Ref is echoed in the ack or error
+// answering it.
+type Request struct {
+	Type   string          `json:"type"`
+	Ref    string          `json:"ref,omitempty"`
+	Topic  string          `json:"topic,omitempty"`
+	ID     int             `json:"id,omitempty"`
+	Typing bool            `json:"typing,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// Message is a message to a client.
+type Message struct {
+	Type    string            `json:"type"`
+	ID      string            `json:"id,omitempty"`
+	Ref     string            `json:"ref,omitempty"`
+	Topic   string            `json:"topic,omitempty"`
+	Members []Member          `json:"members,omitempty"`
+	From    *Member           `json:"from,omitempty"`
+	Typing  *bool             `json:"typing,omitempty"`
+	Data    any               `json:"data,omitempty"`
+	Error   *response.Problem `json:"error,omitempty"`
+}
+
+// Ack answers a request that succeeded.
+func Ack(ref string, data any) Message {
+	return Message{Type: TypeAck, Ref: ref, Data: data}
+}
+
+// Error answers a request that failed with the same problem details the
+// HTTP API would return.
+func Error(ref string, p *response.Problem) Message {
+	return Message{Type: TypeError, Ref: ref, Error: p}
+}
+
+func errorMessage(ref, slug, detail string) Message {
+	return Error(ref, &response.Problem{
+		Type:   response.TypeURI(slug),
+		Title:  http.StatusText(http.StatusBadRequest),
+		Status: http.StatusBadRequest,
+		Detail: detail,
+	})
+}
@@ -0,0 +1,44 @@
+package collab
+
+import (
+	"log/slog"
+	"strconv"
+
+	"task-manager-server/internal/events"
+	"task-manager-server/internal/models"
+)
+
+// Relay publishes the task events of broker on hub, to the owner's task
+// list topic ("tasks"), to the task's own topic ("task:<id>") and, for tasks
+// in a project, to the project's topic ("project:<id>"). It runs until the
+// process exits.
+func Relay(broker *events.Broker, hub Hub) {
+	for {
+		sub := broker.SubscribeAll()
+		for e := range sub.C {
+			var (
+				taskID    int
+				projectID *int
+			)
+			switch data := e.Data.(type) {
+			case *models.Task:
+				taskID, projectID = data.ID, data.ProjectID
+			case models.DeletedTask:
+				taskID, projectID = data.ID, data.ProjectID
+			default:
+				continue
+			}
+
+			m := Message{Type: e.Type, ID: e.ID, Data: e.Data}
+			m.Topic = "tasks"
+			hub.Publish(Topic(e.UserID, m.Topic), m, nil)
+			m.Topic = "task:" + strconv.Itoa(taskID)
+			hub.Publish(Topic(e.UserID, m.Topic), m, nil)
+			if projectID != nil {
+				m.Topic = "project:" + strconv.Itoa(*projectID)
+				hub.Publish(Topic(e.UserID, m.Topic), m, nil)
+			}
+		}
+		slog.Warn("Collab: relay fell behind the event broker, resubscribing")
+	}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"log/slog"
+	"time"
+)
+
+// APIConfig holds the settings of the HTTP API.
+type APIConfig struct {
+	// LegacyDeprecatedAt is announced in the Deprecation header.
+	LegacyDeprecatedAt time.Time
+	// LegacySunset is announced in the Sunset header.
+	LegacySunset time.Time
+	// ValidateAgainstSpec checks requests and responses against the
+	// OpenAPI document. It is on when APP_ENV is "development".
+	ValidateAgainstSpec bool
+	// IdempotencyTTL is how long responses to requests with an
+	// Idempotency-Key are replayed.
+	IdempotencyTTL time.Duration
+}
+
+func NewAPIConfig() APIConfig {
+	return APIConfig{
+		LegacyDeprecatedAt:  getdate("API_LEGACY_DEPRECATED_AT", "2026-10-19"),
+		LegacySunset:        getdate("API_LEGACY_SUNSET", "2027-04-30"),
+		ValidateAgainstSpec: getenv("APP_ENV", "production") == "development",
+		IdempotencyTTL:      time.Duration(getint("IDEMPOTENCY_TTL_HOURS", 24)) * time.Hour,
+	}
+}
+
+// getdate reads a YYYY-MM-DD date.
+func getdate(key, fallback string) time.Time {
+	t, err := time.Parse(time.DateOnly, getenv(key, fallback))
+	if err != nil {
+		slog.Warn("Invalid date, using the default", "key", key, "default", fallback, "err", err)
+		t, _ = time.Parse(time.DateOnly, fallback)
+	}
+	return t
+}
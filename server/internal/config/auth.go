@@ -0,0 +1,65 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager-server/internal/lockout"
+)
+
+// AuthConfig holds the settings used by the account flows.
+type AuthConfig struct {
+	// AppURL is the public URL of the frontend; links in emails point here.
+	AppURL string
+	// RequireEmailVerification blocks login until the email is verified.
+	RequireEmailVerification bool
+	// AccountLockout and IPLockout throttle failed logins per account and
+	// per client IP.
+	AccountLockout lockout.Policy
+	IPLockout      lockout.Policy
+	// UnlockRequests throttles requests for unlock emails per address and
+	// per client IP.
+	UnlockRequests lockout.Policy
+}
+
+func NewAuthConfig() AuthConfig {
+	return AuthConfig{
+		AppURL:                   strings.TrimSuffix(getenv("APP_URL", "http://localhost:5173"), "/"),
+		RequireEmailVerification: getbool("REQUIRE_EMAIL_VERIFICATION", false),
+		AccountLockout: lockout.Policy{
+			MaxAttempts: getint("LOCKOUT_MAX_ATTEMPTS", 5),
+			BaseDelay:   30 * time.Second,
+			MaxDelay:    time.Hour,
+			Window:      24 * time.Hour,
+		},
+		IPLockout: lockout.Policy{
+			MaxAttempts: getint("LOCKOUT_IP_MAX_ATTEMPTS", 20),
+			BaseDelay:   30 * time.Second,
+			MaxDelay:    time.Hour,
+			Window:      time.Hour,
+		},
+		UnlockRequests: lockout.Policy{
+			MaxAttempts: getint("UNLOCK_REQUEST_MAX", 3),
+			BaseDelay:   15 * time.Minute,
+			MaxDelay:    time.Hour,
+			Window:      time.Hour,
+		},
+	}
+}
+
+func getbool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(getenv(key, strconv.FormatBool(fallback)))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+func getint(key string, fallback int) int {
+	v, err := strconv.Atoi(getenv(key, strconv.Itoa(fallback)))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
@@ -1,33 +1,45 @@
 package config
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"time"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/go-sql-driver/mysql"
-	godotenv "github.com/joho/godotenv"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// NewDB connects to MySQL. Call LoadEnv first so DB_* may come from .env.
+//
+// Every statement run with the context of a traced request gets a span,
+// with the SQL text but never the arguments. Statements of background work,
+// such as webhook deliveries, are not traced.
 func NewDB() (*sql.DB, error) {
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
-	} else {
-		log.Println(".env file loaded successfully")
-	}
-
 	host := getenv("DB_HOST", "localhost:3306")
 	user := getenv("DB_USER", "root")
 	pass := getenv("DB_PASS", "")
 	name := getenv("DB_NAME", "task_manager")
 
-	log.Printf("Connecting to MySQL with: host=%s, user=%s, db=%s, pass_length=%d", host, user, name, len(pass))
+	slog.Info("Connecting to MySQL", "host", host, "user", user, "db", name)
 
 	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true&charset=utf8mb4&loc=Local", user, pass, host, name)
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := otelsql.Open("mysql", dsn,
+		otelsql.WithAttributes(semconv.DBSystemNameMySQL, semconv.DBNamespace(name)),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitConnectorConnect: true,
+			OmitRows:             true,
+			SpanFilter:           inTrace,
+		}),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -39,9 +51,44 @@ func NewDB() (*sql.DB, error) {
 	return db, nil
 }
 
+// inTrace keeps statements run outside any trace from starting traces of
+// their own.
+func inTrace(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+	return trace.SpanContextFromContext(ctx).IsValid()
+}
+
 func getenv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
 	}
 	return fallback
 }
+
+// TxConfig controls how units of work retry transactions that MySQL
+// aborted because of a deadlock or a lock wait timeout.
+type TxConfig struct {
+	// MaxAttempts is how often a transaction is run before the error is
+	// returned. One turns retrying off.
+	MaxAttempts int
+	// RetryDelay is the wait before the second attempt; it doubles with
+	// every further one. A random part is added so that the transactions
+	// that collided do not collide again.
+	RetryDelay time.Duration
+}
+
+// NewTxConfig reads DB_TX_MAX_ATTEMPTS and DB_TX_RETRY_DELAY_MS. A
+// transaction runs at least once and never waits a negative time, so
+// values below 1 and 0 are rejected rather than clamped.
+func NewTxConfig() (TxConfig, error) {
+	cfg := TxConfig{
+		MaxAttempts: getint("DB_TX_MAX_ATTEMPTS", 3),
+		RetryDelay:  time.Duration(getint("DB_TX_RETRY_DELAY_MS", 20)) * time.Millisecond,
+	}
+	if cfg.MaxAttempts < 1 {
+		return TxConfig{}, fmt.Errorf("DB_TX_MAX_ATTEMPTS must be at least 1, got %d", cfg.MaxAttempts)
+	}
+	if cfg.RetryDelay < 0 {
+		return TxConfig{}, fmt.Errorf("DB_TX_RETRY_DELAY_MS must not be negative, got %d", cfg.RetryDelay.Milliseconds())
+	}
+	return cfg, nil
+}
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"fmt"
+
+	"task-manager-server/internal/events"
+)
+
+// NewEventBroker creates the broker behind the event streams. It keeps the
+// last EVENTS_REPLAY_SIZE events so reconnecting clients can catch up; 0
+// turns replay off.
+func NewEventBroker() (*events.Broker, error) {
+	replaySize := getint("EVENTS_REPLAY_SIZE", 1000)
+	if replaySize < 0 {
+		return nil, fmt.Errorf("EVENTS_REPLAY_SIZE must not be negative, got %d", replaySize)
+	}
+	return events.NewBroker(replaySize), nil
+}
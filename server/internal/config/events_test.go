@@ -0,0 +1,17 @@
+package config
+
+import "testing"
+
+func TestNewEventBrokerRejectsNegativeReplaySize(t *testing.T) {
+	t.Setenv("EVENTS_REPLAY_SIZE", "-1")
+	if _, err := NewEventBroker(); err == nil {
+		t.Error("a negative EVENTS_REPLAY_SIZE was accepted")
+	}
+
+	for _, size := range []string{"0", "1000"} {
+		t.Setenv("EVENTS_REPLAY_SIZE", size)
+		if _, err := NewEventBroker(); err != nil {
+			t.Errorf("EVENTS_REPLAY_SIZE=%s: %v", size, err)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+// GraphQLConfig bounds the operations the GraphQL endpoint runs. They are
+// checked before execution, so an expensive query never reaches the
+// database. Zero disables a limit.
+type GraphQLConfig struct {
+	// MaxDepth is the deepest nesting of fields; the fields of the
+	// operation itself are at depth 1.
+	MaxDepth int
+	// MaxComplexity bounds the estimated cost. Every field costs 1, and
+	// what is selected below a list field counts 10 times.
+	MaxComplexity int
+}
+
+func NewGraphQLConfig() GraphQLConfig {
+	return GraphQLConfig{
+		MaxDepth:      getint("GRAPHQL_MAX_DEPTH", 10),
+		MaxComplexity: getint("GRAPHQL_MAX_COMPLEXITY", 1000),
+	}
+}
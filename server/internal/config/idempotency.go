@@ -0,0 +1,22 @@
+package config
+
+import (
+	"database/sql"
+	"log/slog"
+
+	"task-manager-server/internal/idempotency"
+)
+
+// NewIdempotencyStore picks where responses to Idempotency-Key requests are
+// kept from IDEMPOTENCY_STORE: "db" shares them between instances, "memory"
+// (default) keeps them in the process.
+func NewIdempotencyStore(db *sql.DB) idempotency.Store {
+	switch getenv("IDEMPOTENCY_STORE", "memory") {
+	case "db":
+		slog.Info("Idempotency: storing responses in the database")
+		return idempotency.NewDBStore(db)
+	default:
+		slog.Info("Idempotency: storing responses in memory")
+		return idempotency.NewMemoryStore()
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"database/sql"
+	"log/slog"
+
+	"task-manager-server/internal/lockout"
+)
+
+// NewLockoutStore picks where failed login attempts are tracked from
+// LOCKOUT_STORE: "db" shares them between instances, "memory" (default)
+// keeps them in the process.
+func NewLockoutStore(db *sql.DB) lockout.Store {
+	switch getenv("LOCKOUT_STORE", "memory") {
+	case "db":
+		slog.Info("Lockout: tracking failed logins in the database")
+		return lockout.NewDBStore(db)
+	default:
+		slog.Info("Lockout: tracking failed logins in memory")
+		return lockout.NewMemoryStore()
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+
+	"task-manager-server/internal/logging"
+
+	godotenv "github.com/joho/godotenv"
+)
+
+// LoadEnv reads a .env file into the environment, if there is one.
+// Variables already set take precedence.
+func LoadEnv() {
+	if err := godotenv.Load(); err != nil {
+		slog.Info("No .env file found, using system environment variables")
+	} else {
+		slog.Info(".env file loaded successfully")
+	}
+}
+
+// NewLogger writes to stderr at LOG_LEVEL (debug, info, warn or error) in
+// LOG_FORMAT: JSON, or "text" for key=value lines that read better in a
+// terminal.
+func NewLogger() *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(getenv("LOG_LEVEL", "info"))); err != nil {
+		level = slog.LevelInfo
+	}
+	return slog.New(logging.NewHandler(os.Stderr, getenv("LOG_FORMAT", "json"), level))
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"log/slog"
+
+	"task-manager-server/internal/mailer"
+)
+
+// NewMailer picks the mail transport from MAIL_DRIVER. "smtp" delivers real
+// emails; anything else falls back to the log mailer used for development.
+func NewMailer() mailer.Mailer {
+	switch getenv("MAIL_DRIVER", "log") {
+	case "smtp":
+		host := getenv("SMTP_HOST", "localhost")
+		port := getenv("SMTP_PORT", "587")
+		slog.Info("Mailer: using SMTP relay", "host", host, "port", port)
+		return mailer.NewSMTPMailer(
+			host,
+			port,
+			getenv("SMTP_USER", ""),
+			getenv("SMTP_PASS", ""),
+			getenv("MAIL_FROM", "no-reply@localhost"),
+		)
+	default:
+		path := getenv("MAIL_LOG_FILE", "")
+		slog.Info("Mailer: logging emails instead of sending", "file", path)
+		return mailer.NewLogMailer(path)
+	}
+}
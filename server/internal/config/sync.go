@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// SyncConfig controls the delta sync of offline clients.
+type SyncConfig struct {
+	// TombstoneRetention is how long deletes are kept for clients that
+	// have not synced yet. Clients offline for longer get a full resync.
+	TombstoneRetention time.Duration
+}
+
+func NewSyncConfig() SyncConfig {
+	return SyncConfig{
+		TombstoneRetention: time.Duration(getint("SYNC_TOMBSTONE_RETENTION_DAYS", 30)) * 24 * time.Hour,
+	}
+}
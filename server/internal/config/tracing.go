@@ -0,0 +1,106 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+)
+
+// NewTracerProvider builds the provider of the server's traces from the
+// OTEL_* variables:
+//
+//   - OTEL_TRACES_EXPORTER picks where spans go: "otlp" sends them to a
+//     collector at OTEL_EXPORTER_OTLP_ENDPOINT over HTTP, "stdout" prints
+//     them, "file" appends them to OTEL_TRACES_FILE as one JSON object per
+//     line, and "none", the default, records nothing.
+//   - OTEL_TRACES_SAMPLER_ARG is the ratio of new traces that are sampled.
+//     Requests that carry a traceparent follow the caller's decision.
+//   - OTEL_SERVICE_NAME names the service in the traces.
+//
+// stdout and file write each span as it ends; otlp sends them in batches.
+// The returned shutdown flushes the spans that are still buffered and
+// closes the traces file; call it before the process exits.
+func NewTracerProvider() (tp *sdktrace.TracerProvider, shutdown func(context.Context) error, err error) {
+	ctx := context.Background()
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(getenv("OTEL_SERVICE_NAME", "task-manager-server")),
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+	ratio := getfloat("OTEL_TRACES_SAMPLER_ARG", 1)
+	sampler := sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+
+	var (
+		processor sdktrace.SpanProcessor
+		file      *os.File
+	)
+	switch exporter := getenv("OTEL_TRACES_EXPORTER", "none"); exporter {
+	case "otlp":
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exp)
+	case "stdout", "file":
+		w := os.Stdout
+		if exporter == "file" {
+			path := getenv("OTEL_TRACES_FILE", "traces.json")
+			if file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644); err != nil {
+				return nil, nil, err
+			}
+			w = file
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			if file != nil {
+				file.Close()
+			}
+			return nil, nil, err
+		}
+		processor = sdktrace.NewSimpleSpanProcessor(exp)
+	case "none":
+		// Incoming trace context is still passed on; no span is recorded.
+		sampler = sdktrace.NeverSample()
+	default:
+		return nil, nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", exporter)
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	}
+	if processor != nil {
+		opts = append(opts, sdktrace.WithSpanProcessor(processor))
+		slog.Info("Tracing: exporting spans", "exporter", getenv("OTEL_TRACES_EXPORTER", "none"), "sampleRatio", ratio)
+	}
+	tp = sdktrace.NewTracerProvider(opts...)
+
+	// The stdout exporter does not close its writer.
+	shutdown = func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}
+	return tp, shutdown, nil
+}
+
+func getfloat(key string, fallback float64) float64 {
+	v, err := strconv.ParseFloat(getenv(key, strconv.FormatFloat(fallback, 'g', -1, 64)), 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,14 @@
+package config
+
+// UndoConfig controls the journal undo and redo replay.
+type UndoConfig struct {
+	// JournalDepth is how many task commands are kept per user for undo.
+	// Zero turns the journal off.
+	JournalDepth int
+}
+
+func NewUndoConfig() UndoConfig {
+	return UndoConfig{
+		JournalDepth: getint("UNDO_JOURNAL_DEPTH", 20),
+	}
+}
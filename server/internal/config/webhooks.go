@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+// WebhookConfig controls the delivery of webhooks.
+type WebhookConfig struct {
+	// MaxAttempts is how often a delivery is tried before it fails.
+	MaxAttempts int
+	// BaseDelay is the wait after the first failed attempt; it doubles
+	// with every further one, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// DisableAfter failed attempts in a row disable the webhook.
+	DisableAfter int
+	// Timeout bounds one attempt, including reading the response.
+	Timeout time.Duration
+	// PollInterval is how often the queue is checked for due deliveries.
+	PollInterval time.Duration
+	// AllowPrivateNetworks lets webhooks target loopback and private
+	// addresses. Off in production so webhooks cannot probe the internal
+	// network.
+	AllowPrivateNetworks bool
+}
+
+func NewWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts:          getint("WEBHOOK_MAX_ATTEMPTS", 8),
+		BaseDelay:            time.Duration(getint("WEBHOOK_BASE_DELAY_SECONDS", 30)) * time.Second,
+		MaxDelay:             6 * time.Hour,
+		DisableAfter:         getint("WEBHOOK_DISABLE_AFTER", 20),
+		Timeout:              10 * time.Second,
+		PollInterval:         5 * time.Second,
+		AllowPrivateNetworks: getbool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+	}
+}
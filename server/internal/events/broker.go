@@ -0,0 +1,189 @@
+// Package events is an in-process publish/subscribe broker for changes to a
+// user's data. Services publish after their changes are committed; stream
+// endpoints subscribe per user.
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	TaskCreated = "task.created"
+	TaskUpdated = "task.updated"
+	TaskDeleted = "task.deleted"
+	// TaskCompleted follows the task.updated of a change that marked a
+	// task done.
+	TaskCompleted = "task.completed"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped. Dropped subscribers resume from the replay buffer.
+const subscriberBuffer = 64
+
+// relayBuffer is the equivalent for subscribers of every user's events.
+const relayBuffer = 1024
+
+// Event is one change, delivered to the subscribers of UserID.
+type Event struct {
+	// ID is unique for the lifetime of the broker, "<epoch>-<seq>".
+	ID     string    `json:"id"`
+	Type   string    `json:"type"`
+	UserID int       `json:"-"`
+	Data   any       `json:"data"`
+	Time   time.Time `json:"time"`
+
+	seq uint64
+}
+
+// Broker fans events out to subscribers and keeps the most recent ones so
+// clients can resume after a reconnect.
+type Broker struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	replay []Event // ring buffer of the last len(replay) events
+	next   int     // index of the next write in replay
+	subs   map[*Subscription]struct{}
+}
+
+// NewBroker creates a broker that keeps the last replaySize events.
+func NewBroker(replaySize int) *Broker {
+	return &Broker{
+		// IDs from before a restart must not be mistaken for new ones.
+		epoch:  strconv.FormatInt(time.Now().UnixMilli(), 36),
+		replay: make([]Event, 0, replaySize),
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish delivers an event to the subscribers of userID.
+func (b *Broker) Publish(userID int, typ string, data any) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e := Event{
+		ID:     b.epoch + "-" + strconv.FormatUint(b.seq, 10),
+		Type:   typ,
+		UserID: userID,
+		Data:   data,
+		Time:   time.Now().UTC(),
+		seq:    b.seq,
+	}
+
+	if cap(b.replay) > 0 {
+		if len(b.replay) < cap(b.replay) {
+			b.replay = append(b.replay, e)
+		} else {
+			b.replay[b.next] = e
+		}
+		b.next = (b.next + 1) % cap(b.replay)
+	}
+
+	for sub := range b.subs {
+		if !sub.all && sub.userID != userID {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			// Too slow: drop it rather than block publishers. The
+			// client reconnects and replays what it missed.
+			b.remove(sub)
+		}
+	}
+	return e
+}
+
+// Subscribe registers a subscriber for userID. When lastEventID is set, the
+// events published after it are returned for replay. complete is false when
+// they can no longer all be replayed, because they were evicted from the
+// buffer or the ID is from before a restart; the client has to refetch.
+func (b *Broker) Subscribe(userID int, lastEventID string) (sub *Subscription, replay []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{
+		c:      make(chan Event, subscriberBuffer),
+		userID: userID,
+		broker: b,
+	}
+	sub.C = sub.c
+	b.subs[sub] = struct{}{}
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+
+	epoch, seqStr, _ := strings.Cut(lastEventID, "-")
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil || epoch != b.epoch || seq > b.seq {
+		return sub, nil, false
+	}
+
+	events := b.buffered()
+	if seq < b.seq && (len(events) == 0 || events[0].seq > seq+1) {
+		complete = false
+	} else {
+		complete = true
+	}
+	for _, e := range events {
+		if e.seq > seq && e.UserID == userID {
+			replay = append(replay, e)
+		}
+	}
+	return sub, replay, complete
+}
+
+// SubscribeAll registers a subscriber for the events of every user, for
+// relaying them to another transport.
+func (b *Broker) SubscribeAll() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &Subscription{
+		c:      make(chan Event, relayBuffer),
+		all:    true,
+		broker: b,
+	}
+	sub.C = sub.c
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// buffered returns the replay buffer oldest first.
+func (b *Broker) buffered() []Event {
+	if len(b.replay) < cap(b.replay) {
+		return b.replay
+	}
+	return append(b.replay[b.next:len(b.replay):len(b.replay)], b.replay[:b.next]...)
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+// Subscription receives the events of one user on C. C is closed when the
+// subscription is closed or the subscriber fell too far behind.
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	userID int
+	all    bool
+	broker *Broker
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
@@ -0,0 +1,130 @@
+package events
+
+import (
+	"testing"
+)
+
+// ids returns the IDs of events.
+func ids(events []Event) []string {
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestSubscribeReplaysMissedEventsOfTheUser(t *testing.T) {
+	b := NewBroker(10)
+	seen := b.Publish(1, TaskCreated, nil)
+	b.Publish(2, TaskCreated, nil)
+	missed := b.Publish(1, TaskUpdated, nil)
+
+	sub, replay, complete := b.Subscribe(1, seen.ID)
+	defer sub.Close()
+
+	if !complete {
+		t.Error("complete = false, want true: nothing was evicted")
+	}
+	if len(replay) != 1 || replay[0].ID != missed.ID {
+		t.Errorf("replay = %v, want only %s", ids(replay), missed.ID)
+	}
+}
+
+func TestSubscribeUpToDate(t *testing.T) {
+	b := NewBroker(10)
+	last := b.Publish(1, TaskCreated, nil)
+
+	for _, lastEventID := range []string{"", last.ID} {
+		sub, replay, complete := b.Subscribe(1, lastEventID)
+		sub.Close()
+		if !complete || len(replay) != 0 {
+			t.Errorf("Subscribe(%q) = %v, %v; want nothing to replay", lastEventID, ids(replay), complete)
+		}
+	}
+}
+
+// Events that no longer fit the buffer cannot be replayed; the client is
+// told to refetch and gets what is still buffered.
+func TestSubscribeAfterEviction(t *testing.T) {
+	b := NewBroker(2)
+	first := b.Publish(1, TaskCreated, nil)
+	b.Publish(1, TaskUpdated, nil)
+	third := b.Publish(1, TaskUpdated, nil)
+	fourth := b.Publish(1, TaskDeleted, nil)
+
+	sub, replay, complete := b.Subscribe(1, first.ID)
+	defer sub.Close()
+
+	if complete {
+		t.Error("complete = true, want false: the second event was evicted")
+	}
+	if got := ids(replay); len(got) != 2 || got[0] != third.ID || got[1] != fourth.ID {
+		t.Errorf("replay = %v, want %s and %s in order", got, third.ID, fourth.ID)
+	}
+}
+
+func TestSubscribeWithForeignID(t *testing.T) {
+	b := NewBroker(10)
+	b.Publish(1, TaskCreated, nil)
+
+	for _, lastEventID := range []string{"otherepoch-1", b.epoch + "-99", "garbage"} {
+		sub, replay, complete := b.Subscribe(1, lastEventID)
+		sub.Close()
+		if complete || len(replay) != 0 {
+			t.Errorf("Subscribe(%q) = %v, %v; want a reset without replay", lastEventID, ids(replay), complete)
+		}
+	}
+}
+
+func TestBrokerWithoutReplay(t *testing.T) {
+	b := NewBroker(0)
+	first := b.Publish(1, TaskCreated, nil)
+	b.Publish(1, TaskUpdated, nil)
+
+	sub, replay, complete := b.Subscribe(1, first.ID)
+	defer sub.Close()
+	if complete || len(replay) != 0 {
+		t.Errorf("replay = %v, complete = %v; want a reset", ids(replay), complete)
+	}
+}
+
+func TestPublishDeliversToSubscribersOfTheUser(t *testing.T) {
+	b := NewBroker(10)
+	sub, _, _ := b.Subscribe(1, "")
+	defer sub.Close()
+	all := b.SubscribeAll()
+	defer all.Close()
+
+	b.Publish(2, TaskCreated, nil)
+	e := b.Publish(1, TaskCreated, "data")
+
+	if got := <-sub.C; got.ID != e.ID || got.Data != "data" {
+		t.Errorf("user 1 received %+v, want %s", got, e.ID)
+	}
+	select {
+	case got := <-sub.C:
+		t.Errorf("user 1 also received %+v", got)
+	default:
+	}
+	if first, second := <-all.C, <-all.C; first.UserID != 2 || second.UserID != 1 {
+		t.Errorf("SubscribeAll received users %d and %d, want 2 then 1", first.UserID, second.UserID)
+	}
+}
+
+// A subscriber that falls behind is dropped instead of blocking publishers.
+func TestPublishDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(10)
+	sub, _, _ := b.Subscribe(1, "")
+
+	for range subscriberBuffer + 1 {
+		b.Publish(1, TaskUpdated, nil)
+	}
+	received := 0
+	for range sub.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d events before the channel closed, want %d", received, subscriberBuffer)
+	}
+	sub.Close()
+}
@@ -0,0 +1,137 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+
+	"task-manager-server/internal/models"
+)
+
+type contextKey struct{}
+
+// requestState is shared by the resolvers of one request.
+type requestState struct {
+	schema *Schema
+	userID int
+
+	mu      sync.Mutex
+	loaders *loaders
+}
+
+type loaders struct {
+	tasks         *Loader[int, *models.Task]
+	subtasks      *Loader[int, []*models.Task]
+	users         *Loader[int, *models.User]
+	labels        *Loader[int, []models.Label]
+	commentCounts *Loader[int, int]
+	projects      *Loader[int, *models.Project]
+	projectTasks  *Loader[int, []*models.Task]
+}
+
+// withUser prepares ctx for executing a request of userID.
+func (s *Schema) withUser(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestState{
+		schema:  s,
+		userID:  userID,
+		loaders: s.newLoaders(ctx, userID),
+	})
+}
+
+func (s *Schema) newLoaders(ctx context.Context, userID int) *loaders {
+	return &loaders{
+		tasks: NewLoader(func(ids []int) (map[int]*models.Task, error) {
+			tasks, err := s.tasks.TasksByID(ctx, userID, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[int]*models.Task, len(tasks))
+			for i := range tasks {
+				byID[tasks[i].ID] = &tasks[i]
+			}
+			return byID, nil
+		}),
+		subtasks: NewLoader(func(ids []int) (map[int][]*models.Task, error) {
+			tasks, err := s.tasks.SubtasksOf(ctx, userID, ids)
+			if err != nil {
+				return nil, err
+			}
+			byParent := make(map[int][]*models.Task)
+			for i := range tasks {
+				parent := *tasks[i].ParentID
+				byParent[parent] = append(byParent[parent], &tasks[i])
+			}
+			return byParent, nil
+		}),
+		users: NewLoader(func(ids []int) (map[int]*models.User, error) {
+			// Owners are the requesting user or, for tasks shared in a
+			// project, another member, whose profile is not shown.
+			byID := make(map[int]*models.User, len(ids))
+			for _, id := range ids {
+				user, err := s.users.GetUser(ctx, id)
+				if err != nil {
+					return nil, err
+				}
+				if id != userID {
+					user = &models.User{ID: user.ID, Name: user.Name, CreatedAt: user.CreatedAt}
+				}
+				byID[id] = user
+			}
+			return byID, nil
+		}),
+		labels: NewLoader(func(ids []int) (map[int][]models.Label, error) {
+			return s.tasks.LabelsOf(ctx, ids)
+		}),
+		commentCounts: NewLoader(func(ids []int) (map[int]int, error) {
+			return s.tasks.CommentCounts(ctx, ids)
+		}),
+		projects: NewLoader(func(ids []int) (map[int]*models.Project, error) {
+			projects, err := s.projects.ProjectsByID(ctx, userID, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[int]*models.Project, len(projects))
+			for id, p := range projects {
+				byID[id] = &p
+			}
+			return byID, nil
+		}),
+		projectTasks: NewLoader(func(ids []int) (map[int][]*models.Task, error) {
+			tasks, err := s.projects.TasksInProjects(ctx, userID, ids)
+			if err != nil {
+				return nil, err
+			}
+			byProject := make(map[int][]*models.Task, len(tasks))
+			for id, list := range tasks {
+				for i := range list {
+					byProject[id] = append(byProject[id], &list[i])
+				}
+			}
+			return byProject, nil
+		}),
+	}
+}
+
+func stateFrom(ctx context.Context) *requestState {
+	return ctx.Value(contextKey{}).(*requestState)
+}
+
+func userID(p graphql.ResolveParams) int {
+	return stateFrom(p.Context).userID
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	st := stateFrom(ctx)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.loaders
+}
+
+// resetLoaders drops what the loaders of ctx cached.
+func resetLoaders(ctx context.Context) {
+	st := stateFrom(ctx)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.loaders = st.schema.newLoaders(ctx, st.userID)
+}
@@ -0,0 +1,112 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+
+	"task-manager-server/internal/models"
+)
+
+// Execute runs a query or mutation for userID.
+func (s *Schema) Execute(ctx context.Context, userID int, req models.GraphQLRequest) *graphql.Result {
+	doc, op, errs := s.prepare(req)
+	if errs != nil {
+		return &graphql.Result{Errors: errs}
+	}
+	if op.Operation == ast.OperationTypeSubscription {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{
+			gqlerrors.NewFormattedError("Subscriptions are served over the WebSocket endpoint"),
+		}}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       s.withUser(ctx, userID),
+	})
+}
+
+// Subscribe runs any operation for userID. Subscriptions yield a result
+// per event until ctx is done; the channel must be drained until it is
+// closed. Queries and mutations yield a single result.
+func (s *Schema) Subscribe(ctx context.Context, userID int, req models.GraphQLRequest) <-chan *graphql.Result {
+	doc, op, errs := s.prepare(req)
+	if errs != nil || op.Operation != ast.OperationTypeSubscription {
+		c := make(chan *graphql.Result, 1)
+		if errs != nil {
+			c <- &graphql.Result{Errors: errs}
+		} else {
+			c <- s.Execute(ctx, userID, req)
+		}
+		close(c)
+		return c
+	}
+
+	return graphql.ExecuteSubscription(graphql.ExecuteParams{
+		Schema:        s.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       s.withUser(ctx, userID),
+	})
+}
+
+// prepare parses and validates a request and checks the operation it runs
+// against the limits.
+func (s *Schema) prepare(req models.GraphQLRequest) (*ast.Document, *ast.OperationDefinition, []gqlerrors.FormattedError) {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return nil, nil, gqlerrors.FormatErrors(err)
+	}
+
+	if result := graphql.ValidateDocument(&s.schema, doc, nil); !result.IsValid {
+		return nil, nil, result.Errors
+	}
+
+	op, err := operation(doc, req.OperationName)
+	if err != nil {
+		return nil, nil, gqlerrors.FormatErrors(err)
+	}
+	if err := checkLimits(s.config, &s.schema, doc, op); err != nil {
+		return nil, nil, gqlerrors.FormatErrors(err)
+	}
+	return doc, op, nil
+}
+
+// operation picks the operation of doc to run: the one named name, or the
+// only one.
+func operation(doc *ast.Document, name string) (*ast.OperationDefinition, error) {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if found != nil {
+				return nil, errors.New("The document has several operations; operationName must name one")
+			}
+			found = op
+		} else if op.Name != nil && op.Name.Value == name {
+			return op, nil
+		}
+	}
+	if found == nil {
+		if name != "" {
+			return nil, fmt.Errorf("Unknown operation %q", name)
+		}
+		return nil, errors.New("The document has no operation")
+	}
+	return found, nil
+}
@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+
+	"task-manager-server/internal/config"
+)
+
+// listFactor is how many items a list field is assumed to return when
+// estimating the complexity of a query.
+const listFactor = 10
+
+// checkLimits measures op and fails when it exceeds the limits of cfg.
+func checkLimits(cfg config.GraphQLConfig, schema *graphql.Schema, doc *ast.Document, op *ast.OperationDefinition) error {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[f.Name.Value] = f
+		}
+	}
+
+	var root *graphql.Object
+	switch op.Operation {
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	default:
+		root = schema.QueryType()
+	}
+
+	m := &measure{schema: schema, fragments: fragments}
+	depth, cost := m.selections(op.SelectionSet, root, 1, map[string]bool{})
+	if cfg.MaxDepth > 0 && depth > cfg.MaxDepth {
+		return fmt.Errorf("Query depth %d exceeds the limit of %d", depth, cfg.MaxDepth)
+	}
+	if cfg.MaxComplexity > 0 && cost > cfg.MaxComplexity {
+		return fmt.Errorf("Query complexity %d exceeds the limit of %d", cost, cfg.MaxComplexity)
+	}
+	return nil
+}
+
+type measure struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+}
+
+// selections returns the depth and cost of a selection set on parent whose
+// fields are at depth. spreading holds the fragments being expanded.
+func (m *measure) selections(set *ast.SelectionSet, parent graphql.Type, depth int, spreading map[string]bool) (maxDepth, cost int) {
+	if set == nil {
+		return depth - 1, 0
+	}
+
+	for _, sel := range set.Selections {
+		var d, c int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			d, c = m.field(sel, parent, depth, spreading)
+		case *ast.InlineFragment:
+			on := parent
+			if sel.TypeCondition != nil {
+				if t := m.schema.Type(sel.TypeCondition.Name.Value); t != nil {
+					on = t
+				}
+			}
+			d, c = m.selections(sel.SelectionSet, on, depth, spreading)
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			f, ok := m.fragments[name]
+			if !ok || spreading[name] {
+				continue
+			}
+			spreading[name] = true
+			on := parent
+			if t := m.schema.Type(f.TypeCondition.Name.Value); t != nil {
+				on = t
+			}
+			d, c = m.selections(f.SelectionSet, on, depth, spreading)
+			delete(spreading, name)
+		}
+		maxDepth = max(maxDepth, d)
+		cost += c
+	}
+	return maxDepth, cost
+}
+
+func (m *measure) field(f *ast.Field, parent graphql.Type, depth int, spreading map[string]bool) (int, int) {
+	// Introspection and unknown fields have no type to follow; they are
+	// measured like fields of single objects.
+	var fieldType graphql.Type
+	if obj, ok := parent.(*graphql.Object); ok {
+		if def, ok := obj.Fields()[f.Name.Value]; ok {
+			fieldType = def.Type
+		}
+	}
+
+	factor := 1
+	if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+		fieldType = nonNull.OfType
+	}
+	if _, ok := fieldType.(*graphql.List); ok {
+		factor = listFactor
+	}
+
+	var named graphql.Type
+	if fieldType != nil {
+		named, _ = graphql.GetNamed(fieldType).(graphql.Type)
+	}
+	d, c := m.selections(f.SelectionSet, named, depth+1, spreading)
+	return max(depth, d), 1 + factor*c
+}
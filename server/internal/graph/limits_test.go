@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/ast"
+
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/models"
+)
+
+func newTestSchema(t *testing.T, cfg config.GraphQLConfig) *Schema {
+	t.Helper()
+	s, err := NewSchema(nil, nil, nil, nil, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// fragmentsOf indexes the fragments of doc like checkLimits does.
+func fragmentsOf(doc *ast.Document) map[string]*ast.FragmentDefinition {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[f.Name.Value] = f
+		}
+	}
+	return fragments
+}
+
+func TestLimitsMeasureDepthAndComplexity(t *testing.T) {
+	s := newTestSchema(t, config.GraphQLConfig{})
+
+	tests := []struct {
+		query     string
+		wantDepth int
+		wantCost  int
+	}{
+		{`{ me { id } }`, 2, 2},
+		{`{ tasks { id } }`, 2, 11},
+		{`{ tasks { subtasks { id title } } }`, 3, 1 + 10*(1+10*2)},
+		{`{ task(id: 1) { parent { parent { id } } } }`, 4, 4},
+		// Fragments are measured where they are spread.
+		{`{ task(id: 1) { ...up } } fragment up on Task { parent { id } }`, 3, 3},
+		{`{ task(id: 1) { ... on Task { subtasks { id } } } }`, 3, 1 + 1 + 10},
+	}
+	for _, tt := range tests {
+		doc, op, errs := s.prepare(models.GraphQLRequest{Query: tt.query})
+		if errs != nil {
+			t.Fatalf("%s: %v", tt.query, errs)
+		}
+		m := &measure{schema: &s.schema, fragments: fragmentsOf(doc)}
+		depth, cost := m.selections(op.SelectionSet, s.schema.QueryType(), 1, map[string]bool{})
+		if depth != tt.wantDepth || cost != tt.wantCost {
+			t.Errorf("%s: depth %d, cost %d; want %d and %d", tt.query, depth, cost, tt.wantDepth, tt.wantCost)
+		}
+	}
+}
+
+// Operations over the limits fail before anything is resolved; the schema
+// has no services to resolve with.
+func TestExecuteRejectsOperationsOverTheLimits(t *testing.T) {
+	s := newTestSchema(t, config.GraphQLConfig{MaxDepth: 3, MaxComplexity: 100})
+
+	tests := []struct {
+		query, wantErr string
+	}{
+		{`{ task(id: 1) { parent { parent { id } } } }`, "Query depth 4 exceeds the limit of 3"},
+		{`{ tasks { subtasks { id title } } }`, "Query complexity 211 exceeds the limit of 100"},
+		{`query A { me { id } } query B { me { id } }`, "operationName must name one"},
+		{`{ tasks { id } `, "Syntax Error"},
+	}
+	for _, tt := range tests {
+		result := s.Execute(context.Background(), 1, models.GraphQLRequest{Query: tt.query})
+		if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, tt.wantErr) {
+			t.Errorf("%s: errors = %v, want %q", tt.query, result.Errors, tt.wantErr)
+		}
+		if result.Data != nil {
+			t.Errorf("%s: data = %v, want none", tt.query, result.Data)
+		}
+	}
+}
+
+func TestPrepareAcceptsOperationsWithinTheLimits(t *testing.T) {
+	s := newTestSchema(t, config.GraphQLConfig{MaxDepth: 3, MaxComplexity: 111})
+
+	for _, req := range []models.GraphQLRequest{
+		{Query: `{ tasks { subtasks { id } } }`},
+		{Query: `query A { me { id } } query B { tasks { id } }`, OperationName: "B"},
+	} {
+		if _, _, errs := s.prepare(req); errs != nil {
+			t.Errorf("%s: %v", req.Query, errs)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestLoaderBatchesQueuedKeys(t *testing.T) {
+	var fetches [][]int
+	l := NewLoader(func(keys []int) (map[int]string, error) {
+		fetches = append(fetches, slices.Clone(keys))
+		return map[int]string{1: "one", 2: "two"}, nil
+	})
+
+	one, two, missing, again := l.Load(1), l.Load(2), l.Load(3), l.Load(1)
+	for _, tt := range []struct {
+		load func() (string, error)
+		want string
+	}{{one, "one"}, {two, "two"}, {missing, ""}, {again, "one"}} {
+		if got, err := tt.load(); got != tt.want || err != nil {
+			t.Errorf("got %q, %v; want %q", got, err, tt.want)
+		}
+	}
+	if len(fetches) != 1 || !slices.Equal(fetches[0], []int{1, 2, 3}) {
+		t.Fatalf("fetches = %v, want one of keys 1, 2 and 3", fetches)
+	}
+
+	// Cached keys are not fetched again; new ones are.
+	l.Load(1)()
+	l.Load(4)()
+	if len(fetches) != 2 || !slices.Equal(fetches[1], []int{4}) {
+		t.Errorf("fetches = %v, want a second one of key 4 only", fetches)
+	}
+}
+
+func TestLoaderReportsFetchErrorToEveryKey(t *testing.T) {
+	boom := errors.New("boom")
+	l := NewLoader(func(keys []int) (map[int]string, error) { return nil, boom })
+
+	a, b := l.Load(1), l.Load(2)
+	if _, err := a(); !errors.Is(err, boom) {
+		t.Errorf("key 1: err = %v, want boom", err)
+	}
+	if _, err := b(); !errors.Is(err, boom) {
+		t.Errorf("key 2: err = %v, want boom", err)
+	}
+}
@@ -0,0 +1,583 @@
+// Package graph serves the GraphQL API: a schema over tasks and users whose
+// resolvers reuse the services, per-request loaders that batch the lookups
+// of nested fields, and limits on the depth and cost of queries.
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/events"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+)
+
+// Schema is the GraphQL API over the tasks, labels and projects and the
+// profile of a user.
+type Schema struct {
+	schema   graphql.Schema
+	task     *graphql.Object
+	user     *graphql.Object
+	project  *graphql.Object
+	tasks    *services.TaskService
+	users    *services.AuthService
+	projects *services.ProjectService
+	events   *events.Broker
+	config   config.GraphQLConfig
+}
+
+// NewSchema builds the schema. Subscriptions are fed by broker.
+func NewSchema(tasks *services.TaskService, users *services.AuthService, projects *services.ProjectService, broker *events.Broker, cfg config.GraphQLConfig) (*Schema, error) {
+	s := &Schema{tasks: tasks, users: users, projects: projects, events: broker, config: cfg}
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query:        s.queryType(),
+		Mutation:     s.mutationType(),
+		Subscription: s.subscriptionType(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.schema = schema
+	return s, nil
+}
+
+// int64Type carries versions, which may outgrow GraphQL's 32-bit Int.
+var int64Type = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Int64",
+	Description: "A 64-bit integer, such as a task version.",
+	Serialize: func(value any) any {
+		return value
+	},
+	ParseValue: func(value any) any {
+		switch v := value.(type) {
+		case float64:
+			if v == float64(int64(v)) {
+				return int64(v)
+			}
+		case int:
+			return int64(v)
+		case int64:
+			return v
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				return n
+			}
+		}
+		return nil
+	},
+	ParseLiteral: func(value ast.Value) any {
+		if v, ok := value.(*ast.IntValue); ok {
+			if n, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+				return n
+			}
+		}
+		return nil
+	},
+})
+
+func (s *Schema) taskType() *graphql.Object {
+	if s.task != nil {
+		return s.task
+	}
+	s.task = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Task",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          {Type: graphql.NewNonNull(graphql.Int)},
+				"title":       {Type: graphql.NewNonNull(graphql.String)},
+				"description": {Type: graphql.NewNonNull(graphql.String)},
+				"done":        {Type: graphql.NewNonNull(graphql.Boolean)},
+				"userId":      {Type: graphql.NewNonNull(graphql.Int)},
+				"parentId":    {Type: graphql.Int},
+				"projectId":   {Type: graphql.Int},
+				"version":     {Type: graphql.NewNonNull(int64Type)},
+				"createdAt":   {Type: graphql.NewNonNull(graphql.DateTime)},
+				"updatedAt":   {Type: graphql.NewNonNull(graphql.DateTime)},
+				"parent": {
+					Type:        s.task,
+					Description: "The task this one is a subtask of.",
+					Resolve:     s.resolveParent,
+				},
+				"subtasks": {
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(s.task))),
+					Description: "The direct subtasks, newest first.",
+					Resolve:     s.resolveSubtasks,
+				},
+				"owner": {
+					Type:    graphql.NewNonNull(s.userType()),
+					Resolve: s.resolveOwner,
+				},
+				"labels": {
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(labelType))),
+					Description: "The owner's labels on the task, by name.",
+					Resolve:     s.resolveLabels,
+				},
+				"commentCount": {
+					Type:    graphql.NewNonNull(graphql.Int),
+					Resolve: s.resolveCommentCount,
+				},
+				"project": {
+					Type:        s.projectType(),
+					Description: "The project the task is shared in; null outside a project or one you are not a member of.",
+					Resolve:     s.resolveProject,
+				},
+			}
+		}),
+	})
+	return s.task
+}
+
+var labelType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Label",
+	Fields: graphql.Fields{
+		"id":        {Type: graphql.NewNonNull(graphql.Int)},
+		"name":      {Type: graphql.NewNonNull(graphql.String)},
+		"color":     {Type: graphql.NewNonNull(graphql.String)},
+		"createdAt": {Type: graphql.NewNonNull(graphql.DateTime)},
+	},
+})
+
+func (s *Schema) projectType() *graphql.Object {
+	if s.project != nil {
+		return s.project
+	}
+	s.project = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Project",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        {Type: graphql.NewNonNull(graphql.Int)},
+				"name":      {Type: graphql.NewNonNull(graphql.String)},
+				"ownerId":   {Type: graphql.NewNonNull(graphql.Int)},
+				"createdAt": {Type: graphql.NewNonNull(graphql.DateTime)},
+				"tasks": {
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(s.taskType()))),
+					Description: "The tasks shared in the project by any member, newest first.",
+					Resolve:     s.resolveProjectTasks,
+				},
+			}
+		}),
+	})
+	return s.project
+}
+
+func (s *Schema) userType() *graphql.Object {
+	if s.user != nil {
+		return s.user
+	}
+	s.user = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":            {Type: graphql.NewNonNull(graphql.Int)},
+				"name":          {Type: graphql.NewNonNull(graphql.String)},
+				"email":         {Type: graphql.NewNonNull(graphql.String), Description: "Empty for other users."},
+				"emailVerified": {Type: graphql.NewNonNull(graphql.Boolean)},
+				"createdAt":     {Type: graphql.NewNonNull(graphql.DateTime)},
+				"tasks": {
+					Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(s.taskType()))),
+					Description: "The user's tasks, newest first. Empty for other users.",
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						if id := p.Source.(*models.User).ID; id == userID(p) {
+							return s.tasks.GetTasks(p.Context, id)
+						}
+						return []models.Task{}, nil
+					},
+				},
+			}
+		}),
+	})
+	return s.user
+}
+
+func (s *Schema) queryType() *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": {
+				Type: graphql.NewNonNull(s.userType()),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return s.users.GetUser(p.Context, userID(p))
+				},
+			},
+			"tasks": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(s.taskType()))),
+				Description: "Your tasks, newest first.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return s.tasks.GetTasks(p.Context, userID(p))
+				},
+			},
+			"task": {
+				Type: s.taskType(),
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					task, err := s.tasks.GetTask(p.Context, p.Args["id"].(int), userID(p))
+					if errors.Is(err, services.ErrTaskNotFound) {
+						return nil, nil
+					}
+					return task, err
+				},
+			},
+			"labels": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(labelType))),
+				Description: "Your labels, by name.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return s.tasks.ListLabels(p.Context, userID(p))
+				},
+			},
+			"projects": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(s.projectType()))),
+				Description: "The projects you are a member of, by name.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return s.projects.ListProjects(p.Context, userID(p))
+				},
+			},
+			"project": {
+				Type: s.projectType(),
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					project, err := s.projects.GetProject(p.Context, p.Args["id"].(int), userID(p))
+					if errors.Is(err, services.ErrProjectNotFound) {
+						return nil, nil
+					}
+					return project, err
+				},
+			},
+		},
+	})
+}
+
+func (s *Schema) mutationType() *graphql.Object {
+	createInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreateTaskInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       {Type: graphql.NewNonNull(graphql.String)},
+			"description": {Type: graphql.String},
+			"done":        {Type: graphql.Boolean},
+			"parentId":    {Type: graphql.Int},
+		},
+	})
+	updateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpdateTaskInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       {Type: graphql.String},
+			"description": {Type: graphql.String},
+			"done":        {Type: graphql.Boolean},
+		},
+	})
+	ifVersion := &graphql.ArgumentConfig{
+		Type:        int64Type,
+		Description: "Fail with a version conflict unless the task is still at this version.",
+	}
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createTask": {
+				Type: graphql.NewNonNull(s.taskType()),
+				Args: graphql.FieldConfigArgument{
+					"input": {Type: graphql.NewNonNull(createInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					var req models.CreateTaskRequest
+					if err := decodeInput(p.Args["input"], &req); err != nil {
+						return nil, err
+					}
+					return s.tasks.CreateTask(p.Context, &req, userID(p))
+				},
+			},
+			"updateTask": {
+				Type: graphql.NewNonNull(s.taskType()),
+				Args: graphql.FieldConfigArgument{
+					"id":        {Type: graphql.NewNonNull(graphql.Int)},
+					"input":     {Type: graphql.NewNonNull(updateInput)},
+					"ifVersion": ifVersion,
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					var req models.UpdateTaskRequest
+					if err := decodeInput(p.Args["input"], &req); err != nil {
+						return nil, err
+					}
+					return s.tasks.UpdateTask(p.Context, p.Args["id"].(int), userID(p), &req, versionArg(p))
+				},
+			},
+			"moveTask": {
+				Type:        graphql.NewNonNull(s.taskType()),
+				Description: "Makes the task a subtask of parentId, or a top-level task without it.",
+				Args: graphql.FieldConfigArgument{
+					"id":        {Type: graphql.NewNonNull(graphql.Int)},
+					"parentId":  {Type: graphql.Int},
+					"ifVersion": ifVersion,
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					var parentID *int
+					if id, ok := p.Args["parentId"].(int); ok {
+						parentID = &id
+					}
+					return s.tasks.MoveTask(p.Context, p.Args["id"].(int), userID(p), parentID, versionArg(p))
+				},
+			},
+			"deleteTask": {
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id":        {Type: graphql.NewNonNull(graphql.Int)},
+					"ifVersion": ifVersion,
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if err := s.tasks.DeleteTask(p.Context, p.Args["id"].(int), userID(p), versionArg(p)); err != nil {
+						return nil, err
+					}
+					return true, nil
+				},
+			},
+		},
+	})
+}
+
+func (s *Schema) subscriptionType() *graphql.Object {
+	eventType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "TaskEvent",
+		Description: "A change to one of your tasks.",
+		Fields: graphql.Fields{
+			"type": {
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "task.created, task.updated, task.completed or task.deleted",
+			},
+			"id": {
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, _ := eventTask(p.Source.(events.Event))
+					return id, nil
+				},
+			},
+			"version": {
+				Type: graphql.NewNonNull(int64Type),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					switch data := p.Source.(events.Event).Data.(type) {
+					case *models.Task:
+						return data.Version, nil
+					case models.DeletedTask:
+						return data.Version, nil
+					}
+					return nil, nil
+				},
+			},
+			"time": {Type: graphql.NewNonNull(graphql.DateTime)},
+			"task": {
+				Type:        s.taskType(),
+				Description: "The task after the change; null for deletes.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if task, ok := p.Source.(events.Event).Data.(*models.Task); ok {
+						return task, nil
+					}
+					return nil, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"taskChanged": {
+				Type:        graphql.NewNonNull(eventType),
+				Description: "Your task changes as they happen, optionally of one task only.",
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.Int},
+				},
+				Subscribe: s.subscribeTaskChanged,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					// Every event is a new execution; cached loads from
+					// earlier ones would be stale.
+					resetLoaders(p.Context)
+					return p.Source, nil
+				},
+			},
+		},
+	})
+}
+
+func (s *Schema) subscribeTaskChanged(p graphql.ResolveParams) (any, error) {
+	only, filtered := p.Args["id"].(int)
+	sub, _, _ := s.events.Subscribe(userID(p), "")
+
+	c := make(chan any)
+	go func() {
+		defer close(c)
+		defer sub.Close()
+		for {
+			select {
+			case <-p.Context.Done():
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if id, _ := eventTask(e); filtered && id != only {
+					continue
+				}
+				select {
+				case c <- e:
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}
+	}()
+	return c, nil
+}
+
+func (s *Schema) resolveParent(p graphql.ResolveParams) (any, error) {
+	task := sourceTask(p)
+	if task.ParentID == nil {
+		return nil, nil
+	}
+	load := loadersFrom(p.Context).tasks.Load(*task.ParentID)
+	return func() (any, error) {
+		parent, err := load()
+		if parent == nil || err != nil {
+			return nil, err
+		}
+		return parent, nil
+	}, nil
+}
+
+func (s *Schema) resolveSubtasks(p graphql.ResolveParams) (any, error) {
+	load := loadersFrom(p.Context).subtasks.Load(sourceTask(p).ID)
+	return func() (any, error) {
+		subtasks, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if subtasks == nil {
+			subtasks = []*models.Task{}
+		}
+		return subtasks, nil
+	}, nil
+}
+
+func (s *Schema) resolveOwner(p graphql.ResolveParams) (any, error) {
+	load := loadersFrom(p.Context).users.Load(sourceTask(p).UserID)
+	return func() (any, error) {
+		user, err := load()
+		if user == nil || err != nil {
+			return nil, err
+		}
+		return user, nil
+	}, nil
+}
+
+func (s *Schema) resolveLabels(p graphql.ResolveParams) (any, error) {
+	load := loadersFrom(p.Context).labels.Load(sourceTask(p).ID)
+	return func() (any, error) {
+		labels, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if labels == nil {
+			labels = []models.Label{}
+		}
+		return labels, nil
+	}, nil
+}
+
+func (s *Schema) resolveCommentCount(p graphql.ResolveParams) (any, error) {
+	load := loadersFrom(p.Context).commentCounts.Load(sourceTask(p).ID)
+	return func() (any, error) {
+		return load()
+	}, nil
+}
+
+func (s *Schema) resolveProject(p graphql.ResolveParams) (any, error) {
+	task := sourceTask(p)
+	if task.ProjectID == nil {
+		return nil, nil
+	}
+	load := loadersFrom(p.Context).projects.Load(*task.ProjectID)
+	return func() (any, error) {
+		project, err := load()
+		if project == nil || err != nil {
+			return nil, err
+		}
+		return project, nil
+	}, nil
+}
+
+func (s *Schema) resolveProjectTasks(p graphql.ResolveParams) (any, error) {
+	load := loadersFrom(p.Context).projectTasks.Load(sourceProject(p).ID)
+	return func() (any, error) {
+		tasks, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if tasks == nil {
+			tasks = []*models.Task{}
+		}
+		return tasks, nil
+	}, nil
+}
+
+// eventTask returns the ID of the task an event is about.
+func eventTask(e events.Event) (int, bool) {
+	switch data := e.Data.(type) {
+	case *models.Task:
+		return data.ID, true
+	case models.DeletedTask:
+		return data.ID, true
+	}
+	return 0, false
+}
+
+// decodeInput copies an input object into a request model and validates it
+// like the REST endpoints do.
+func decodeInput(input any, dst any) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return err
+	}
+	return validation.Validate(dst)
+}
+
+func versionArg(p graphql.ResolveParams) *int64 {
+	if v, ok := p.Args["ifVersion"].(int64); ok {
+		return &v
+	}
+	return nil
+}
+
+// sourceTask returns the task whose field is resolved. Lists hold tasks by
+// value, single tasks are pointers.
+func sourceTask(p graphql.ResolveParams) *models.Task {
+	switch t := p.Source.(type) {
+	case *models.Task:
+		return t
+	case models.Task:
+		return &t
+	}
+	return nil
+}
+
+// sourceProject returns the project whose field is resolved, held by value
+// in lists like tasks.
+func sourceProject(p graphql.ResolveParams) *models.Project {
+	switch pr := p.Source.(type) {
+	case *models.Project:
+		return pr
+	case models.Project:
+		return &pr
+	}
+	return nil
+}
@@ -1,171 +1,115 @@
 package handlers
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
-	"strings"
 
 	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
 	"task-manager-server/internal/services"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthHandler struct {
 	authService *services.AuthService
-	taskService *services.TaskService
-	jwtSecret   []byte
 }
 
-func NewAuthHandler(authService *services.AuthService, taskService *services.TaskService) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
-		taskService: taskService,
-		jwtSecret:   []byte("your-secret-key-change-in-production"),
 	}
 }
 
-func writeJSON(w http.ResponseWriter, status int, data any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(data)
-}
-
-func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]string{
-		"message": message,
-	})
-}
-
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
 	var req models.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	user, err := h.authService.Register(&req)
+	user, err := h.authService.Register(r.Context(), &req)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, r, err)
 		return
 	}
 
-	log.Printf("Register: created user=%d email=%s", user.ID, user.Email)
-	writeJSON(w, http.StatusCreated, user)
+	logger(r).Info("user registered", "userId", user.ID)
+	response.JSON(w, http.StatusCreated, user)
 }
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
 	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	response, err := h.authService.Login(&req)
+	auth, err := h.authService.Login(r.Context(), &req, clientIP(r))
 	if err != nil {
-		writeError(w, http.StatusUnauthorized, err.Error())
+		writeError(w, r, err)
 		return
 	}
 
-	log.Printf("Login: user=%d email=%s", response.User.ID, response.User.Email)
-	writeJSON(w, http.StatusOK, response)
-}
-
-func (h *AuthHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
+	if auth.MFARequired {
+		logger(r).Info("MFA challenge issued")
+	} else {
+		logger(r).Info("user logged in", "userId", auth.User.ID)
 	}
+	response.JSON(w, http.StatusOK, auth)
+}
 
-	authHeader := r.Header.Get("Authorization")
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method")
-		}
-		return h.jwtSecret, nil
-	})
-
-	if err != nil || !token.Valid {
-		writeError(w, http.StatusUnauthorized, "Invalid token")
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req models.EmailRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		writeError(w, http.StatusUnauthorized, "Invalid token claims")
+	if err := h.authService.ResendVerification(r.Context(), req.Email); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	userID := int(claims["user_id"].(float64))
+	response.JSON(w, http.StatusAccepted, models.MessageResponse{
+		Message: "If the account exists and is not verified yet, a verification email has been sent",
+	})
+}
 
-	tasks, err := h.taskService.GetTasks(userID)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to get tasks")
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, tasks)
-}
-
-func (h *AuthHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	if err := h.authService.VerifyEmail(r.Context(), req.Token); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	authHeader := r.Header.Get("Authorization")
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method")
-		}
-		return h.jwtSecret, nil
-	})
+	response.JSON(w, http.StatusOK, models.MessageResponse{Message: "Email verified"})
+}
 
-	if err != nil || !token.Valid {
-		writeError(w, http.StatusUnauthorized, "Invalid token")
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.EmailRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		writeError(w, http.StatusUnauthorized, "Invalid token claims")
+	if err := h.authService.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	userID := int(claims["user_id"].(float64))
-
-	var req models.CreateTaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
+	response.JSON(w, http.StatusAccepted, models.MessageResponse{
+		Message: "If the account exists, a password reset email has been sent",
+	})
+}
 
-	if req.Title == "" {
-		writeError(w, http.StatusBadRequest, "Title is required")
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	task, err := h.taskService.CreateTask(&req, userID)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to create task")
+	if err := h.authService.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, task)
+	response.JSON(w, http.StatusOK, models.MessageResponse{Message: "Password updated"})
 }
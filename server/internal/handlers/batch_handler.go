@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+)
+
+// maxBatchOperations bounds the operations of one batch.
+const maxBatchOperations = 100
+
+// Batch runs create, update, delete and move operations in one transaction
+// and reports on each of them.
+func (h *TaskHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.BatchRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > maxBatchOperations {
+		writeError(w, r, validation.Errors{{
+			Field:   "operations",
+			Code:    validation.CodeInvalidLen,
+			Message: fmt.Sprintf("operations must hold between 1 and %d items", maxBatchOperations),
+		}})
+		return
+	}
+	atomic := req.Mode != models.BatchBestEffort
+
+	res := models.BatchResponse{Results: make([]models.BatchResult, len(req.Operations))}
+
+	// Invalid operations never reach the service. In atomic mode one of
+	// them fails the batch up front.
+	var (
+		ops     []services.TaskOperation
+		indexes []int
+	)
+	for i, op := range req.Operations {
+		parsed, err := parseOperation(op)
+		if err != nil {
+			res.Results[i] = models.BatchResult{Status: models.BatchFailed, Error: operationProblem(r, err)}
+			if atomic {
+				markSkipped(res.Results)
+				response.JSON(w, http.StatusOK, res)
+				return
+			}
+			continue
+		}
+		ops = append(ops, parsed)
+		indexes = append(indexes, i)
+	}
+
+	results, committed, err := h.taskService.Batch(r.Context(), userID, ops, atomic)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// In a failed atomic batch the operations before the failing one were
+	// rolled back and those after it never ran.
+	failed := false
+	for j, result := range results {
+		i := indexes[j]
+		switch {
+		case result.Err != nil:
+			failed = true
+			res.Results[i] = models.BatchResult{Status: models.BatchFailed, Error: operationProblem(r, result.Err)}
+		case committed:
+			res.Results[i] = models.BatchResult{Status: models.BatchApplied, Task: result.Task}
+		case !failed:
+			res.Results[i] = models.BatchResult{Status: models.BatchRolledBack}
+		}
+	}
+	markSkipped(res.Results)
+	res.Committed = committed
+
+	logger(r).Info("batch run", "operations", len(req.Operations), "atomic", atomic, "committed", committed)
+	response.JSON(w, http.StatusOK, res)
+}
+
+// parseOperation validates an operation and decodes its task.
+func parseOperation(op models.BatchOperation) (services.TaskOperation, error) {
+	parsed := services.TaskOperation{
+		Op:        op.Op,
+		ID:        op.ID,
+		ParentID:  op.ParentID,
+		IfVersion: op.IfVersion,
+	}
+	if err := validation.Validate(&op); err != nil {
+		return parsed, err
+	}
+
+	switch op.Op {
+	case services.OpCreate:
+		parsed.Create = &models.CreateTaskRequest{}
+		return parsed, decodeChange(op.Task, parsed.Create)
+	case services.OpUpdate:
+		parsed.Update = &models.UpdateTaskRequest{}
+		return parsed, decodeChange(op.Task, parsed.Update)
+	}
+	return parsed, nil
+}
+
+// markSkipped marks the operations that have no result yet.
+func markSkipped(results []models.BatchResult) {
+	for i := range results {
+		if results[i].Status == "" {
+			results[i].Status = models.BatchSkipped
+		}
+	}
+}
+
+// operationProblem describes why a single operation failed.
+func operationProblem(r *http.Request, err error) *response.Problem {
+	p, ok := problemFor(err)
+	if !ok {
+		p = &response.Problem{Status: http.StatusInternalServerError, Detail: "An unexpected error occurred"}
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.TraceID = response.TraceID(r)
+	return p
+}
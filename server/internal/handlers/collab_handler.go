@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"task-manager-server/internal/collab"
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+
+	"github.com/gorilla/websocket"
+)
+
+type CollabHandler struct {
+	hub            collab.Hub
+	taskService    *services.TaskService
+	projectService *services.ProjectService
+	upgrader       websocket.Upgrader
+}
+
+func NewCollabHandler(hub collab.Hub, taskService *services.TaskService, projectService *services.ProjectService) *CollabHandler {
+	return &CollabHandler{
+		hub:            hub,
+		taskService:    taskService,
+		projectService: projectService,
+		upgrader: websocket.Upgrader{
+			// Connections authenticate with a bearer token, not cookies,
+			// so cross-origin pages cannot ride on a user's session.
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Connect upgrades the request to the collaboration WebSocket.
+func (h *CollabHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already answered the request.
+		return
+	}
+
+	conn := collab.NewConn(ws, userID)
+	conn.Run(func(req collab.Request) {
+		h.handle(r, conn, req)
+	})
+
+	for _, topic := range h.hub.Topics(conn) {
+		h.leave(conn, collab.TopicName(topic))
+	}
+}
+
+func (h *CollabHandler) handle(r *http.Request, conn *collab.Conn, req collab.Request) {
+	var (
+		data any
+		err  error
+	)
+
+	switch req.Type {
+	case collab.TypeSubscribe:
+		err = h.subscribe(r.Context(), conn, req.Topic)
+	case collab.TypeUnsubscribe:
+		err = h.unsubscribe(conn, req.Topic)
+	case collab.TypeTyping:
+		err = h.typing(conn, req)
+	case collab.TypeTaskCreate:
+		var body models.CreateTaskRequest
+		if err = decodeMessage(req.Data, &body); err == nil {
+			data, err = h.taskService.CreateTask(r.Context(), &body, conn.UserID())
+		}
+	case collab.TypeTaskUpdate:
+		var body models.UpdateTaskRequest
+		if err = decodeMessage(req.Data, &body); err == nil {
+			data, err = h.taskService.UpdateTask(r.Context(), req.ID, conn.UserID(), &body, nil)
+		}
+	case collab.TypeTaskDelete:
+		if err = h.taskService.DeleteTask(r.Context(), req.ID, conn.UserID(), nil); err == nil {
+			data = models.DeletedTask{ID: req.ID}
+		}
+	default:
+		err = badMessage("Unknown message type " + strconv.Quote(req.Type))
+	}
+
+	if err != nil {
+		conn.Send(collab.Error(req.Ref, messageProblem(r, err)))
+		return
+	}
+	// Acks are only sent to requests that asked for them with a ref.
+	if req.Ref != "" {
+		conn.Send(collab.Ack(req.Ref, data))
+	}
+}
+
+// subscribe joins "tasks", the user's task list, "task:<id>", a task the
+// user owns or shares in a project, or "project:<id>", a project the user
+// is a member of.
+func (h *CollabHandler) subscribe(ctx context.Context, conn *collab.Conn, topic string) error {
+	if err := h.access(ctx, conn.UserID(), topic); err != nil {
+		return err
+	}
+
+	members := h.hub.Join(collab.Topic(conn.UserID(), topic), conn)
+	h.announce(conn.UserID(), topic, members)
+	return nil
+}
+
+// access checks that the user may subscribe to topic.
+func (h *CollabHandler) access(ctx context.Context, userID int, topic string) error {
+	if topic == "tasks" {
+		return nil
+	}
+	kind, idStr, _ := strings.Cut(topic, ":")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		kind = ""
+	}
+
+	switch kind {
+	case "task":
+		_, err = h.taskService.GetSharedTask(ctx, id, userID)
+		return err
+	case "project":
+		return h.projectService.CheckMember(ctx, id, userID)
+	default:
+		return badMessage(`Topics are "tasks", "task:<id>" or "project:<id>"`)
+	}
+}
+
+// Recheck removes the users who lost access to shared topics since they
+// subscribed, e.g. by leaving a project, and sends them an "unsubscribed"
+// message.
+func (h *CollabHandler) Recheck(ctx context.Context, topics ...string) {
+	for _, topic := range topics {
+		checked := map[int]bool{}
+		for _, m := range h.hub.Members(topic) {
+			if checked[m.UserID] {
+				continue
+			}
+			checked[m.UserID] = true
+
+			err := h.access(ctx, m.UserID, topic)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, services.ErrTaskNotFound) && !errors.Is(err, services.ErrProjectNotFound) {
+				logging.FromContext(ctx).Error("collab access recheck failed", "topic", topic, "userId", m.UserID, "err", err)
+				continue
+			}
+			members := h.hub.Evict(topic, m.UserID, collab.Message{Type: collab.TypeUnsubscribed, Topic: topic})
+			h.announce(m.UserID, topic, members)
+		}
+	}
+}
+
+func (h *CollabHandler) unsubscribe(conn *collab.Conn, topic string) error {
+	if !h.joined(conn, topic) {
+		return badMessage("Not subscribed to " + strconv.Quote(topic))
+	}
+	h.leave(conn, topic)
+	return nil
+}
+
+func (h *CollabHandler) typing(conn *collab.Conn, req collab.Request) error {
+	if !h.joined(conn, req.Topic) {
+		return badMessage("Not subscribed to " + strconv.Quote(req.Topic))
+	}
+	from := conn.Member()
+	h.hub.Publish(collab.Topic(conn.UserID(), req.Topic), collab.Message{
+		Type:   collab.TypeTyping,
+		Topic:  req.Topic,
+		From:   &from,
+		Typing: &req.Typing,
+	}, conn)
+	return nil
+}
+
+func (h *CollabHandler) joined(conn *collab.Conn, topic string) bool {
+	return slices.Contains(h.hub.Topics(conn), collab.Topic(conn.UserID(), topic))
+}
+
+func (h *CollabHandler) leave(conn *collab.Conn, topic string) {
+	members := h.hub.Leave(collab.Topic(conn.UserID(), topic), conn)
+	h.announce(conn.UserID(), topic, members)
+}
+
+// announce sends the members of a topic to all of them.
+func (h *CollabHandler) announce(userID int, topic string, members []collab.Member) {
+	h.hub.Publish(collab.Topic(userID, topic), collab.Message{
+		Type:    collab.TypePresence,
+		Topic:   topic,
+		Members: members,
+	}, nil)
+}
+
+// decodeMessage reads the data of a request and validates it.
+func decodeMessage(data json.RawMessage, dst any) error {
+	if err := json.Unmarshal(data, dst); err != nil {
+		return badMessage("Invalid message data")
+	}
+	return validation.Validate(dst)
+}
+
+// badMessage is a malformed request from the client.
+type badMessage string
+
+func (e badMessage) Error() string {
+	return string(e)
+}
+
+// messageProblem describes the failure of a request made over the
+// WebSocket the way writeError would over HTTP.
+func messageProblem(r *http.Request, err error) *response.Problem {
+	p, ok := problemFor(err)
+	if bad, isBad := err.(badMessage); isBad {
+		p, ok = &response.Problem{
+			Type:   response.TypeURI("invalid-message"),
+			Status: http.StatusBadRequest,
+			Detail: string(bad),
+		}, true
+	}
+
+	traceID := response.TraceID(r)
+	if !ok {
+		logger(r).Error("collab message failed", "traceId", traceID, "err", err)
+		p = &response.Problem{
+			Status: http.StatusInternalServerError,
+			Detail: "An unexpected error occurred",
+		}
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = r.URL.Path
+	p.TraceID = traceID
+	return p
+}
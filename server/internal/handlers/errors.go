@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"task-manager-server/internal/patch"
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceErrors maps the errors returned by the services to problem types.
+var serviceErrors = []struct {
+	err    error
+	status int
+	slug   string
+}{
+	{services.ErrTaskNotFound, http.StatusNotFound, "not-found"},
+	{services.ErrUserNotFound, http.StatusNotFound, "not-found"},
+	{services.ErrWebhookNotFound, http.StatusNotFound, "not-found"},
+	{services.ErrDeliveryNotFound, http.StatusNotFound, "not-found"},
+	{services.ErrRevisionNotFound, http.StatusNotFound, "not-found"},
+	{services.ErrProjectNotFound, http.StatusNotFound, "not-found"},
+	{services.ErrLabelNotFound, http.StatusNotFound, "not-found"},
+	{services.ErrLabelTaken, http.StatusConflict, "label-taken"},
+	{services.ErrNotProjectOwner, http.StatusForbidden, "not-project-owner"},
+	{services.ErrOwnerCannotLeave, http.StatusConflict, "owner-cannot-leave"},
+	{services.ErrVersionConflict, http.StatusConflict, "version-conflict"},
+	{services.ErrNothingToUndo, http.StatusConflict, "nothing-to-undo"},
+	{services.ErrNothingToRedo, http.StatusConflict, "nothing-to-redo"},
+	{services.ErrInvalidSyncToken, http.StatusBadRequest, "invalid-sync-token"},
+	{services.ErrParentNotFound, http.StatusUnprocessableEntity, "invalid-parent"},
+	{services.ErrTaskCycle, http.StatusUnprocessableEntity, "invalid-parent"},
+	{services.ErrTaskTooDeep, http.StatusUnprocessableEntity, "invalid-parent"},
+	{services.ErrEmailTaken, http.StatusConflict, "email-taken"},
+	{services.ErrInvalidCredentials, http.StatusUnauthorized, "invalid-credentials"},
+	{services.ErrInvalidPassword, http.StatusUnauthorized, "invalid-credentials"},
+	{services.ErrEmailNotVerified, http.StatusForbidden, "email-not-verified"},
+	{services.ErrInvalidToken, http.StatusUnauthorized, "invalid-token"},
+	{services.ErrUnlockTargetMissing, http.StatusBadRequest, "invalid-request"},
+	{services.ErrMFACodeRequired, http.StatusBadRequest, "mfa-code-required"},
+	{services.ErrInvalidMFACode, http.StatusUnauthorized, "invalid-mfa-code"},
+	{services.ErrMFAAlreadyEnabled, http.StatusConflict, "mfa-already-enabled"},
+	{services.ErrMFANotEnabled, http.StatusConflict, "mfa-not-enabled"},
+	{services.ErrMFASetupNotStarted, http.StatusConflict, "mfa-setup-not-started"},
+}
+
+// patchErrors maps the errors of applying a patch. Their messages say which
+// operation failed, so they are used as the detail.
+var patchErrors = []struct {
+	err    error
+	status int
+	slug   string
+}{
+	{patch.ErrInvalid, http.StatusBadRequest, "invalid-patch"},
+	{patch.ErrTestFailed, http.StatusConflict, "patch-test-failed"},
+	{patch.ErrUnprocessable, http.StatusUnprocessableEntity, "unprocessable-patch"},
+}
+
+// writeError turns an error returned by a service into a problem response.
+// Errors the services do not declare are logged and reported as a generic
+// 500 so internal details never reach the client.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var locked *services.LockedError
+	if errors.As(err, &locked) {
+		seconds := int(math.Ceil(locked.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+
+	p, ok := problemFor(err)
+	if !ok {
+		traceID := response.TraceID(r)
+		r = r.WithContext(response.WithTraceID(r.Context(), traceID))
+		logger(r).Error("unexpected error", "traceId", traceID, "err", err)
+		span := trace.SpanFromContext(r.Context())
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unexpected error")
+		response.Error(w, r, http.StatusInternalServerError, "An unexpected error occurred")
+		return
+	}
+	response.WriteProblem(w, r, p)
+}
+
+// problemFor describes an error returned by a service. ok is false for
+// errors the services do not declare.
+func problemFor(err error) (p *response.Problem, ok bool) {
+	var locked *services.LockedError
+	if errors.As(err, &locked) {
+		return &response.Problem{
+			Type:   response.TypeURI("account-locked"),
+			Title:  "Too many failed login attempts",
+			Status: http.StatusTooManyRequests,
+			Detail: locked.Error(),
+		}, true
+	}
+
+	var invalid validation.Errors
+	if errors.As(err, &invalid) {
+		return validationProblem(invalid), true
+	}
+
+	for _, e := range patchErrors {
+		if errors.Is(err, e.err) {
+			return &response.Problem{
+				Type:   response.TypeURI(e.slug),
+				Status: e.status,
+				Detail: err.Error(),
+			}, true
+		}
+	}
+
+	for _, e := range serviceErrors {
+		if errors.Is(err, e.err) {
+			return &response.Problem{
+				Type:   response.TypeURI(e.slug),
+				Status: e.status,
+				Detail: e.err.Error(),
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// validationProblem lists every invalid field. Detail repeats the first one
+// so clients that only show a single message still get a useful one.
+func validationProblem(errs validation.Errors) *response.Problem {
+	return &response.Problem{
+		Type:   response.TypeURI("validation-error"),
+		Title:  "Validation failed",
+		Status: http.StatusUnprocessableEntity,
+		Detail: errs[0].Message,
+		Errors: errs,
+	}
+}
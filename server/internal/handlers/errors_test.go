@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+)
+
+// problem is a decoded problem response.
+type problem struct {
+	Type     string                  `json:"type"`
+	Title    string                  `json:"title"`
+	Status   int                     `json:"status"`
+	Detail   string                  `json:"detail"`
+	Instance string                  `json:"instance"`
+	TraceID  string                  `json:"traceId"`
+	Errors   []validation.FieldError `json:"errors"`
+}
+
+func writeErrorFor(t *testing.T, err error) (*httptest.ResponseRecorder, problem) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/tasks/3", nil)
+	r = r.WithContext(response.WithTraceID(r.Context(), "trace-1"))
+	w := httptest.NewRecorder()
+	writeError(w, r, err)
+
+	if ct := w.Header().Get("Content-Type"); ct != response.ProblemContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, response.ProblemContentType)
+	}
+	var p problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	if p.Status != w.Code {
+		t.Errorf("status member %d, response status %d", p.Status, w.Code)
+	}
+	if p.Instance != "/api/v1/tasks/3" || p.TraceID != "trace-1" {
+		t.Errorf("instance %q, traceId %q; want the request path and trace-1", p.Instance, p.TraceID)
+	}
+	return w, p
+}
+
+func TestWriteErrorMapsServiceErrors(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantType   string
+	}{
+		{services.ErrTaskNotFound, http.StatusNotFound, "/problems/not-found"},
+		{fmt.Errorf("updating task 3: %w", services.ErrVersionConflict), http.StatusConflict, "/problems/version-conflict"},
+		{services.ErrTaskCycle, http.StatusUnprocessableEntity, "/problems/invalid-parent"},
+		{services.ErrInvalidCredentials, http.StatusUnauthorized, "/problems/invalid-credentials"},
+	}
+	for _, tt := range tests {
+		w, p := writeErrorFor(t, tt.err)
+		if w.Code != tt.wantStatus || p.Type != tt.wantType {
+			t.Errorf("%v: %d %s, want %d %s", tt.err, w.Code, p.Type, tt.wantStatus, tt.wantType)
+		}
+		if p.Title == "" || p.Detail == "" {
+			t.Errorf("%v: problem %+v has no title or detail", tt.err, p)
+		}
+	}
+}
+
+func TestWriteErrorListsInvalidFields(t *testing.T) {
+	errs := validation.Errors{
+		{Field: "title", Code: validation.CodeRequired, Message: "title is required"},
+		{Field: "description", Code: validation.CodeTooLong, Message: "description must be at most 65535 bytes"},
+	}
+	w, p := writeErrorFor(t, errs)
+
+	if w.Code != http.StatusUnprocessableEntity || p.Type != "/problems/validation-error" {
+		t.Fatalf("got %d %s, want 422 /problems/validation-error", w.Code, p.Type)
+	}
+	if p.Detail != "title is required" {
+		t.Errorf("detail = %q, want the first message", p.Detail)
+	}
+	if len(p.Errors) != 2 || p.Errors[1].Field != "description" || p.Errors[1].Code != validation.CodeTooLong {
+		t.Errorf("errors = %+v, want both fields", p.Errors)
+	}
+}
+
+func TestWriteErrorLockedSetsRetryAfter(t *testing.T) {
+	w, p := writeErrorFor(t, &services.LockedError{RetryAfter: 1500 * time.Millisecond})
+
+	if w.Code != http.StatusTooManyRequests || p.Type != "/problems/account-locked" {
+		t.Fatalf("got %d %s, want 429 /problems/account-locked", w.Code, p.Type)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+}
+
+// Undeclared errors may carry internals such as SQL, so only a generic
+// detail leaves the server.
+func TestWriteErrorHidesUnexpectedErrors(t *testing.T) {
+	w, p := writeErrorFor(t, errors.New("dial tcp 10.0.0.7:3306: connection refused"))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "3306") || p.Detail != "An unexpected error occurred" {
+		t.Errorf("the response leaks the error: %s", w.Body)
+	}
+	if p.Type != "about:blank" || p.Title != "Internal Server Error" {
+		t.Errorf("type %q, title %q; want about:blank and the status text", p.Type, p.Title)
+	}
+}
+
+func TestDecodeJSONRejectsMalformedAndInvalidBodies(t *testing.T) {
+	tests := []struct {
+		body       string
+		wantStatus int
+	}{
+		{`{"email":`, http.StatusBadRequest},
+		{`{"email": "not-an-address", "password": "s3cretpass"}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		var dst struct {
+			Email    string `json:"email" validate:"required,email"`
+			Password string `json:"password" validate:"required"`
+		}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(tt.body))
+		if decodeJSON(w, r, &dst) {
+			t.Errorf("%s: decodeJSON accepted the body", tt.body)
+		}
+		if w.Code != tt.wantStatus || w.Header().Get("Content-Type") != response.ProblemContentType {
+			t.Errorf("%s: %d %s, want a %d problem", tt.body, w.Code, w.Header().Get("Content-Type"), tt.wantStatus)
+		}
+	}
+}
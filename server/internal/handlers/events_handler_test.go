@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"task-manager-server/internal/events"
+	"task-manager-server/internal/middleware"
+)
+
+// stream runs Stream for user 1 until it has sent what it had buffered.
+func stream(broker *events.Broker, lastEventID string) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), middleware.UserIDKey, 1))
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil).WithContext(ctx)
+	if lastEventID != "" {
+		r.Header.Set("Last-Event-ID", lastEventID)
+	}
+	w := httptest.NewRecorder()
+	NewEventsHandler(broker).Stream(w, r)
+	return w
+}
+
+func TestStreamReplaysFromLastEventID(t *testing.T) {
+	broker := events.NewBroker(10)
+	seen := broker.Publish(1, events.TaskCreated, map[string]int{"id": 3})
+	missed := broker.Publish(1, events.TaskUpdated, map[string]int{"id": 3})
+
+	w := stream(broker, seen.ID)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	body := w.Body.String()
+	want := "id: " + missed.ID + "\nevent: task.updated\ndata: {\"id\":\"" + missed.ID + "\",\"type\":\"task.updated\",\"data\":{\"id\":3},"
+	if !strings.Contains(body, want) {
+		t.Errorf("body = %q, want the missed event", body)
+	}
+	if strings.Contains(body, "id: "+seen.ID+"\n") || strings.Contains(body, "event: reset") {
+		t.Errorf("body = %q, want only the missed event", body)
+	}
+}
+
+func TestStreamResetsWhenEventsWereEvicted(t *testing.T) {
+	broker := events.NewBroker(1)
+	seen := broker.Publish(1, events.TaskCreated, nil)
+	broker.Publish(1, events.TaskUpdated, nil)
+	last := broker.Publish(1, events.TaskDeleted, nil)
+
+	body := stream(broker, seen.ID).Body.String()
+
+	reset := strings.Index(body, "event: reset\n")
+	replayed := strings.Index(body, "id: "+last.ID+"\n")
+	if reset < 0 || replayed < reset {
+		t.Errorf("body = %q, want a reset followed by the buffered event", body)
+	}
+}
+
+func TestStreamRequiresUser(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewEventsHandler(events.NewBroker(1)).Stream(w, httptest.NewRequest(http.MethodGet, "/api/v1/events", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want 401", w.Code)
+	}
+}
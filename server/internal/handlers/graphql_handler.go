@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+
+	"task-manager-server/internal/graph"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/validation"
+)
+
+// graphqlProtocol is the WebSocket subprotocol of GraphQL subscriptions,
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const graphqlProtocol = "graphql-transport-ws"
+
+const (
+	// connectionInitWait is how long a client has to send connection_init.
+	connectionInitWait = 10 * time.Second
+	// graphqlPongWait is how long a client may stay silent before it is
+	// dropped; graphqlPingPeriod must be shorter.
+	graphqlPongWait   = 60 * time.Second
+	graphqlPingPeriod = 50 * time.Second
+	graphqlWriteWait  = 10 * time.Second
+	// graphqlMaxMessageSize bounds a subscribe message and its variables.
+	graphqlMaxMessageSize = 1 << 20
+)
+
+// Close codes of the graphql-transport-ws protocol.
+const (
+	closeBadRequest          = 4400
+	closeUnauthorized        = 4401
+	closeInitTimeout         = 4408
+	closeSubscriberExists    = 4409
+	closeTooManyInitRequests = 4429
+)
+
+type GraphQLHandler struct {
+	schema   *graph.Schema
+	upgrader websocket.Upgrader
+}
+
+func NewGraphQLHandler(schema *graph.Schema) *GraphQLHandler {
+	return &GraphQLHandler{
+		schema: schema,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{graphqlProtocol},
+			// Connections authenticate with a bearer token, not cookies,
+			// so cross-origin pages cannot ride on a user's session.
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Query runs a query or mutation. Like any GraphQL server it answers 200
+// when the request could be executed, with errors listed in the body.
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.GraphQLRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	response.JSON(w, http.StatusOK, graphqlResponse(r, h.schema.Execute(r.Context(), userID, req)))
+}
+
+// Subscribe upgrades the request to a WebSocket speaking the
+// graphql-transport-ws protocol. Any operation can be run over it;
+// subscriptions stream a result per task event until completed.
+func (h *GraphQLHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already answered the request.
+		return
+	}
+	defer ws.Close()
+
+	conn := &graphqlConn{
+		handler: h,
+		r:       r,
+		userID:  userID,
+		ws:      ws,
+		subs:    make(map[string]*graphqlSubscription),
+	}
+	if ws.Subprotocol() != graphqlProtocol {
+		conn.close(websocket.CloseProtocolError, "Use the "+graphqlProtocol+" subprotocol")
+		return
+	}
+	conn.serve()
+}
+
+// graphqlConn is one subscriptions WebSocket.
+type graphqlConn struct {
+	handler *GraphQLHandler
+	r       *http.Request
+	userID  int
+	ws      *websocket.Conn
+
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	subs   map[string]*graphqlSubscription
+	acked  bool
+	closed bool
+}
+
+type graphqlSubscription struct {
+	cancel context.CancelFunc
+}
+
+// graphqlMessage is a message of the protocol in either direction.
+type graphqlMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func (c *graphqlConn) serve() {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(c.r.Context())
+	defer cancel()
+
+	c.ws.SetReadLimit(graphqlMaxMessageSize)
+	_ = c.ws.SetReadDeadline(time.Now().Add(connectionInitWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(graphqlPongWait))
+	})
+	go c.ping(ctx)
+
+	for {
+		_, data, err := c.ws.ReadMessage()
+		if err != nil {
+			var netErr interface{ Timeout() bool }
+			if errors.As(err, &netErr) && netErr.Timeout() && !c.isAcked() {
+				c.close(closeInitTimeout, "Connection initialisation timeout")
+			}
+			return
+		}
+
+		var msg graphqlMessage
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+			c.close(closeBadRequest, "Messages must be JSON objects with a type")
+			return
+		}
+
+		switch msg.Type {
+		case "connection_init":
+			if c.isAcked() {
+				c.close(closeTooManyInitRequests, "Too many initialisation requests")
+				return
+			}
+			c.mu.Lock()
+			c.acked = true
+			c.mu.Unlock()
+			_ = c.ws.SetReadDeadline(time.Now().Add(graphqlPongWait))
+			c.send(graphqlMessage{Type: "connection_ack"})
+		case "ping":
+			c.send(graphqlMessage{Type: "pong"})
+		case "pong":
+		case "subscribe":
+			if !c.isAcked() {
+				c.close(closeUnauthorized, "Unauthorized")
+				return
+			}
+			var req models.GraphQLRequest
+			if msg.ID == "" || json.Unmarshal(msg.Payload, &req) != nil || validation.Validate(&req) != nil {
+				c.close(closeBadRequest, "subscribe needs an id and a payload with a query")
+				return
+			}
+
+			c.mu.Lock()
+			if _, exists := c.subs[msg.ID]; exists {
+				c.mu.Unlock()
+				c.close(closeSubscriberExists, "Subscriber for "+msg.ID+" already exists")
+				return
+			}
+			subCtx, stop := context.WithCancel(ctx)
+			sub := &graphqlSubscription{cancel: stop}
+			c.subs[msg.ID] = sub
+			c.mu.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.run(subCtx, msg.ID, sub, req)
+			}()
+		case "complete":
+			c.mu.Lock()
+			if sub, ok := c.subs[msg.ID]; ok {
+				sub.cancel()
+				delete(c.subs, msg.ID)
+			}
+			c.mu.Unlock()
+		default:
+			c.close(closeBadRequest, "Unexpected message type "+msg.Type)
+			return
+		}
+	}
+}
+
+// run executes the operation of a subscribe message, sending its results
+// until it ends or the client completes it.
+func (c *graphqlConn) run(ctx context.Context, id string, sub *graphqlSubscription, req models.GraphQLRequest) {
+	failed := false
+	for result := range c.handler.schema.Subscribe(ctx, c.userID, req) {
+		// Results are drained after a cancel so the executor can stop.
+		if ctx.Err() != nil {
+			continue
+		}
+		resp := graphqlResponse(c.r, result)
+		if resp.Data == nil && len(resp.Errors) > 0 {
+			// The operation could not be executed at all.
+			payload, _ := json.Marshal(resp.Errors)
+			c.send(graphqlMessage{ID: id, Type: "error", Payload: payload})
+			failed = true
+			continue
+		}
+		payload, _ := json.Marshal(resp)
+		c.send(graphqlMessage{ID: id, Type: "next", Payload: payload})
+	}
+
+	c.mu.Lock()
+	current := c.subs[id] == sub
+	if current {
+		delete(c.subs, id)
+	}
+	c.mu.Unlock()
+	sub.cancel()
+
+	// A client that completed the operation itself expects nothing more.
+	if current && !failed {
+		c.send(graphqlMessage{ID: id, Type: "complete"})
+	}
+}
+
+// ping keeps the connection alive through proxies and detects clients that
+// went away without closing it.
+func (c *graphqlConn) ping(ctx context.Context) {
+	ticker := time.NewTicker(graphqlPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(graphqlWriteWait))
+			c.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *graphqlConn) isAcked() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.acked
+}
+
+func (c *graphqlConn) send(msg graphqlMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.ws.SetWriteDeadline(time.Now().Add(graphqlWriteWait))
+	if err := c.ws.WriteJSON(msg); err != nil {
+		// The read loop sees the broken connection and ends it.
+		_ = c.ws.Close()
+	}
+}
+
+// close ends the connection with a close frame. The first call wins.
+func (c *graphqlConn) close(code int, reason string) {
+	c.mu.Lock()
+	closed := c.closed
+	c.closed = true
+	c.mu.Unlock()
+	if closed {
+		return
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(graphqlWriteWait))
+}
+
+// graphqlResponse converts a result for the client. Errors returned by the
+// services are described like the problem responses of the REST endpoints;
+// unexpected ones are logged and replaced with a generic message so
+// internal details never reach the client.
+func graphqlResponse(r *http.Request, result *graphql.Result) models.GraphQLResponse {
+	var resp models.GraphQLResponse
+	if result.Data != nil {
+		resp.Data, _ = json.Marshal(result.Data)
+	}
+
+	for _, e := range result.Errors {
+		out := models.GraphQLError{Message: e.Message, Path: e.Path}
+		for _, loc := range e.Locations {
+			out.Locations = append(out.Locations, models.GraphQLLocation{Line: loc.Line, Column: loc.Column})
+		}
+
+		// Errors without a path come from parsing, validating or
+		// limiting the request; their messages are meant for clients.
+		if len(e.Path) > 0 {
+			cause := resolverError(e)
+			if p, ok := problemFor(cause); ok {
+				out.Message = p.Detail
+				out.Extensions = map[string]any{
+					"code":   strings.TrimPrefix(p.Type, response.TypeURI("")),
+					"status": p.Status,
+				}
+				if p.Errors != nil {
+					out.Extensions["errors"] = p.Errors
+				}
+			} else if _, own := cause.(*gqlerrors.Error); !own {
+				// Errors the executor raises itself, such as a null
+				// for a non-null field, keep their message.
+				traceID := response.TraceID(r)
+				logger(r).Error("GraphQL resolver failed", "traceId", traceID, "err", cause)
+				out.Message = "An unexpected error occurred"
+				out.Extensions = map[string]any{"status": http.StatusInternalServerError, "traceId": traceID}
+			}
+		}
+		resp.Errors = append(resp.Errors, out)
+	}
+	return resp
+}
+
+// resolverError unwraps the error a resolver returned from the layers the
+// executor adds around it.
+func resolverError(e gqlerrors.FormattedError) error {
+	err := e.OriginalError()
+	for err != nil {
+		switch wrapped := err.(type) {
+		case *gqlerrors.Error:
+			if wrapped.OriginalError == nil {
+				return wrapped
+			}
+			err = wrapped.OriginalError
+		case gqlerrors.FormattedError:
+			if wrapped.OriginalError() == nil {
+				return wrapped
+			}
+			err = wrapped.OriginalError()
+		default:
+			return err
+		}
+	}
+	return err
+}
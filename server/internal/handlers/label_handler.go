@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
+)
+
+func (h *TaskHandler) ListLabels(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	labels, err := h.taskService.ListLabels(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, labels)
+}
+
+func (h *TaskHandler) CreateLabel(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateLabelRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	label, err := h.taskService.CreateLabel(r.Context(), &req, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("label created", "labelId", label.ID)
+	response.JSON(w, http.StatusCreated, label)
+}
+
+func (h *TaskHandler) DeleteLabel(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid label ID")
+		return
+	}
+
+	if err := h.taskService.DeleteLabel(r.Context(), id, userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("label deleted", "labelId", id)
+	response.JSON(w, http.StatusOK, models.MessageResponse{Message: "Label deleted successfully"})
+}
+
+// SetTaskLabels replaces the labels of a task.
+func (h *TaskHandler) SetTaskLabels(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := h.extractTaskID(r)
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	var req models.SetTaskLabelsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	labels, err := h.taskService.SetTaskLabels(r.Context(), id, userID, req.LabelIDs)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("task labels set", "taskId", id, "count", len(labels))
+	response.JSON(w, http.StatusOK, labels)
+}
+
+func (h *TaskHandler) ListComments(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := h.extractTaskID(r)
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	comments, err := h.taskService.ListComments(r.Context(), id, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, comments)
+}
+
+func (h *TaskHandler) AddComment(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := h.extractTaskID(r)
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	var req models.CreateCommentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	comment, err := h.taskService.AddComment(r.Context(), id, userID, &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("comment added", "taskId", id, "commentId", comment.ID)
+	response.JSON(w, http.StatusCreated, comment)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
+)
+
+func (h *AuthHandler) RequestUnlock(w http.ResponseWriter, r *http.Request) {
+	var req models.EmailRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.authService.RequestUnlock(r.Context(), req.Email, clientIP(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusAccepted, models.MessageResponse{
+		Message: "If the account exists, an unlock email has been sent",
+	})
+}
+
+func (h *AuthHandler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	var req models.UnlockAccountRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.authService.UnlockAccount(r.Context(), req.Token); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, models.MessageResponse{Message: "Account unlocked"})
+}
+
+func (h *AuthHandler) AdminUnlock(w http.ResponseWriter, r *http.Request) {
+	var req models.AdminUnlockRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.authService.AdminUnlock(r.Context(), getUserIDFromContext(r), &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("lockout cleared", "email", req.Email, "ip", req.IP)
+	response.JSON(w, http.StatusOK, models.MessageResponse{Message: "Lockout cleared"})
+}
+
+// clientIP returns the address of the peer. Proxy headers are ignored on
+// purpose: they are set by the client and would let an attacker pick the IP
+// the lockout is counted against.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
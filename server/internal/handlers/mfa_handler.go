@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
+)
+
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	auth, err := h.authService.LoginMFA(r.Context(), &req, clientIP(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("user logged in with MFA", "userId", auth.User.ID)
+	response.JSON(w, http.StatusOK, auth)
+}
+
+func (h *AuthHandler) SetupTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	setup, err := h.authService.SetupTOTP(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, setup)
+}
+
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.TOTPConfirmRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	codes, err := h.authService.ConfirmTOTP(r.Context(), userID, req.Code)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("2FA enabled")
+	response.JSON(w, http.StatusOK, codes)
+}
+
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.MFAReauthRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.authService.DisableTOTP(r.Context(), userID, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("2FA disabled")
+	response.JSON(w, http.StatusOK, models.MessageResponse{Message: "Two-factor authentication disabled"})
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.MFAReauthRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(r.Context(), userID, &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, codes)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/patch"
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/validation"
+)
+
+// maxPatchBody bounds the size of a patch document.
+const maxPatchBody = 1 << 20
+
+// acceptPatch lists the patch formats PatchTask understands.
+const acceptPatch = patch.MergePatchType + ", " + patch.JSONPatchType
+
+// readOnlyTaskFields are members of the task document a patch may not
+// change. The project is set with PUT /tasks/{id}/project.
+var readOnlyTaskFields = []string{"id", "userId", "projectId", "version", "createdAt", "updatedAt"}
+
+// PatchTask applies a JSON Merge Patch or a JSON Patch, chosen by the
+// Content-Type, to the task document. The patched document is validated
+// like a new task before it is stored.
+func (h *TaskHandler) PatchTask(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := h.extractTaskID(r)
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	var apply func(doc, p []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchType:
+		apply = patch.Merge
+	case patch.JSONPatchType:
+		apply = patch.Apply
+	default:
+		w.Header().Set("Accept-Patch", acceptPatch)
+		response.Error(w, r, http.StatusUnsupportedMediaType, "Content-Type must be one of "+acceptPatch)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPatchBody+1))
+	if err != nil {
+		response.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(body) > maxPatchBody {
+		response.Error(w, r, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+
+	task, err := h.taskService.PatchTask(r.Context(), id, userID, func(task *models.Task) error {
+		return patchTask(task, apply, body)
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("task patched", "taskId", id, "mediaType", mediaType, "version", task.Version)
+	response.JSON(w, http.StatusOK, task)
+}
+
+// patchTask applies body to the JSON document of task and copies the
+// patched writable fields back.
+func patchTask(task *models.Task, apply func(doc, p []byte) ([]byte, error), body []byte) error {
+	doc, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	out, err := apply(doc, body)
+	if err != nil {
+		return err
+	}
+
+	var before, after map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &before); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(out, &after); err != nil {
+		return fmt.Errorf("%w: the task must remain an object", patch.ErrUnprocessable)
+	}
+
+	var errs validation.Errors
+	for _, name := range slices.Sorted(maps.Keys(after)) {
+		if _, ok := before[name]; !ok {
+			errs = append(errs, validation.FieldError{
+				Field: name, Code: validation.CodeInvalidValue, Message: fmt.Sprintf("%s is not a task field", name),
+			})
+		}
+	}
+	for _, name := range readOnlyTaskFields {
+		if !bytes.Equal(before[name], after[name]) {
+			errs = append(errs, validation.FieldError{
+				Field: name, Code: validation.CodeInvalidValue, Message: fmt.Sprintf("%s is read-only", name),
+			})
+		}
+	}
+	// Removing these would silently reset them.
+	for _, name := range []string{"title", "done"} {
+		if v, ok := after[name]; !ok || string(v) == "null" {
+			errs = append(errs, validation.FieldError{
+				Field: name, Code: validation.CodeRequired, Message: fmt.Sprintf("%s is required", name),
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// The writable fields follow the rules for new tasks.
+	var fields models.CreateTaskRequest
+	if err := json.Unmarshal(out, &fields); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return validation.Errors{{
+				Field: typeErr.Field, Code: validation.CodeInvalidValue, Message: fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type),
+			}}
+		}
+		return err
+	}
+	if err := validation.Validate(&fields); err != nil {
+		return err
+	}
+
+	task.Title = fields.Title
+	task.Description = fields.Description
+	task.Done = fields.Done
+	task.ParentID = fields.ParentID
+	return nil
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/services"
+)
+
+type ProjectHandler struct {
+	projectService *services.ProjectService
+	taskService    *services.TaskService
+	collab         *CollabHandler
+}
+
+// NewProjectHandler creates the handler. Changes that take access away
+// from users are rechecked on the collaboration topics of collab.
+func NewProjectHandler(projectService *services.ProjectService, taskService *services.TaskService, collab *CollabHandler) *ProjectHandler {
+	return &ProjectHandler{
+		projectService: projectService,
+		taskService:    taskService,
+		collab:         collab,
+	}
+}
+
+func (h *ProjectHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	projects, err := h.projectService.ListProjects(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, projects)
+}
+
+func (h *ProjectHandler) GetProject(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid project ID")
+		return
+	}
+
+	project, err := h.projectService.GetProject(r.Context(), id, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, project)
+}
+
+func (h *ProjectHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateProjectRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	project, err := h.projectService.CreateProject(r.Context(), &req, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("project created", "projectId", project.ID)
+	response.JSON(w, http.StatusCreated, project)
+}
+
+func (h *ProjectHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid project ID")
+		return
+	}
+
+	var req models.AddProjectMemberRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	member, err := h.projectService.AddMember(r.Context(), id, userID, req.Email)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("project member added", "projectId", id, "memberId", member.UserID)
+	response.JSON(w, http.StatusOK, member)
+}
+
+// RemoveMember takes a member out of a project. The member is also dropped
+// from the collaboration topics of the project and of the tasks in it that
+// they do not own.
+func (h *ProjectHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	memberID := pathID(r, "userId")
+	if id == -1 || memberID == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid project or user ID")
+		return
+	}
+
+	if err := h.projectService.RemoveMember(r.Context(), id, userID, memberID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	topics := []string{"project:" + strconv.Itoa(id)}
+	taskIDs, err := h.projectService.TaskIDs(r.Context(), id)
+	if err != nil {
+		logger(r).Error("listing project tasks failed", "projectId", id, "err", err)
+	}
+	for _, taskID := range taskIDs {
+		topics = append(topics, "task:"+strconv.Itoa(taskID))
+	}
+	h.collab.Recheck(r.Context(), topics...)
+
+	logger(r).Info("project member removed", "projectId", id, "memberId", memberID)
+	response.JSON(w, http.StatusOK, models.MessageResponse{Message: "Member removed"})
+}
+
+func (h *ProjectHandler) ProjectTasks(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid project ID")
+		return
+	}
+
+	tasks, err := h.projectService.ProjectTasks(r.Context(), id, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, tasks)
+}
+
+// SetTaskProject moves one of the user's tasks into a project or out of it.
+// Members of the former project are dropped from the task's topic.
+func (h *ProjectHandler) SetTaskProject(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	var req models.SetTaskProjectRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	task, err := h.taskService.SetTaskProject(r.Context(), id, userID, req.ProjectID, nil)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.collab.Recheck(r.Context(), "task:"+strconv.Itoa(id))
+
+	logger(r).Info("task project set", "taskId", id, "shared", task.ProjectID != nil)
+	response.JSON(w, http.StatusOK, task)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/validation"
+)
+
+// decodeJSON reads the request body into dst and validates it. On failure it
+// writes the error response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		response.Error(w, r, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+
+	if err := validation.Validate(dst); err != nil {
+		writeError(w, r, err)
+		return false
+	}
+
+	return true
+}
+
+// logger returns the request-scoped logger, which carries the request ID
+// and, once authenticated, the user ID.
+func logger(r *http.Request) *slog.Logger {
+	return logging.FromContext(r.Context())
+}
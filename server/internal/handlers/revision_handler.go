@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"task-manager-server/internal/response"
+)
+
+// Revisions lists a page of the revisions of a task, newest first, with
+// what each one changed. The before and limit query parameters select the
+// page.
+func (h *TaskHandler) Revisions(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := h.extractTaskID(r)
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID")
+		return
+	}
+
+	var before, limit int
+	if s := r.URL.Query().Get("before"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			response.Error(w, r, http.StatusBadRequest, "before must be a revision number")
+			return
+		}
+		before = n
+	}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			response.Error(w, r, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	revisions, err := h.taskService.TaskRevisions(r.Context(), id, userID, before, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, revisions)
+}
+
+// RevertTask restores a task to one of its revisions.
+func (h *TaskHandler) RevertTask(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, rev := h.extractTaskID(r), pathID(r, "rev")
+	if id == -1 || rev == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID or revision")
+		return
+	}
+
+	task, err := h.taskService.RevertTask(r.Context(), id, userID, rev, nil)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("task reverted", "taskId", id, "revision", rev)
+	response.JSON(w, http.StatusOK, task)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+)
+
+// maxSyncChanges bounds the mutations one POST /sync may carry.
+const maxSyncChanges = 100
+
+type SyncHandler struct {
+	taskService *services.TaskService
+}
+
+func NewSyncHandler(taskService *services.TaskService) *SyncHandler {
+	return &SyncHandler{
+		taskService: taskService,
+	}
+}
+
+// Changes returns the tasks changed and deleted since the token in the since
+// query parameter; without one it returns every task.
+func (h *SyncHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var since int64
+	if token := r.URL.Query().Get("since"); token != "" {
+		var err error
+		if since, err = strconv.ParseInt(token, 10, 64); err != nil {
+			writeError(w, r, services.ErrInvalidSyncToken)
+			return
+		}
+	}
+
+	changes, err := h.taskService.Changes(r.Context(), userID, since)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, changes)
+}
+
+// Apply applies mutations made offline, in order. Each one succeeds or fails
+// on its own; the results say which.
+func (h *SyncHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.SyncRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if len(req.Changes) > maxSyncChanges {
+		writeError(w, r, validation.Errors{{
+			Field:   "changes",
+			Code:    validation.CodeTooLong,
+			Message: fmt.Sprintf("changes must hold at most %d items", maxSyncChanges),
+		}})
+		return
+	}
+
+	results := make([]models.SyncResult, len(req.Changes))
+	for i, change := range req.Changes {
+		results[i] = h.apply(r, userID, change)
+	}
+
+	applied := 0
+	for _, res := range results {
+		if res.Status == models.SyncApplied {
+			applied++
+		}
+	}
+	logger(r).Info("sync changes applied", "applied", applied, "changes", len(results))
+
+	response.JSON(w, http.StatusOK, models.SyncApplyResponse{Results: results})
+}
+
+// apply applies one change. Unexpected errors are logged and reported as
+// an error result, so the results of the other changes still reach the
+// client.
+func (h *SyncHandler) apply(r *http.Request, userID int, change models.SyncChange) models.SyncResult {
+	result := models.SyncResult{ClientID: change.ClientID, ID: change.ID}
+
+	if err := validation.Validate(&change); err != nil {
+		return invalidResult(result, err)
+	}
+
+	var (
+		task *models.Task
+		err  error
+	)
+	switch change.Op {
+	case models.SyncCreate:
+		var body models.CreateTaskRequest
+		if err := decodeChange(change.Task, &body); err != nil {
+			return invalidResult(result, err)
+		}
+		task, err = h.taskService.CreateTask(r.Context(), &body, userID)
+	case models.SyncUpdate:
+		var body models.UpdateTaskRequest
+		if err := decodeChange(change.Task, &body); err != nil {
+			return invalidResult(result, err)
+		}
+		task, err = h.taskService.UpdateTask(r.Context(), change.ID, userID, &body, change.BaseVersion)
+	case models.SyncDelete:
+		err = h.taskService.DeleteTask(r.Context(), change.ID, userID, change.BaseVersion)
+	}
+
+	switch {
+	case err == nil:
+		result.Status = models.SyncApplied
+		result.Task = task
+		if task != nil {
+			result.ID = task.ID
+		}
+	case errors.Is(err, services.ErrVersionConflict):
+		result.Status = models.SyncConflict
+		if result.Task, err = h.taskService.GetTask(r.Context(), change.ID, userID); errors.Is(err, services.ErrTaskNotFound) {
+			// Deleted since the conflict was detected.
+			result.Status = models.SyncNotFound
+		} else if err != nil {
+			return failedResult(r, result, err)
+		}
+	case errors.Is(err, services.ErrTaskNotFound):
+		result.Status = models.SyncNotFound
+	case errors.Is(err, services.ErrParentNotFound):
+		return invalidResult(result, validation.Errors{{
+			Field: "parentId", Code: validation.CodeInvalidValue, Message: err.Error(),
+		}})
+	default:
+		return failedResult(r, result, err)
+	}
+	return result
+}
+
+func decodeChange(data json.RawMessage, dst any) error {
+	if len(data) == 0 {
+		return validation.Errors{{Field: "task", Code: validation.CodeRequired, Message: "task is required"}}
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return validation.Errors{{Field: "task", Code: validation.CodeInvalidValue, Message: "task must be a task object"}}
+	}
+	return validation.Validate(dst)
+}
+
+func invalidResult(result models.SyncResult, err error) models.SyncResult {
+	result.Status = models.SyncInvalid
+	result.Errors = err.(validation.Errors)
+	return result
+}
+
+func failedResult(r *http.Request, result models.SyncResult, err error) models.SyncResult {
+	logger(r).Error("sync change failed", "traceId", response.TraceID(r), "err", err)
+	result.Status = models.SyncError
+	result.Task = nil
+	return result
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"task-manager-server/internal/middleware"
+	"task-manager-server/internal/models"
+)
+
+// asUser returns r authenticated as user 1.
+func asUser(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, 1))
+}
+
+func TestSyncChangesRejectsMalformedToken(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewSyncHandler(nil).Changes(w, asUser(httptest.NewRequest(http.MethodGet, "/api/v1/sync?since=abc", nil)))
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "/problems/invalid-sync-token") {
+		t.Errorf("got %d %s, want a 400 invalid-sync-token problem", w.Code, w.Body)
+	}
+}
+
+// Invalid changes are reported in their result without reaching the
+// service; the other changes are still answered.
+func TestSyncApplyReportsInvalidChanges(t *testing.T) {
+	body := `{"changes": [
+		{"op": "create", "clientId": "c1"},
+		{"op": "create", "clientId": "c2", "task": {"title": ""}},
+		{"op": "rename", "clientId": "c3"}
+	]}`
+	w := httptest.NewRecorder()
+	NewSyncHandler(nil).Apply(w, asUser(httptest.NewRequest(http.MethodPost, "/api/v1/sync", strings.NewReader(body))))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var res models.SyncApplyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ clientID, field string }{{"c1", "task"}, {"c2", "title"}, {"c3", "op"}}
+	if len(res.Results) != len(want) {
+		t.Fatalf("results = %+v, want %d", res.Results, len(want))
+	}
+	for i, result := range res.Results {
+		if result.ClientID != want[i].clientID || result.Status != models.SyncInvalid {
+			t.Errorf("result %d = %s %s, want %s invalid", i, result.ClientID, result.Status, want[i].clientID)
+			continue
+		}
+		if len(result.Errors) == 0 || result.Errors[0].Field != want[i].field {
+			t.Errorf("result %d errors = %+v, want one on %s", i, result.Errors, want[i].field)
+		}
+	}
+}
+
+func TestSyncApplyBoundsChanges(t *testing.T) {
+	changes := make([]models.SyncChange, maxSyncChanges+1)
+	for i := range changes {
+		changes[i] = models.SyncChange{Op: models.SyncDelete, ID: i + 1}
+	}
+	body, _ := json.Marshal(models.SyncRequest{Changes: changes})
+
+	w := httptest.NewRecorder()
+	NewSyncHandler(nil).Apply(w, asUser(httptest.NewRequest(http.MethodPost, "/api/v1/sync", strings.NewReader(string(body)))))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status %d, want 422", w.Code)
+	}
+}
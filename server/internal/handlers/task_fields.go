@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/services"
+)
+
+// parseTaskQuery reads the fields and include query parameters, both comma
+// separated lists, e.g. ?fields=id,title,done&include=subtasks.
+func parseTaskQuery(r *http.Request) (services.TaskQuery, error) {
+	var q services.TaskQuery
+	known := services.TaskFields()
+	for _, name := range splitList(r.URL.Query().Get("fields")) {
+		if !slices.Contains(known, name) {
+			return q, fmt.Errorf("Unknown field %q in fields; tasks have: %s", name, strings.Join(known, ", "))
+		}
+		q.Fields = append(q.Fields, name)
+	}
+	for _, name := range splitList(r.URL.Query().Get("include")) {
+		if !slices.Contains(services.TaskIncludes, name) {
+			return q, fmt.Errorf("Cannot include %q; tasks can include: %s", name, strings.Join(services.TaskIncludes, ", "))
+		}
+		q.Include = append(q.Include, name)
+	}
+	return q, nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// taskView returns what is sent for task: the task itself, or only the
+// fields and relations of q when they were asked for.
+func taskView(task models.Task, q services.TaskQuery) any {
+	if len(q.Fields) == 0 && len(q.Include) == 0 {
+		return task
+	}
+
+	var all map[string]json.RawMessage
+	data, _ := json.Marshal(task)
+	_ = json.Unmarshal(data, &all)
+
+	fields := q.Fields
+	if len(fields) == 0 {
+		fields = services.TaskFields()
+	}
+	view := make(map[string]any, len(fields)+len(q.Include))
+	for _, name := range fields {
+		view[name] = all[name]
+	}
+
+	// Embedded tasks have the same fields but no relations of their own
+	// except their subtask count.
+	embedded := services.TaskQuery{Fields: fields}
+	if slices.Contains(q.Include, services.IncludeSubtaskCount) {
+		embedded.Include = []string{services.IncludeSubtaskCount}
+	}
+	for _, relation := range q.Include {
+		switch relation {
+		case services.IncludeSubtasks:
+			subtasks := make([]any, len(task.Subtasks))
+			for i, sub := range task.Subtasks {
+				subtasks[i] = taskView(sub, embedded)
+			}
+			view[relation] = subtasks
+		case services.IncludeParent:
+			var parent any
+			if task.Parent != nil {
+				parent = taskView(*task.Parent, embedded)
+			}
+			view[relation] = parent
+		case services.IncludeSubtaskCount:
+			view[relation] = task.SubtaskCount
+		case services.IncludeLabels:
+			view[relation] = task.Labels
+		case services.IncludeCommentCount:
+			view[relation] = task.CommentCount
+		case services.IncludeProject:
+			view[relation] = task.Project
+		}
+	}
+	return view
+}
+
+func taskViews(tasks []models.Task, q services.TaskQuery) []any {
+	views := make([]any, len(tasks))
+	for i, task := range tasks {
+		views[i] = taskView(task, q)
+	}
+	return views
+}
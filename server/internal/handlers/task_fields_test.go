@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/services"
+)
+
+func TestParseTaskQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/tasks?fields=id,%20title,,id&include=subtasks", nil)
+	q, err := parseTaskQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(q.Fields, []string{"id", "title"}) || !slices.Equal(q.Include, []string{"subtasks"}) {
+		t.Errorf("query = %+v, want fields id,title and include subtasks", q)
+	}
+
+	for _, query := range []string{"fields=id,owner", "include=comments"} {
+		if _, err := parseTaskQuery(httptest.NewRequest(http.MethodGet, "/api/v1/tasks?"+query, nil)); err == nil {
+			t.Errorf("?%s was accepted", query)
+		}
+	}
+}
+
+// viewJSON encodes the view of task like a response would.
+func viewJSON(t *testing.T, task models.Task, q services.TaskQuery) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(taskView(task, q))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var view map[string]any
+	if err := json.Unmarshal(data, &view); err != nil {
+		t.Fatal(err)
+	}
+	return view
+}
+
+func keys(m map[string]any) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestTaskViewKeepsOnlyFieldsAskedFor(t *testing.T) {
+	task := models.Task{ID: 3, Title: "Groceries", Done: true}
+
+	view := viewJSON(t, task, services.TaskQuery{Fields: []string{"title", "done"}})
+	if got := keys(view); !slices.Equal(got, []string{"done", "title"}) {
+		t.Errorf("fields = %v, want done and title", got)
+	}
+
+	// Without a query the task is sent whole.
+	if _, ok := viewJSON(t, task, services.TaskQuery{})["createdAt"]; !ok {
+		t.Error("a plain read lost fields")
+	}
+}
+
+func TestTaskViewEmbedsRelations(t *testing.T) {
+	count := 1
+	task := models.Task{
+		ID: 3, Title: "Groceries",
+		Subtasks:     []models.Task{{ID: 5, Title: "Milk", Done: true, SubtaskCount: &count}},
+		SubtaskCount: &count,
+	}
+	q := services.TaskQuery{
+		Fields:  []string{"id", "title"},
+		Include: []string{services.IncludeSubtasks, services.IncludeParent, services.IncludeSubtaskCount},
+	}
+	view := viewJSON(t, task, q)
+
+	if got := keys(view); !slices.Equal(got, []string{"id", "parent", "subtaskCount", "subtasks", "title"}) {
+		t.Fatalf("keys = %v", got)
+	}
+	if view["parent"] != nil {
+		t.Errorf("parent = %v, want null for a top-level task", view["parent"])
+	}
+	// Embedded tasks have the same fields, and only the subtask count of
+	// the relations.
+	sub := view["subtasks"].([]any)[0].(map[string]any)
+	if got := keys(sub); !slices.Equal(got, []string{"id", "subtaskCount", "title"}) {
+		t.Errorf("subtask keys = %v, want id, subtaskCount and title", got)
+	}
+}
@@ -1,14 +1,12 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"task-manager-server/internal/middleware"
 	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
 	"task-manager-server/internal/services"
 )
 
@@ -23,176 +21,131 @@ func NewTaskHandler(taskService *services.TaskService) *TaskHandler {
 }
 
 func (h *TaskHandler) GetTasks(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
-	userID := h.getUserIDFromContext(r)
-	if userID == -1 {
-		writeError(w, http.StatusUnauthorized, "Unauthorized")
+	q, err := parseTaskQuery(r)
+	if err != nil {
+		response.Error(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	tasks, err := h.taskService.GetTasks(userID)
+	tasks, err := h.taskService.QueryTasks(r.Context(), userID, q)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to get tasks")
+		writeError(w, r, err)
 		return
 	}
 
-	log.Printf("GetTasks: user=%d returned=%d tasks", userID, len(tasks))
+	logger(r).Debug("tasks listed", "count", len(tasks))
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(tasks)
+	response.JSON(w, http.StatusOK, taskViews(tasks, q))
 }
 
 func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	userID := h.getUserIDFromContext(r)
+	userID := getUserIDFromContext(r)
 	if userID == -1 {
-		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	id := h.extractTaskID(r)
 	if id == -1 {
-		writeError(w, http.StatusBadRequest, "Invalid task ID")
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID")
 		return
 	}
 
-	task, err := h.taskService.GetTask(id, userID)
+	q, err := parseTaskQuery(r)
 	if err != nil {
-		writeError(w, http.StatusNotFound, "Task not found")
+		response.Error(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(task)
-}
-
-func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	task, err := h.taskService.QueryTask(r.Context(), id, userID, q)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	userID := h.getUserIDFromContext(r)
+	response.JSON(w, http.StatusOK, taskView(*task, q))
+}
+
+func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
 	if userID == -1 {
-		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	var req models.CreateTaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
-		return
-	}
-
-	if req.Title == "" {
-		writeError(w, http.StatusBadRequest, "Title is required")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	task, err := h.taskService.CreateTask(&req, userID)
+	task, err := h.taskService.CreateTask(r.Context(), &req, userID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "Failed to create task")
+		writeError(w, r, err)
 		return
 	}
 
-	log.Printf("CreateTask: user=%d id=%d title=%s", userID, task.ID, task.Title)
+	logger(r).Info("task created", "taskId", task.ID)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(task)
+	response.JSON(w, http.StatusCreated, task)
 }
 
 func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	userID := h.getUserIDFromContext(r)
+	userID := getUserIDFromContext(r)
 	if userID == -1 {
-		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	id := h.extractTaskID(r)
 	if id == -1 {
-		writeError(w, http.StatusBadRequest, "Invalid task ID")
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID")
 		return
 	}
 
 	var req models.UpdateTaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	// Get existing task
-	existingTask, err := h.taskService.GetTask(id, userID)
+	task, err := h.taskService.UpdateTask(r.Context(), id, userID, &req, nil)
 	if err != nil {
-		writeError(w, http.StatusNotFound, "Task not found")
+		writeError(w, r, err)
 		return
 	}
 
-	// Apply partial updates only for fields that are provided
-	if req.Title != nil {
-		existingTask.Title = *req.Title
-	}
-	if req.Description != nil {
-		existingTask.Description = *req.Description
-	}
-	if req.Done != nil {
-		existingTask.Done = *req.Done
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(existingTask)
-	log.Printf("UpdateTask: user=%d id=%d title=%s done=%v", userID, id, existingTask.Title, existingTask.Done)
+	logger(r).Info("task updated", "taskId", id, "done", task.Done)
+	response.JSON(w, http.StatusOK, task)
 }
 
 func (h *TaskHandler) DeleteTask(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	userID := h.getUserIDFromContext(r)
+	userID := getUserIDFromContext(r)
 	if userID == -1 {
-		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
 
 	id := h.extractTaskID(r)
 	if id == -1 {
-		writeError(w, http.StatusBadRequest, "Invalid task ID")
+		response.Error(w, r, http.StatusBadRequest, "Invalid task ID")
 		return
 	}
 
-	// Check if task exists and belongs to user
-	_, err := h.taskService.GetTask(id, userID)
-	if err != nil {
-		writeError(w, http.StatusNotFound, "Task not found")
+	if err := h.taskService.DeleteTask(r.Context(), id, userID, nil); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	// Delete task (this would need to be implemented in the service)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Task deleted successfully"})
-	log.Printf("DeleteTask: user=%d id=%d", userID, id)
+	logger(r).Info("task deleted", "taskId", id)
+	response.JSON(w, http.StatusOK, models.MessageResponse{Message: "Task deleted successfully"})
 }
 
-func (h *TaskHandler) getUserIDFromContext(r *http.Request) int {
+func getUserIDFromContext(r *http.Request) int {
 	userID := r.Context().Value(middleware.UserIDKey)
 	if userID == nil {
 		return -1
@@ -205,16 +158,9 @@ func (h *TaskHandler) getUserIDFromContext(r *http.Request) int {
 }
 
 func (h *TaskHandler) extractTaskID(r *http.Request) int {
-	// Extract ID from URL path like /api/tasks/123
-	path := strings.TrimPrefix(r.URL.Path, "/api/tasks/")
-	path = strings.TrimSuffix(path, "/")
-
-	if path == "" {
-		return -1
-	}
-
-	id, err := strconv.Atoi(path)
-	if err != nil {
+	// The {id} wildcard of routes like /api/tasks/{id}
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
 		return -1
 	}
 
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"task-manager-server/internal/response"
+)
+
+// Undo reverts the user's last task commands, as many as the steps query
+// parameter (default 1, at most 20).
+func (h *TaskHandler) Undo(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	steps, ok := undoSteps(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.taskService.Undo(r.Context(), userID, steps)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("commands undone", "commands", res.Commands, "conflicts", len(res.Conflicts))
+	response.JSON(w, http.StatusOK, res)
+}
+
+// Redo applies the user's last undone task commands again, as many as the
+// steps query parameter.
+func (h *TaskHandler) Redo(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	steps, ok := undoSteps(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := h.taskService.Redo(r.Context(), userID, steps)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("commands redone", "commands", res.Commands, "conflicts", len(res.Conflicts))
+	response.JSON(w, http.StatusOK, res)
+}
+
+// undoSteps reads the steps query parameter; it answers 400 when it is not
+// a positive number.
+func undoSteps(w http.ResponseWriter, r *http.Request) (int, bool) {
+	s := r.URL.Query().Get("steps")
+	if s == "" {
+		return 1, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		response.Error(w, r, http.StatusBadRequest, "steps must be a positive number")
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/response"
+	"task-manager-server/internal/services"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	webhooks, err := h.webhookService.ListWebhooks(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, webhooks)
+}
+
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	webhook, err := h.webhookService.GetWebhook(r.Context(), id, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	webhook, err := h.webhookService.CreateWebhook(r.Context(), &req, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("webhook created", "webhookId", webhook.ID)
+	response.JSON(w, http.StatusCreated, webhook)
+}
+
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	webhook, err := h.webhookService.UpdateWebhook(r.Context(), id, userID, &req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("webhook updated", "webhookId", id, "active", webhook.Active)
+	response.JSON(w, http.StatusOK, webhook)
+}
+
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(r.Context(), id, userID); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("webhook deleted", "webhookId", id)
+	response.JSON(w, http.StatusOK, models.MessageResponse{Message: "Webhook deleted successfully"})
+}
+
+// Deliveries lists the latest deliveries of a webhook, at most the limit
+// query parameter (default and maximum 100).
+func (h *WebhookHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id := pathID(r, "id")
+	if id == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	var limit int
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			response.Error(w, r, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.webhookService.Deliveries(r.Context(), id, userID, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, deliveries)
+}
+
+// Redeliver queues a past delivery to be sent again.
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == -1 {
+		response.Error(w, r, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	id, deliveryID := pathID(r, "id"), pathID(r, "deliveryId")
+	if id == -1 || deliveryID == -1 {
+		response.Error(w, r, http.StatusBadRequest, "Invalid webhook or delivery ID")
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(r.Context(), id, deliveryID, userID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	logger(r).Info("webhook delivery requeued", "webhookId", id, "deliveryId", deliveryID)
+	response.JSON(w, http.StatusAccepted, delivery)
+}
+
+// pathID reads a positive ID from a path wildcard; -1 when it is invalid.
+func pathID(r *http.Request, name string) int {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil || id <= 0 {
+		return -1
+	}
+	return id
+}
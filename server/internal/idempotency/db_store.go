@@ -0,0 +1,128 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// DBStore keeps records in the idempotency_keys table so that retries are
+// recognised by every instance and across restarts.
+type DBStore struct {
+	db *sql.DB
+}
+
+func NewDBStore(db *sql.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Begin(ctx context.Context, userID int, key, fingerprint string, now time.Time) (Record, bool, error) {
+	// Expired and abandoned records of the user free their keys. Sweeping
+	// per user keeps the table from growing with every request.
+	if _, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = ? AND expires_at <= ?`,
+		userID,
+		now,
+	); err != nil {
+		return Record{}, false, err
+	}
+
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT IGNORE INTO idempotency_keys (user_id, idem_key, fingerprint, created_at, expires_at)
+       VALUES (?, ?, ?, ?, ?)`,
+		userID,
+		key,
+		fingerprint,
+		now,
+		now.Add(PendingTimeout),
+	)
+	if err != nil {
+		return Record{}, false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return Record{}, false, err
+	}
+	if inserted == 1 {
+		return Record{}, true, nil
+	}
+
+	var (
+		rec     Record
+		status  sql.NullInt64
+		headers []byte
+		body    []byte
+	)
+	err = s.db.QueryRowContext(
+		ctx,
+		`SELECT fingerprint, status_code, response_headers, response_body
+       FROM idempotency_keys
+       WHERE user_id = ? AND idem_key = ?`,
+		userID,
+		key,
+	).Scan(&rec.Fingerprint, &status, &headers, &body)
+	if err == sql.ErrNoRows {
+		// Released in the meantime; the client may retry.
+		return Record{Fingerprint: fingerprint, Pending: true}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	if !status.Valid {
+		rec.Pending = true
+		return rec, false, nil
+	}
+
+	rec.Response = &Response{Status: int(status.Int64), Body: body}
+	if err := json.Unmarshal(headers, &rec.Response.Header); err != nil {
+		return Record{}, false, err
+	}
+	return rec, false, nil
+}
+
+func (s *DBStore) Complete(ctx context.Context, userID int, key string, res Response, expiresAt time.Time) error {
+	headers, err := json.Marshal(res.Header)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(
+		ctx,
+		`UPDATE idempotency_keys
+       SET status_code = ?, response_headers = ?, response_body = ?, expires_at = ?
+       WHERE user_id = ? AND idem_key = ?`,
+		res.Status,
+		headers,
+		res.Body,
+		expiresAt,
+		userID,
+		key,
+	)
+	return err
+}
+
+func (s *DBStore) Extend(ctx context.Context, userID int, key string, until time.Time) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`UPDATE idempotency_keys SET expires_at = ?
+       WHERE user_id = ? AND idem_key = ? AND status_code IS NULL`,
+		until,
+		userID,
+		key,
+	)
+	return err
+}
+
+func (s *DBStore) Release(ctx context.Context, userID int, key string) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = ? AND idem_key = ?`,
+		userID,
+		key,
+	)
+	return err
+}
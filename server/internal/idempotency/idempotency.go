@@ -0,0 +1,50 @@
+// Package idempotency stores the first response to a request sent with an
+// Idempotency-Key so that retries of it can be answered without running it
+// again.
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// PendingTimeout is how long a request holds its key before it is
+// considered abandoned, e.g. because the server stopped while running it.
+// A request that is still running renews its hold with Extend, so it may
+// take longer than this.
+const PendingTimeout = time.Minute
+
+// Response is a stored response.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is what a store knows about a key.
+type Record struct {
+	// Fingerprint identifies the request that first used the key.
+	Fingerprint string
+	// Pending is true while that request is still running.
+	Pending bool
+	// Response is set once it finished.
+	Response *Response
+}
+
+// Store keeps records per user and key.
+type Store interface {
+	// Begin claims key for a request with fingerprint. When the key is
+	// free, or its record expired or was abandoned, it records the request
+	// as pending and returns started true. Otherwise it returns the
+	// existing record.
+	Begin(ctx context.Context, userID int, key, fingerprint string, now time.Time) (rec Record, started bool, err error)
+	// Complete stores the response of a started request until expiresAt.
+	Complete(ctx context.Context, userID int, key string, res Response, expiresAt time.Time) error
+	// Extend keeps the key of a started request that is still running
+	// held until until. It does nothing once the request completed.
+	Extend(ctx context.Context, userID int, key string, until time.Time) error
+	// Release frees the key of a started request that should not be
+	// replayed, so it can be retried.
+	Release(ctx context.Context, userID int, key string) error
+}
@@ -0,0 +1,90 @@
+package idempotency
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pruneThreshold is the size after which expired records are swept.
+const pruneThreshold = 10000
+
+type memoryRecord struct {
+	Record
+	expiresAt time.Time
+}
+
+// MemoryStore keeps records in process memory. It suits a single instance;
+// records are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryRecord
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]*memoryRecord)}
+}
+
+func (s *MemoryStore) Begin(_ context.Context, userID int, key, fingerprint string, now time.Time) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.records) > pruneThreshold {
+		s.prune(now)
+	}
+
+	id := recordID(userID, key)
+	if r, ok := s.records[id]; ok && now.Before(r.expiresAt) {
+		return r.Record, false, nil
+	}
+
+	s.records[id] = &memoryRecord{
+		Record:    Record{Fingerprint: fingerprint, Pending: true},
+		expiresAt: now.Add(PendingTimeout),
+	}
+	return Record{}, true, nil
+}
+
+func (s *MemoryStore) Complete(_ context.Context, userID int, key string, res Response, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.records[recordID(userID, key)]; ok {
+		r.Pending = false
+		r.Response = &res
+		r.expiresAt = expiresAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) Extend(_ context.Context, userID int, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r, ok := s.records[recordID(userID, key)]; ok && r.Pending {
+		r.expiresAt = until
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, userID int, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, recordID(userID, key))
+	return nil
+}
+
+// prune drops expired records.
+func (s *MemoryStore) prune(now time.Time) {
+	for id, r := range s.records {
+		if !now.Before(r.expiresAt) {
+			delete(s.records, id)
+		}
+	}
+}
+
+func recordID(userID int, key string) string {
+	return strconv.Itoa(userID) + ":" + key
+}
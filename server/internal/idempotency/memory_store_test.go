@@ -0,0 +1,75 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreLifecycle(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	if _, started, err := s.Begin(ctx, 1, "k", "fp", now); err != nil || !started {
+		t.Fatalf("Begin on a free key: started %v, err %v", started, err)
+	}
+	rec, started, _ := s.Begin(ctx, 1, "k", "fp", now)
+	if started || !rec.Pending {
+		t.Fatalf("Begin on a held key: started %v, record %+v", started, rec)
+	}
+	if _, started, _ := s.Begin(ctx, 2, "k", "fp", now); !started {
+		t.Error("keys are not scoped per user")
+	}
+
+	res := Response{Status: http.StatusCreated, Body: []byte(`{}`)}
+	if err := s.Complete(ctx, 1, "k", res, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	rec, started, _ = s.Begin(ctx, 1, "k", "fp", now.Add(30*time.Minute))
+	if started || rec.Pending || rec.Response == nil || rec.Response.Status != http.StatusCreated {
+		t.Fatalf("Begin on a completed key: started %v, record %+v", started, rec)
+	}
+	if _, started, _ := s.Begin(ctx, 1, "k", "fp", now.Add(2*time.Hour)); !started {
+		t.Error("an expired key was not freed")
+	}
+}
+
+func TestMemoryStoreExtend(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	s.Begin(ctx, 1, "k", "fp", now)
+	if err := s.Extend(ctx, 1, "k", now.Add(3*PendingTimeout)); err != nil {
+		t.Fatal(err)
+	}
+	if _, started, _ := s.Begin(ctx, 1, "k", "fp", now.Add(2*PendingTimeout)); started {
+		t.Error("an extended key was taken for abandoned")
+	}
+	if _, started, _ := s.Begin(ctx, 1, "k", "fp", now.Add(4*PendingTimeout)); !started {
+		t.Error("a key no longer renewed was not freed")
+	}
+
+	// Extending a completed key keeps its expiry.
+	s.Complete(ctx, 1, "k", Response{Status: http.StatusOK}, now.Add(5*PendingTimeout))
+	s.Extend(ctx, 1, "k", now.Add(100*PendingTimeout))
+	if _, started, _ := s.Begin(ctx, 1, "k", "fp", now.Add(6*PendingTimeout)); !started {
+		t.Error("Extend moved the expiry of a completed key")
+	}
+}
+
+func TestMemoryStoreRelease(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+
+	s.Begin(ctx, 1, "k", "fp", now)
+	if err := s.Release(ctx, 1, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, started, _ := s.Begin(ctx, 1, "k", "other", now); !started {
+		t.Error("a released key is still held")
+	}
+}
@@ -0,0 +1,72 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DBStore keeps entries in the login_attempts table so that lockouts are
+// shared between instances and survive restarts.
+type DBStore struct {
+	db *sql.DB
+}
+
+func NewDBStore(db *sql.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Get(ctx context.Context, key string) (Entry, error) {
+	var e Entry
+	var lockedUntil sql.NullTime
+
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT failures, last_failure, locked_until FROM login_attempts WHERE attempt_key = ?`,
+		key,
+	).Scan(&e.Failures, &e.LastFailure, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return Entry{}, nil
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e.LockedUntil = lockedUntil.Time
+	return e, nil
+}
+
+func (s *DBStore) AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error) {
+	// MySQL evaluates the assignments left to right, so failures still sees
+	// the previous last_failure.
+	if _, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO login_attempts (attempt_key, failures, last_failure)
+       VALUES (?, 1, ?)
+       ON DUPLICATE KEY UPDATE
+         failures = IF(last_failure < ?, 1, failures + 1),
+         last_failure = VALUES(last_failure)`,
+		key,
+		now,
+		now.Add(-window),
+	); err != nil {
+		return Entry{}, err
+	}
+
+	return s.Get(ctx, key)
+}
+
+func (s *DBStore) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`UPDATE login_attempts SET locked_until = ? WHERE attempt_key = ?`,
+		until,
+		key,
+	)
+	return err
+}
+
+func (s *DBStore) Reset(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_attempts WHERE attempt_key = ?`, key)
+	return err
+}
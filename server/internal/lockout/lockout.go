@@ -0,0 +1,106 @@
+// Package lockout tracks failed login attempts and locks keys (accounts, IP
+// addresses) out with an exponentially growing delay.
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is the failure state of a single key.
+type Entry struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Store persists entries. Implementations must make AddFailure atomic so
+// that concurrent attempts are all counted.
+type Store interface {
+	// Get returns the entry for key, or a zero Entry if there is none.
+	Get(ctx context.Context, key string) (Entry, error)
+	// AddFailure records a failed attempt at now and returns the updated
+	// entry. The counter restarts when the previous failure is older than
+	// window.
+	AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error)
+	// Lock stores the time until which key is locked.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset forgets key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy controls when a key gets locked and for how long.
+type Policy struct {
+	// MaxAttempts is the number of failures allowed before locking.
+	MaxAttempts int
+	// BaseDelay is the first lockout; every further failure doubles it.
+	BaseDelay time.Duration
+	// MaxDelay caps the lockout.
+	MaxDelay time.Duration
+	// Window is how long a failure is remembered.
+	Window time.Duration
+}
+
+// Limiter applies a Policy to keys sharing a prefix in a Store.
+type Limiter struct {
+	store  Store
+	prefix string
+	policy Policy
+}
+
+func NewLimiter(store Store, prefix string, policy Policy) *Limiter {
+	return &Limiter{
+		store:  store,
+		prefix: prefix,
+		policy: policy,
+	}
+}
+
+// Check returns how long key stays locked, or zero when it is not locked.
+func (l *Limiter) Check(ctx context.Context, key string) (time.Duration, error) {
+	entry, err := l.store.Get(ctx, l.prefix+key)
+	if err != nil {
+		return 0, err
+	}
+	return remaining(entry.LockedUntil), nil
+}
+
+// Fail records a failed attempt. When the attempt pushes key over the
+// policy it is locked and the lockout duration is returned.
+func (l *Limiter) Fail(ctx context.Context, key string) (time.Duration, error) {
+	now := time.Now()
+	entry, err := l.store.AddFailure(ctx, l.prefix+key, now, l.policy.Window)
+	if err != nil {
+		return 0, err
+	}
+
+	over := entry.Failures - l.policy.MaxAttempts
+	if over < 0 {
+		return 0, nil
+	}
+
+	delay := l.policy.BaseDelay
+	for i := 0; i < over && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.policy.MaxDelay {
+		delay = l.policy.MaxDelay
+	}
+
+	if err := l.store.Lock(ctx, l.prefix+key, now.Add(delay)); err != nil {
+		return 0, err
+	}
+	return delay, nil
+}
+
+// Reset clears the failures and any lock on key.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, l.prefix+key)
+}
+
+func remaining(until time.Time) time.Duration {
+	if d := time.Until(until); d > 0 {
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneThreshold is the size after which stale entries are swept.
+const pruneThreshold = 10000
+
+// MemoryStore keeps entries in process memory. It suits a single instance;
+// state is lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		return *e, nil
+	}
+	return Entry{}, nil
+}
+
+func (s *MemoryStore) AddFailure(_ context.Context, key string, now time.Time, window time.Duration) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.entries) > pruneThreshold {
+		s.prune(now, window)
+	}
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &Entry{}
+		s.entries[key] = e
+	}
+	if now.Sub(e.LastFailure) > window {
+		e.Failures = 0
+	}
+	e.Failures++
+	e.LastFailure = now
+	return *e, nil
+}
+
+func (s *MemoryStore) Lock(_ context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &Entry{}
+		s.entries[key] = e
+	}
+	e.LockedUntil = until
+	return nil
+}
+
+func (s *MemoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// prune drops entries that are neither locked nor within the window.
+func (s *MemoryStore) prune(now time.Time, window time.Duration) {
+	for key, e := range s.entries {
+		if now.Sub(e.LastFailure) > window && now.After(e.LockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
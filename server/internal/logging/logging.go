@@ -0,0 +1,159 @@
+// Package logging writes structured logs with log/slog and carries a logger
+// tagged with the request ID down to the services. Attributes that could
+// hold personal data or credentials are redacted before they are written.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the values of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never written. Keys are
+// compared in lower case without separators, so "mfa_token" and "mfaToken"
+// both match.
+var sensitiveKeys = map[string]bool{
+	"email":         true,
+	"password":      true,
+	"newpassword":   true,
+	"token":         true,
+	"accesstoken":   true,
+	"mfatoken":      true,
+	"secret":        true,
+	"totpsecret":    true,
+	"recoverycode":  true,
+	"authorization": true,
+	"cookie":        true,
+}
+
+// Patterns scrubbed from every text written, such as error messages that
+// quote their input.
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	bearerPattern = regexp.MustCompile(`(?i)bearer\s+\S+`)
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+// NewHandler returns a handler writing records of level and above to w, as
+// JSON or, with format "text", as key=value pairs.
+func NewHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	if format == "text" {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(a.Key))
+	if sensitiveKeys[key] {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Scrub(err.Error()))
+		}
+	}
+	return a
+}
+
+// Scrub masks email addresses and tokens in s.
+func Scrub(s string) string {
+	s = emailPattern.ReplaceAllString(s, Redacted)
+	s = bearerPattern.ReplaceAllString(s, "Bearer "+Redacted)
+	return jwtPattern.ReplaceAllString(s, Redacted)
+}
+
+// Request describes the request being served. Middleware further in fills
+// in what it learns, such as the route or the authenticated user, for the
+// line logged once the request is answered.
+type Request struct {
+	ID     string
+	Route  string
+	UserID int
+}
+
+type requestKey struct{}
+
+type loggerKey struct{}
+
+// WithRequest returns a copy of ctx carrying req.
+func WithRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+// RequestFrom returns the request ctx belongs to; nil for work the server
+// does on its own.
+func RequestFrom(ctx context.Context) *Request {
+	req, _ := ctx.Value(requestKey{}).(*Request)
+	return req
+}
+
+// WithLogger returns a copy of ctx carrying l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// ForRequest returns the default logger tagged with the ID of a request
+// and, when the request is traced, with the ID of its trace as otelTraceId.
+func ForRequest(ctx context.Context, id string) *slog.Logger {
+	l := slog.Default().With("requestId", id)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		l = l.With("otelTraceId", sc.TraceID().String())
+	}
+	return l
+}
+
+// With returns a copy of ctx whose logger adds args to every record.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// FromContext returns the logger of a request, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// maxRequestID bounds request IDs taken from clients.
+const maxRequestID = 128
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// ValidRequestID reports whether id, received from a client or a proxy, can
+// be used as is: short and made of characters that are safe in logs and
+// headers.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
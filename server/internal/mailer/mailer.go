@@ -0,0 +1,14 @@
+package mailer
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails such as verification and password
+// reset links.
+type Mailer interface {
+	Send(msg Message) error
+}
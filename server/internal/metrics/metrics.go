@@ -0,0 +1,91 @@
+// Package metrics exposes the server's metrics in the Prometheus text
+// format: HTTP traffic, the database connection pool, the Go runtime and
+// counters of what users do.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "taskmanager"
+
+// UnmatchedRoute labels requests no route accepted, so unknown paths do not
+// each get a series of their own.
+const UnmatchedRoute = "unmatched"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests answered, by route pattern and status code.",
+	}, []string{"route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to answer HTTP requests, by route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "status"})
+
+	// TasksCreated counts tasks stored, in any way: REST, GraphQL, gRPC,
+	// batches, sync or undo.
+	TasksCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_created_total",
+		Help:      "Tasks created.",
+	})
+
+	// TasksCompleted counts tasks that became done.
+	TasksCompleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_completed_total",
+		Help:      "Tasks marked done.",
+	})
+
+	// Logins counts sessions issued, with or without a second factor.
+	Logins = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Successful logins.",
+	})
+
+	// LoginFailures counts failed logins by the reason recorded for the
+	// lockout, e.g. "invalid_password".
+	LoginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Failed logins, by reason.",
+	}, []string{"reason"})
+)
+
+// ObserveRequest records an answered HTTP request. route is the pattern it
+// matched, e.g. "GET /api/v1/tasks/{id}", or UnmatchedRoute.
+func ObserveRequest(route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, code).Inc()
+	httpDuration.WithLabelValues(route, code).Observe(d.Seconds())
+}
+
+// Handler serves the metrics, including the connection pool stats of db.
+func Handler(db *sql.DB) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "task_manager"),
+		httpRequests,
+		httpDuration,
+		TasksCreated,
+		TasksCompleted,
+		Logins,
+		LoginFailures,
+	)
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
@@ -2,63 +2,90 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/response"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type contextKey string
 
-const UserIDKey contextKey = "user_id"
+const (
+	UserIDKey contextKey = "user_id"
+	ScopesKey contextKey = "scopes"
+)
 
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			writeError(w, http.StatusUnauthorized, "Authorization header required")
+		userID, scopes, err := Authenticate(r.Header.Get("Authorization"))
+		if err != nil {
+			response.Error(w, r, http.StatusUnauthorized, err.Error())
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			writeError(w, http.StatusUnauthorized, "Bearer token required")
-			return
+		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		ctx = context.WithValue(ctx, ScopesKey, scopes)
+		if req := logging.RequestFrom(ctx); req != nil {
+			req.UserID = userID
 		}
+		ctx = logging.With(ctx, "userId", userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte("your-secret-key-change-in-production"), nil
-		})
+// Authenticate validates an Authorization value of the form "Bearer
+// <token>" and returns the user and scopes of the session. The gRPC server
+// checks its metadata with it too, so both APIs accept the same tokens.
+func Authenticate(authHeader string) (userID int, scopes []string, err error) {
+	if authHeader == "" {
+		return 0, nil, errors.New("Authorization header required")
+	}
 
-		if err != nil || !token.Valid {
-			writeError(w, http.StatusUnauthorized, "Invalid token")
-			return
-		}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return 0, nil, errors.New("Bearer token required")
+	}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			writeError(w, http.StatusUnauthorized, "Invalid token claims")
-			return
-		}
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte("your-secret-key-change-in-production"), nil
+	})
 
-		userID, ok := claims["user_id"].(float64)
-		if !ok {
-			writeError(w, http.StatusUnauthorized, "Invalid user ID in token")
-			return
-		}
+	if err != nil || !token.Valid {
+		return 0, nil, errors.New("Invalid token")
+	}
 
-		ctx := context.WithValue(r.Context(), UserIDKey, int(userID))
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, nil, errors.New("Invalid token claims")
+	}
+
+	id, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, nil, errors.New("Invalid user ID in token")
+	}
 
-func writeJSON(w http.ResponseWriter, status int, data any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(data)
+	// Scopes are optional and space separated, e.g. "admin".
+	scope, _ := claims["scope"].(string)
+
+	return int(id), strings.Fields(scope), nil
 }
 
-func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]string{"message": message})
+// RequireScope only lets requests through whose token carries scope. It must
+// run after AuthMiddleware.
+func RequireScope(scope string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, _ := r.Context().Value(ScopesKey).([]string)
+			if !slices.Contains(scopes, scope) {
+				response.Error(w, r, http.StatusForbidden, "Insufficient permissions")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
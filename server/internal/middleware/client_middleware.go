@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"task-manager-server/internal/audit"
+)
+
+// ClientInfo keeps the address and user agent of the client in the request
+// context, where the services find them for the audit log. Like the login
+// lockout it ignores proxy headers, which the client controls.
+func ClientInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		ctx := audit.WithClient(r.Context(), audit.Client{IP: ip, UserAgent: r.UserAgent()})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
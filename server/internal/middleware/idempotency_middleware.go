@@ -0,0 +1,224 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"task-manager-server/internal/idempotency"
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/response"
+)
+
+const (
+	// maxIdempotencyKey is the longest Idempotency-Key accepted.
+	maxIdempotencyKey = 255
+	// maxIdempotentBody bounds the request bodies that are fingerprinted.
+	maxIdempotentBody = 8 << 20
+)
+
+// leaseRenewal is how often a running request renews the hold on its key.
+var leaseRenewal = idempotency.PendingTimeout / 2
+
+// versionPrefix matches the version in a versioned route pattern.
+var versionPrefix = regexp.MustCompile(`^/api/v[0-9]+/`)
+
+// unreplayedHeaders describe the request that got the stored response, or
+// the path it was sent to, rather than the response itself. A replay keeps
+// the values set for the retry.
+var unreplayedHeaders = map[string]bool{
+	"X-Request-Id": true,
+	"Traceparent":  true,
+	"Deprecation":  true,
+	"Sunset":       true,
+	"Link":         true,
+}
+
+// Idempotency makes retries of a mutating request safe. The first response
+// to a request with an Idempotency-Key header is stored for ttl and replayed,
+// with an Idempotent-Replayed header, for retries that reuse the key, also
+// through another alias of the route such as /api for /api/v1. Reusing a key
+// for a different request is a 422. Server errors are not stored, so
+// such requests can be retried. It must run after AuthMiddleware since keys
+// are scoped per user.
+func Idempotency(store idempotency.Store, ttl time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			userID, ok := r.Context().Value(UserIDKey).(int)
+			if key == "" || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKey {
+				response.Error(w, r, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+			if err != nil {
+				response.Error(w, r, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			if len(body) > maxIdempotentBody {
+				response.Error(w, r, http.StatusRequestEntityTooLarge, "Request body too large")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			sum := sha256.New()
+			io.WriteString(sum, idempotencyRoute(r)+"\n")
+			sum.Write(body)
+			fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+			rec, started, err := store.Begin(r.Context(), userID, key, fingerprint, time.Now())
+			if err != nil {
+				logging.FromContext(r.Context()).Error("Idempotency: storing the response", "key", key, "err", err)
+				response.Error(w, r, http.StatusInternalServerError, "An unexpected error occurred")
+				return
+			}
+
+			if !started {
+				switch {
+				case rec.Fingerprint != fingerprint:
+					response.WriteProblem(w, r, &response.Problem{
+						Type:   response.TypeURI("idempotency-key-reused"),
+						Title:  "Idempotency key reused",
+						Status: http.StatusUnprocessableEntity,
+						Detail: "This Idempotency-Key was already used for a different request",
+					})
+				case rec.Pending:
+					w.Header().Set("Retry-After", "1")
+					response.WriteProblem(w, r, &response.Problem{
+						Type:   response.TypeURI("idempotency-key-in-use"),
+						Title:  "Request in progress",
+						Status: http.StatusConflict,
+						Detail: "A request with this Idempotency-Key is still being processed",
+					})
+				default:
+					replay(w, rec.Response)
+				}
+				return
+			}
+
+			// The key is released or completed even when the client went away.
+			storeCtx := context.WithoutCancel(r.Context())
+			capture := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+			stop := holdKey(storeCtx, store, userID, key)
+			defer func() {
+				stop()
+				if p := recover(); p != nil {
+					_ = store.Release(storeCtx, userID, key)
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(capture, r)
+			stop()
+
+			if capture.status >= 500 {
+				err = store.Release(storeCtx, userID, key)
+			} else {
+				err = store.Complete(storeCtx, userID, key, idempotency.Response{
+					Status: capture.status,
+					Header: capture.header,
+					Body:   capture.body.Bytes(),
+				}, time.Now().Add(ttl))
+			}
+			if err != nil {
+				logging.FromContext(r.Context()).Error("Idempotency: storing the response", "key", key, "err", err)
+			}
+		})
+	}
+}
+
+// holdKey renews the hold on key until the returned function is called, so
+// a slow request is not taken for abandoned and run a second time by a
+// retry.
+func holdKey(ctx context.Context, store idempotency.Store, userID int, key string) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(leaseRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if err := store.Extend(ctx, userID, key, now.Add(idempotency.PendingTimeout)); err != nil {
+					logging.FromContext(ctx).Error("Idempotency: renewing the key", "key", key, "err", err)
+				}
+			}
+		}
+	}()
+	return sync.OnceFunc(func() {
+		close(done)
+		<-stopped
+	})
+}
+
+// idempotencyRoute names the request the same under every prefix its route
+// is served at: the route pattern without the version, with the wildcards
+// filled in, e.g. "PUT /api/tasks/42".
+func idempotencyRoute(r *http.Request) string {
+	method, path, _ := strings.Cut(r.Pattern, " ")
+	path = versionPrefix.ReplaceAllString(path, "/api/")
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			name = strings.TrimSuffix(strings.TrimSuffix(name, "}"), "...")
+			segments[i] = r.PathValue(name)
+		}
+	}
+	return method + " " + strings.Join(segments, "/")
+}
+
+func replay(w http.ResponseWriter, res *idempotency.Response) {
+	for name, values := range res.Header {
+		if !unreplayedHeaders[http.CanonicalHeaderKey(name)] {
+			w.Header()[name] = values
+		}
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(res.Status)
+	_, _ = w.Write(res.Body)
+}
+
+// captureWriter keeps a copy of the response it passes through.
+type captureWriter struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.wroteHeader = true
+		c.status = status
+		c.header = c.ResponseWriter.Header().Clone()
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
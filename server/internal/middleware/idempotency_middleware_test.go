@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"task-manager-server/internal/idempotency"
+)
+
+// countingStore is a MemoryStore that counts renewals.
+type countingStore struct {
+	*idempotency.MemoryStore
+	extends atomic.Int32
+}
+
+func (s *countingStore) Extend(ctx context.Context, userID int, key string, until time.Time) error {
+	s.extends.Add(1)
+	return s.MemoryStore.Extend(ctx, userID, key, until)
+}
+
+// idempotentServer serves handler at the versioned and the unversioned
+// path of a route, as user 7, behind the Idempotency middleware.
+func idempotentServer(store idempotency.Store, handler http.HandlerFunc) http.Handler {
+	mux := http.NewServeMux()
+	wrapped := Idempotency(store, time.Hour)(handler)
+	mux.Handle("POST /api/v1/tasks/{id}", wrapped)
+	mux.Handle("POST /api/tasks/{id}", wrapped)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), UserIDKey, 7)))
+	})
+}
+
+func send(h http.Handler, path, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyReplaysTheFirstResponse(t *testing.T) {
+	var runs atomic.Int32
+	h := idempotentServer(idempotency.NewMemoryStore(), func(w http.ResponseWriter, r *http.Request) {
+		n := runs.Add(1)
+		w.Header().Set("X-Request-Id", "first")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"run":` + strconv.Itoa(int(n)) + `}`))
+	})
+
+	first := send(h, "/api/v1/tasks/3", "k1", `{"title":"a"}`)
+	again := send(h, "/api/tasks/3", "k1", `{"title":"a"}`)
+
+	if runs.Load() != 1 {
+		t.Fatalf("handler ran %d times, want once", runs.Load())
+	}
+	if again.Code != http.StatusCreated || again.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", again.Code, again.Body, first.Code, first.Body)
+	}
+	if again.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay has no Idempotent-Replayed header")
+	}
+	if again.Header().Get("X-Request-Id") != "" {
+		t.Error("replay repeats the X-Request-Id of the first request")
+	}
+
+	if other := send(h, "/api/v1/tasks/3", "", `{"title":"a"}`); other.Header().Get("Idempotent-Replayed") != "" || runs.Load() != 2 {
+		t.Error("a request without a key was replayed")
+	}
+}
+
+func TestIdempotencyRejectsReusedKey(t *testing.T) {
+	h := idempotentServer(idempotency.NewMemoryStore(), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	send(h, "/api/v1/tasks/3", "k1", `{"title":"a"}`)
+	for _, tt := range []struct{ path, body string }{
+		{"/api/v1/tasks/3", `{"title":"b"}`},
+		{"/api/v1/tasks/4", `{"title":"a"}`},
+	} {
+		if w := send(h, tt.path, "k1", tt.body); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %s: status %d, want 422", tt.path, tt.body, w.Code)
+		}
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var runs atomic.Int32
+	h := idempotentServer(idempotency.NewMemoryStore(), func(w http.ResponseWriter, r *http.Request) {
+		if runs.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	send(h, "/api/v1/tasks/3", "k1", `{}`)
+	if w := send(h, "/api/v1/tasks/3", "k1", `{}`); w.Code != http.StatusOK || runs.Load() != 2 {
+		t.Fatalf("retry: status %d after %d runs, want 200 after 2", w.Code, runs.Load())
+	}
+}
+
+func TestIdempotencyHoldsTheKeyWhileRunning(t *testing.T) {
+	defer func(d time.Duration) { leaseRenewal = d }(leaseRenewal)
+	leaseRenewal = 5 * time.Millisecond
+
+	store := &countingStore{MemoryStore: idempotency.NewMemoryStore()}
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := idempotentServer(store, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		send(h, "/api/v1/tasks/3", "k1", `{}`)
+	}()
+	<-started
+
+	if w := send(h, "/api/v1/tasks/3", "k1", `{}`); w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("retry while running: status %d, want 409 with Retry-After", w.Code)
+	}
+	time.Sleep(10 * leaseRenewal)
+	close(release)
+	wg.Wait()
+
+	renewals := store.extends.Load()
+	if renewals == 0 {
+		t.Fatal("the key was never renewed while the request ran")
+	}
+	time.Sleep(5 * leaseRenewal)
+	if store.extends.Load() != renewals {
+		t.Error("the key is still renewed after the request finished")
+	}
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/response"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the ID of a request in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestLogger gives every request an ID and a logger tagged with it, and
+// logs one line per request once it is answered. The ID is taken from the
+// X-Request-ID header when the client or a proxy in front sent a usable
+// one. It is echoed in the response and reported as the traceId of problem
+// responses, so a client can quote it. Requests that are traced also log
+// the ID of their OpenTelemetry trace as otelTraceId.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		req := &logging.Request{ID: id}
+		ctx := logging.WithRequest(r.Context(), req)
+		ctx = logging.WithLogger(ctx, logging.ForRequest(ctx, id))
+		ctx = response.WithTraceID(ctx, id)
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		// A handler that writes nothing answers 200.
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if sw.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", req.Route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.status),
+			slog.Int64("bytes", sw.bytes),
+			slog.Float64("latencyMs", float64(time.Since(start).Microseconds())/1000),
+		}
+		if req.UserID != 0 {
+			attrs = append(attrs, slog.Int("userId", req.UserID))
+		}
+		logging.ForRequest(ctx, id).LogAttrs(ctx, level, "request", attrs...)
+	})
+}
+
+// Route names the route a request matched, for the request log and the
+// span of the request. The router adds it in front of every route.
+func Route(pattern string) Middleware {
+	_, path, _ := strings.Cut(pattern, " ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if req := logging.RequestFrom(r.Context()); req != nil {
+				req.Route = pattern
+			}
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(pattern)
+			span.SetAttributes(semconv.HTTPRoute(path))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// statusWriter notes the status and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades through; the upgrade is logged as 101.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *statusWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
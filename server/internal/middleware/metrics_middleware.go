@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/metrics"
+)
+
+// RequestMetrics counts requests and their latency by route and status. It
+// runs inside RequestLogger, whose request the router names the route of.
+func RequestMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := metrics.UnmatchedRoute
+		if req := logging.RequestFrom(r.Context()); req != nil && req.Route != "" {
+			route = req.Route
+		}
+		metrics.ObserveRequest(route, status, time.Since(start))
+	})
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import "net/http"
+
+// TokenFromQuery accepts the bearer token in the access_token query
+// parameter for clients that cannot set headers, such as EventSource. A
+// token in the Authorization header takes precedence.
+func TokenFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
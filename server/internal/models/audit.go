@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// AuditEntry records one change made through the API: who made it, from
+// where, and what it changed.
+type AuditEntry struct {
+	ID         int64                  `json:"id"`
+	ActorID    *int                   `json:"actorId"` // nil for anonymous requests
+	Action     string                 `json:"action"`
+	EntityType string                 `json:"entityType"`
+	EntityID   string                 `json:"entityId"`
+	Changes    map[string]AuditChange `json:"changes,omitempty"`
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"userAgent"`
+	CreatedAt  time.Time              `json:"createdAt"`
+}
+
+// AuditChange is the value of a field before and after a change; From is
+// null for creations and To for removals.
+type AuditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// AuditLogResponse is a page of the audit log, newest first. Before is
+// passed back to get the next page; it is absent on the last one.
+type AuditLogResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	Before  *int64       `json:"before,omitempty"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "encoding/json"
+
+// Batch modes.
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+// BatchRequest runs several task operations in one transaction. In atomic
+// mode (the default) a failing operation cancels the whole batch; in
+// best_effort mode only that operation is skipped.
+type BatchRequest struct {
+	Mode       string           `json:"mode,omitempty" validate:"oneof=atomic best_effort"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation is one operation. Task holds a CreateTaskRequest for
+// creates and an UpdateTaskRequest for updates. A move makes the task a
+// subtask of ParentID, or a top-level task when ParentID is null. Update,
+// move and delete fail with a conflict when IfVersion is set and the task
+// is at another version.
+type BatchOperation struct {
+	Op        string          `json:"op" validate:"required,oneof=create update delete move"`
+	ID        int             `json:"id,omitempty"`
+	ParentID  *int            `json:"parentId,omitempty"`
+	IfVersion *int64          `json:"ifVersion,omitempty"`
+	Task      json.RawMessage `json:"task,omitempty"`
+}
+
+// Batch result statuses.
+const (
+	BatchApplied    = "applied"
+	BatchFailed     = "failed"
+	BatchRolledBack = "rolled_back" // applied, then undone by a later failure
+	BatchSkipped    = "skipped"     // not run because an earlier one failed
+)
+
+// BatchResult reports on the operation at the same index. Error holds the
+// problem details of a failed operation.
+type BatchResult struct {
+	Status string `json:"status"`
+	Task   *Task  `json:"task,omitempty"`
+	Error  any    `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
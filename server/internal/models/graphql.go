@@ -0,0 +1,34 @@
+package models
+
+import "encoding/json"
+
+// GraphQLRequest is a GraphQL operation sent over HTTP or the WebSocket.
+type GraphQLRequest struct {
+	Query string `json:"query" validate:"required"`
+	// OperationName picks the operation to run when Query has several.
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// GraphQLResponse holds the data of an operation and its errors. Fields
+// that failed are null in Data and listed in Errors.
+type GraphQLResponse struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []GraphQLError  `json:"errors,omitempty"`
+}
+
+// GraphQLError is one error of an operation. For the errors of the API,
+// Extensions holds the code and status of the matching problem type, e.g.
+// {"code": "version-conflict", "status": 409}.
+type GraphQLError struct {
+	Message    string            `json:"message"`
+	Locations  []GraphQLLocation `json:"locations,omitempty"`
+	Path       []any             `json:"path,omitempty"`
+	Extensions map[string]any    `json:"extensions,omitempty"`
+}
+
+// GraphQLLocation points into the query.
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Label tags tasks of the user who created it.
+type Label struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Comment is a note on a task by its owner or a member of its project.
+type Comment struct {
+	ID        int64     `json:"id"`
+	TaskID    int       `json:"taskId"`
+	UserID    int       `json:"userId"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Length limits in the validate tags match the labels and task_comments
+// tables.
+
+type CreateLabelRequest struct {
+	Name  string `json:"name" validate:"required,max=50"`
+	Color string `json:"color" validate:"max=32"` // any CSS color, e.g. #f5a623
+}
+
+// SetTaskLabelsRequest replaces the labels of a task.
+type SetTaskLabelsRequest struct {
+	LabelIDs []int `json:"labelIds"`
+}
+
+type CreateCommentRequest struct {
+	Body string `json:"body" validate:"required,maxbytes=65535"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// TOTPSetupResponse is returned when enrolment starts. The secret stays
+// inactive until it is confirmed with a code from the authenticator app.
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+type TOTPConfirmRequest struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+// RecoveryCodesResponse lists freshly generated recovery codes. They are
+// only ever shown once; the server keeps hashes.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// MFALoginRequest completes a login that returned an MFA challenge. Either
+// Code or RecoveryCode must be set.
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfaToken" validate:"required"`
+	Code         string `json:"code,omitempty" validate:"numeric,len=6"`
+	RecoveryCode string `json:"recoveryCode,omitempty" validate:"max=32"`
+}
+
+// MFAReauthRequest re-authenticates the user before sensitive 2FA changes.
+type MFAReauthRequest struct {
+	Password     string `json:"password" validate:"required,maxbytes=72"`
+	Code         string `json:"code,omitempty" validate:"numeric,len=6"`
+	RecoveryCode string `json:"recoveryCode,omitempty" validate:"max=32"`
+}
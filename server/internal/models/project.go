@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Project shares the tasks in it with its members. The owner is a member
+// too, and the only one who can add or remove others.
+type Project struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   int       `json:"ownerId"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	// Members is only returned for a single project.
+	Members []ProjectMember `json:"members,omitempty"`
+}
+
+// ProjectMember is a user who can see the tasks of a project.
+type ProjectMember struct {
+	UserID  int       `json:"userId"`
+	Name    string    `json:"name"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// Length limits in the validate tags match the projects and users tables.
+
+type CreateProjectRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type AddProjectMemberRequest struct {
+	Email string `json:"email" validate:"required,email,max=100"`
+}
+
+// SetTaskProjectRequest moves a task into a project, or out of its project
+// when projectId is null.
+type SetTaskProjectRequest struct {
+	ProjectID *int `json:"projectId"`
+}
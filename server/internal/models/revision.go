@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// TaskRevision is a task as it was stored by one of its changes. Changes
+// and DescriptionDiff compare it with the revision before; both are empty
+// for the first one.
+type TaskRevision struct {
+	Revision    int       `json:"revision"`
+	Version     int64     `json:"version"` // change sequence of the change
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Done        bool      `json:"done"`
+	ParentID    *int      `json:"parentId"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	Changes         map[string]AuditChange `json:"changes,omitempty"`
+	DescriptionDiff []DiffLine             `json:"descriptionDiff,omitempty"`
+}
+
+// TaskRevisionsResponse is a page of the revisions of a task, newest first.
+// Before is passed back to get the next page; it is absent on the last one.
+type TaskRevisionsResponse struct {
+	Revisions []TaskRevision `json:"revisions"`
+	Before    *int           `json:"before,omitempty"`
+}
+
+// Operations of a line diff.
+const (
+	DiffEqual  = "equal"
+	DiffDelete = "delete"
+	DiffInsert = "insert"
+)
+
+// DiffLine is one line of a line diff: kept, deleted from the old text or
+// inserted from the new one.
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+
+	"task-manager-server/internal/validation"
+)
+
+// SyncResponse lists what changed after a sync token.
+type SyncResponse struct {
+	Tasks   []Task        `json:"tasks"`
+	Deleted []DeletedTask `json:"deleted"`
+	// Token is passed as since to the next sync.
+	Token string `json:"token"`
+	// Full means Tasks holds every task: the client replaces its copy
+	// instead of merging.
+	Full bool `json:"full"`
+	// HasMore means the page was cut; sync again with Token right away.
+	HasMore bool `json:"hasMore"`
+}
+
+// Sync operations.
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// SyncRequest carries mutations a client made while offline, in the order
+// it made them.
+type SyncRequest struct {
+	Changes []SyncChange `json:"changes"`
+}
+
+// SyncChange is one offline mutation. Task holds a CreateTaskRequest for
+// creates and an UpdateTaskRequest for updates. BaseVersion is the version
+// of the task the client changed; when it no longer matches, the change is
+// a conflict and is not applied.
+type SyncChange struct {
+	Op          string          `json:"op" validate:"required,oneof=create update delete"`
+	ClientID    string          `json:"clientId,omitempty" validate:"max=64"`
+	ID          int             `json:"id,omitempty"`
+	BaseVersion *int64          `json:"baseVersion,omitempty"`
+	Task        json.RawMessage `json:"task,omitempty"`
+}
+
+// Sync result statuses.
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncNotFound = "not_found"
+	SyncInvalid  = "invalid"
+	SyncError    = "error" // not applied because of a server error; retry later
+)
+
+// SyncResult reports what happened to the change at the same index. Task is
+// the stored task when the change was applied, and the server's version on
+// a conflict.
+type SyncResult struct {
+	ClientID string            `json:"clientId,omitempty"`
+	ID       int               `json:"id,omitempty"`
+	Status   string            `json:"status"`
+	Task     *Task             `json:"task,omitempty"`
+	Errors   validation.Errors `json:"errors,omitempty"`
+}
+
+// SyncApplyResponse answers a SyncRequest.
+type SyncApplyResponse struct {
+	Results []SyncResult `json:"results"`
+}
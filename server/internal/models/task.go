@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Task struct {
 	ID          int       `json:"id"`
@@ -8,20 +11,61 @@ type Task struct {
 	Description string    `json:"description"`
 	Done        bool      `json:"done"`
 	UserID      int       `json:"userId"`
+	ParentID    *int      `json:"parentId"`  // nil for top-level tasks
+	ProjectID   *int      `json:"projectId"` // nil for tasks not shared in a project
+	Version     int64     `json:"version"`   // change sequence of the last change
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
+
+	// Relations embedded on request with include=.
+	Subtasks     []Task   `json:"subtasks,omitempty"`
+	Parent       *Task    `json:"parent,omitempty"`
+	SubtaskCount *int     `json:"subtaskCount,omitempty"`
+	Labels       []Label  `json:"labels,omitempty"`
+	CommentCount *int     `json:"commentCount,omitempty"`
+	Project      *Project `json:"project,omitempty"`
+}
+
+// DeletedTask identifies a task that no longer exists.
+type DeletedTask struct {
+	ID      int   `json:"id"`
+	Version int64 `json:"version"` // change sequence of the delete
+	// ProjectID is only set on the events of a delete, so the collaborators
+	// of the project learn about it.
+	ProjectID *int `json:"projectId,omitempty"`
 }
 
+// Length limits in the validate tags match the tasks table: title is a
+// VARCHAR(255) and description a TEXT column.
+
 type CreateTaskRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string `json:"title" validate:"required,max=255"`
+	Description string `json:"description" validate:"maxbytes=65535"`
 	Done        bool   `json:"done"`
+	ParentID    *int   `json:"parentId,omitempty"` // creates a subtask
 }
 
 // UpdateTaskRequest allows partial updates of a task. Fields are pointers
 // so we can distinguish between "not provided" and zero values.
 type UpdateTaskRequest struct {
-	Title       *string `json:"title,omitempty"`
-	Description *string `json:"description,omitempty"`
+	Title       *string `json:"title,omitempty" validate:"notblank,max=255"`
+	Description *string `json:"description,omitempty" validate:"maxbytes=65535"`
 	Done        *bool   `json:"done,omitempty"`
 }
+
+// TaskMergePatch documents a JSON Merge Patch of a task. Members set to null
+// are removed, which clears description and parentId.
+type TaskMergePatch struct {
+	Title       *string `json:"title,omitempty" validate:"max=255"`
+	Description *string `json:"description,omitempty" validate:"maxbytes=65535"`
+	Done        *bool   `json:"done,omitempty"`
+	ParentID    *int    `json:"parentId,omitempty"`
+}
+
+// PatchOperation documents one operation of a JSON Patch of a task.
+type PatchOperation struct {
+	Op    string          `json:"op" validate:"required,oneof=add remove replace move copy test"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
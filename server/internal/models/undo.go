@@ -0,0 +1,25 @@
+package models
+
+// UndoResponse tells what an undo or redo restored. Commands lists the
+// commands it went through in order; Command is the first of them.
+type UndoResponse struct {
+	Command        string         `json:"command"` // e.g. "update" or "batch"
+	Commands       []string       `json:"commands"`
+	Tasks          []Task         `json:"tasks"`          // the tasks it changed or recreated
+	DeletedTaskIDs []int          `json:"deletedTaskIds"` // the tasks it deleted
+	Conflicts      []UndoConflict `json:"conflicts,omitempty"`
+}
+
+// Reasons of an UndoConflict.
+const (
+	ConflictChanged = "changed"
+	ConflictDeleted = "deleted"
+)
+
+// UndoConflict is a part of a command that was left alone because the task
+// changed since: Fields changed again, or the task was deleted.
+type UndoConflict struct {
+	TaskID int      `json:"taskId"`
+	Reason string   `json:"reason"`
+	Fields []string `json:"fields,omitempty"`
+}
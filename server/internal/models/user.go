@@ -3,25 +3,65 @@ package models
 import "time"
 
 type User struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"emailVerified"`
+	IsAdmin       bool      `json:"isAdmin"`
+	Password      string    `json:"-"`
+	CreatedAt     time.Time `json:"createdAt"`
 }
 
+// Length limits in the validate tags match the users table columns; bcrypt
+// ignores anything past 72 bytes of a password.
+
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,max=100"`
+	Password string `json:"password" validate:"required,maxbytes=72"`
 }
 
 type RegisterRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=100"`
+	Password string `json:"password" validate:"required,password,maxbytes=72"`
 }
 
 type AuthResponse struct {
-	User  User   `json:"user"`
-	Token string `json:"token"`
+	User  *User  `json:"user,omitempty"`
+	Token string `json:"token,omitempty"`
+	// When the account has two-factor authentication enabled, login returns
+	// only these two fields; MFAToken is exchanged at /api/v1/login/mfa.
+	MFARequired bool   `json:"mfaRequired,omitempty"`
+	MFAToken    string `json:"mfaToken,omitempty"`
+}
+
+// EmailRequest is used by the endpoints that send a link to an address,
+// such as resending the verification email or starting a password reset.
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email,max=100"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,password,maxbytes=72"`
+}
+
+type UnlockAccountRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// AdminUnlockRequest lifts the lockout of an account, an IP address, or both.
+type AdminUnlockRequest struct {
+	Email string `json:"email,omitempty" validate:"max=100"`
+	IP    string `json:"ip,omitempty" validate:"ip"`
+}
+
+// MessageResponse is returned by endpoints that have nothing to send back
+// but a confirmation.
+type MessageResponse struct {
+	Message string `json:"message"`
 }
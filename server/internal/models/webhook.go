@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivers the events of a user's tasks to a URL.
+type Webhook struct {
+	ID     int      `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret signs the deliveries. It is only returned when the webhook is
+	// created.
+	Secret string `json:"secret,omitempty"`
+	// Active is false once the webhook was disabled, by the user or after
+	// too many failed deliveries in a row.
+	Active bool `json:"active"`
+	// Failures counts the failed delivery attempts since the last success.
+	Failures   int        `json:"failures"`
+	DisabledAt *time.Time `json:"disabledAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,max=2048"`
+	Events []string `json:"events"`
+}
+
+// UpdateWebhookRequest changes the fields that are provided. Setting
+// active to true re-enables a disabled webhook.
+type UpdateWebhookRequest struct {
+	URL    *string  `json:"url,omitempty" validate:"notblank,max=2048"`
+	Events []string `json:"events,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook.
+type WebhookDelivery struct {
+	ID        int    `json:"id"`
+	WebhookID int    `json:"webhookId"`
+	EventID   string `json:"eventId"`
+	Event     string `json:"event"`
+	// Payload is the request body, the event as streamed by /events.
+	Payload json.RawMessage `json:"payload"`
+	// Status is pending until the receiver answers with a 2xx, or failed
+	// once every attempt was used.
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	// NextAttemptAt is set while the delivery is pending.
+	NextAttemptAt *time.Time `json:"nextAttemptAt"`
+	// ResponseStatus and Error describe the last attempt.
+	ResponseStatus *int       `json:"responseStatus"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DeliveredAt    *time.Time `json:"deliveredAt"`
+}
@@ -0,0 +1,35 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+//go:embed docs.html
+var docsPage string
+
+// SpecHandler serves the document as JSON.
+func (d *Document) SpecHandler() http.HandlerFunc {
+	body, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		panic("openapi: cannot encode document: " + err.Error())
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}
+}
+
+// DocsHandler serves a self-contained page that renders the document found
+// at specURL. It needs no external assets, so it works offline.
+func DocsHandler(specURL string) http.HandlerFunc {
+	page := strings.ReplaceAll(docsPage, "{{SPEC_URL}}", specURL)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	}
+}
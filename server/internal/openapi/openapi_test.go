@@ -0,0 +1,81 @@
+package openapi_test
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/handlers"
+	"task-manager-server/internal/idempotency"
+	"task-manager-server/internal/routes"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata/openapi.json with the current document")
+
+const golden = "testdata/openapi.json"
+
+// TestSpecMatchesGolden builds the document from the routes that are
+// served and fails when it differs from the committed one. After an
+// intended change to the API, review the diff and run
+//
+//	go test ./internal/openapi -update
+func TestSpecMatchesGolden(t *testing.T) {
+	handler := routes.SetupRoutes(
+		handlers.NewAuthHandler(nil),
+		handlers.NewTaskHandler(nil),
+		handlers.NewEventsHandler(nil),
+		handlers.NewCollabHandler(nil, nil, nil),
+		handlers.NewSyncHandler(nil),
+		handlers.NewWebhookHandler(nil),
+		handlers.NewProjectHandler(nil, nil, nil),
+		handlers.NewAuditHandler(nil),
+		handlers.NewGraphQLHandler(nil),
+		idempotency.NewMemoryStore(),
+		config.APIConfig{},
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/openapi.json: status %d", rec.Code)
+	}
+	got := rec.Body.Bytes()
+
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("reading %s: %v (run with -update to create it)", golden, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("the OpenAPI document no longer matches %s; review the change and run go test ./internal/openapi -update\n%s", golden, firstDiff(want, got))
+	}
+}
+
+// firstDiff shows the first line that differs between want and got.
+func firstDiff(want, got []byte) string {
+	wl, gl := bytes.Split(want, []byte("\n")), bytes.Split(got, []byte("\n"))
+	for i := 0; i < max(len(wl), len(gl)); i++ {
+		var w, g []byte
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if !bytes.Equal(w, g) {
+			return "line " + strconv.Itoa(i+1) + ":\n- " + string(w) + "\n+ " + string(g)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema (2020-12, as used by OpenAPI 3.1)
+// that the generator emits and the validator understands.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"` // string or []string
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MaxBytes             *int               `json:"x-maxBytes,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties any                `json:"additionalProperties,omitempty"`
+	Description          string             `json:"description,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor returns the schema of t. Named structs are added to components
+// and referenced, so each model appears once in the document.
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var s *Schema
+	switch {
+	case t == timeType:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		// Any JSON value; the model documents what it holds.
+		s = &Schema{}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		d.addComponent(t)
+		s = &Schema{Ref: "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Struct:
+		s = d.structSchema(t)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s = &Schema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case t.Kind() == reflect.Map:
+		s = &Schema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem())}
+	case t.Kind() == reflect.String:
+		s = &Schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		s = &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = &Schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = &Schema{Type: "number"}
+	default:
+		s = &Schema{}
+	}
+
+	// Pointers to named structs stay plain references; pointers to scalars
+	// become nullable since the API can send null for them.
+	if nullable && s.Ref == "" {
+		if typ, ok := s.Type.(string); ok {
+			s.Type = []string{typ, "null"}
+		}
+	}
+	return s
+}
+
+func (d *Document) addComponent(t reflect.Type) {
+	if _, ok := d.Components.Schemas[t.Name()]; ok {
+		return
+	}
+	// Reserve the name first so self-referencing models terminate.
+	d.Components.Schemas[t.Name()] = &Schema{}
+	*d.Components.Schemas[t.Name()] = *d.structSchema(t)
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := d.schemaFor(f.Type)
+		if applyValidateTag(prop, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		} else if f.Tag.Get("validate") == "" && !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			// Response fields without omitempty are always sent.
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// applyValidateTag translates validation rules into schema keywords and
+// reports whether the field is required.
+func applyValidateTag(s *Schema, tag string) bool {
+	if tag == "" {
+		return false
+	}
+
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		rule, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		n, _ := strconv.Atoi(arg)
+
+		switch rule {
+		case "required":
+			required = true
+			s.MinLength = intPtr(1)
+		case "notblank":
+			s.MinLength = intPtr(1)
+		case "email":
+			s.Format = "email"
+		case "min":
+			s.MinLength = intPtr(n)
+		case "max":
+			s.MaxLength = intPtr(n)
+		case "maxbytes":
+			s.MaxBytes = intPtr(n)
+		case "len":
+			s.MinLength, s.MaxLength = intPtr(n), intPtr(n)
+		case "numeric":
+			s.Pattern = "^[0-9]*$"
+		case "password":
+			s.MinLength = intPtr(8)
+			s.Description = "At least 8 characters with a letter and a digit"
+		case "ip":
+			s.Description = "IPv4 or IPv6 address"
+		case "oneof":
+			s.Enum = strings.Fields(arg)
+		}
+	}
+	return required
+}
+
+func intPtr(n int) *int {
+	return &n
+}
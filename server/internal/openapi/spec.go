@@ -0,0 +1,256 @@
+// Package openapi derives an OpenAPI 3.1 document from the route table and
+// the models, serves it together with an offline documentation page, and
+// can validate traffic against it during development.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Operation is what the generator needs to know about one route.
+type Operation struct {
+	Method   string
+	Path     string // full ServeMux path, e.g. "/api/v1/tasks/{id}"
+	Summary  string
+	Tag      string
+	Auth     bool
+	Request  any // zero value of the request model, nil when there is no body
+	Response any // zero value of the response model
+	Status   int // success status, 200 when zero
+
+	// ContentType of the success response, application/json when empty.
+	// Responses that are not JSON are documented as strings.
+	ContentType string
+	// RequestTypes holds the request models by media type for operations
+	// that accept bodies other than JSON. It replaces Request.
+	RequestTypes map[string]any
+	// Idempotent operations accept an Idempotency-Key header.
+	Idempotent bool
+	// Sparse operations accept the fields and include query parameters,
+	// so their responses may leave out fields.
+	Sparse bool
+}
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	// operations indexes the operations by "METHOD path" for the validator.
+	operations map[string]*OperationObject
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type PathItem map[string]*OperationObject
+
+type OperationObject struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+
+	sparse bool
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+var pathParam = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// Build creates the document for the given operations.
+func Build(info Info, ops []Operation) *Document {
+	d := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		operations: map[string]*OperationObject{},
+	}
+	d.Components.Schemas["Problem"] = problemSchema()
+
+	for _, op := range ops {
+		d.addOperation(op)
+	}
+	return d
+}
+
+func (d *Document) addOperation(op Operation) {
+	path := pathParam.ReplaceAllString(op.Path, "{$1}")
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+
+	o := &OperationObject{
+		OperationID: operationID(op.Method, op.Path),
+		Summary:     op.Summary,
+		Responses:   map[string]*Response{},
+	}
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+	}
+
+	for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		schema := &Schema{Type: "string"}
+		if m[1] == "id" || strings.HasSuffix(m[1], "Id") {
+			schema = &Schema{Type: "integer"}
+		}
+		o.Parameters = append(o.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: schema})
+	}
+
+	if op.Sparse {
+		o.sparse = true
+		o.Parameters = append(o.Parameters,
+			Parameter{
+				Name:        "fields",
+				In:          "query",
+				Description: "Comma separated fields to return, e.g. id,title,done",
+				Schema:      &Schema{Type: "string"},
+			},
+			Parameter{
+				Name:        "include",
+				In:          "query",
+				Description: "Comma separated relations to embed: subtasks, parent, subtaskCount",
+				Schema:      &Schema{Type: "string"},
+			},
+		)
+	}
+
+	if op.Idempotent {
+		o.Parameters = append(o.Parameters, Parameter{
+			Name:   "Idempotency-Key",
+			In:     "header",
+			Schema: &Schema{Type: "string", MaxLength: intPtr(255)},
+		})
+	}
+
+	requests := op.RequestTypes
+	if requests == nil && op.Request != nil {
+		requests = map[string]any{"application/json": op.Request}
+	}
+	if len(requests) > 0 {
+		o.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{}}
+		for mediaType, model := range requests {
+			o.RequestBody.Content[mediaType] = &MediaType{Schema: d.schemaFor(reflect.TypeOf(model))}
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	switch {
+	case op.ContentType != "" && op.ContentType != "application/json":
+		success.Content = map[string]*MediaType{
+			op.ContentType: {Schema: &Schema{Type: "string"}},
+		}
+	case op.Response != nil:
+		success.Content = map[string]*MediaType{
+			"application/json": {Schema: d.schemaFor(reflect.TypeOf(op.Response))},
+		}
+	}
+	o.Responses[strconv.Itoa(status)] = success
+	o.Responses["default"] = &Response{
+		Description: "Problem details",
+		Content: map[string]*MediaType{
+			"application/problem+json": {Schema: &Schema{Ref: "#/components/schemas/Problem"}},
+		},
+	}
+
+	if op.Auth {
+		o.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+
+	(*item)[strings.ToLower(op.Method)] = o
+	d.operations[op.Method+" "+op.Path] = o
+}
+
+// operationID turns "GET /api/v1/tasks/{id}" into "getTasksById".
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" || seg == "api" || (len(seg) > 1 && seg[0] == 'v' && seg[1] >= '0' && seg[1] <= '9') {
+			continue
+		}
+		if m := pathParam.FindStringSubmatch(seg); m != nil {
+			seg = "by-" + m[1]
+		}
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool { return r == '-' || r == '_' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+func problemSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"type":     {Type: "string"},
+			"title":    {Type: "string"},
+			"status":   {Type: "integer"},
+			"detail":   {Type: "string"},
+			"instance": {Type: "string"},
+			"traceId":  {Type: "string"},
+			"errors": {Type: "array", Items: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"field":   {Type: "string"},
+					"code":    {Type: "string"},
+					"message": {Type: "string"},
+				},
+			}},
+		},
+		Required: []string{"type", "title", "status", "traceId"},
+	}
+}
@@ -0,0 +1,269 @@
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/response"
+)
+
+// maxCapture bounds the bodies the validator buffers.
+const maxCapture = 1 << 20
+
+// Middleware validates the traffic of one operation against the document.
+// It is meant for development:
+//
+//   - request bodies are checked for shape (types, objects, arrays) and
+//     rejected with a 400 problem when they do not match. Content rules such
+//     as lengths or formats are left to the handlers, which report them as
+//     validation errors just like in production.
+//   - responses are checked against every keyword and mismatches are logged,
+//     which catches handlers drifting away from the documented models.
+func (d *Document) Middleware(method, path string) func(http.Handler) http.Handler {
+	op, ok := d.operations[method+" "+path]
+	if !ok {
+		panic(fmt.Sprintf("openapi: no operation for %s %s", method, path))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if op.RequestBody != nil && r.Body != nil {
+				body, err := io.ReadAll(io.LimitReader(r.Body, maxCapture))
+				if err != nil {
+					response.Error(w, r, http.StatusBadRequest, "Invalid request body")
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+
+				// Bodies of undocumented media types are left to the
+				// handler, which rejects them.
+				content := requestContent(op.RequestBody, r.Header.Get("Content-Type"))
+				var v any
+				if content != nil && json.Unmarshal(body, &v) == nil {
+					if errs := d.validate(content.Schema, v, "body", false); len(errs) > 0 {
+						response.WriteProblem(w, r, &response.Problem{
+							Type:   response.TypeURI("openapi-violation"),
+							Title:  "Request does not match the API specification",
+							Status: http.StatusBadRequest,
+							Detail: errs[0],
+							Errors: errs,
+						})
+						return
+					}
+				}
+			}
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			// Sparse responses only have the fields asked for.
+			query := r.URL.Query()
+			strict := !op.sparse || (!query.Has("fields") && !query.Has("include"))
+			d.checkResponse(r.Context(), op, method, path, rec, strict)
+		})
+	}
+}
+
+// requestContent returns the documented body for a Content-Type. Requests
+// without one are taken to be JSON.
+func requestContent(body *RequestBody, contentType string) *MediaType {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	if mediaType == "" {
+		mediaType = "application/json"
+	}
+	return body.Content[mediaType]
+}
+
+func (d *Document) checkResponse(ctx context.Context, op *OperationObject, method, path string, rec *recorder, strict bool) {
+	logger := logging.FromContext(ctx).With("method", method, "route", path, "status", rec.status)
+	if rec.truncated || rec.body.Len() == 0 {
+		return
+	}
+
+	res, ok := op.Responses[strconv.Itoa(rec.status)]
+	if !ok {
+		res = op.Responses["default"]
+		if rec.status < 400 {
+			logger.Warn("OpenAPI: undocumented status")
+			return
+		}
+	}
+
+	mediaType, _, _ := strings.Cut(rec.Header().Get("Content-Type"), ";")
+	content, ok := res.Content[mediaType]
+	if !ok {
+		logger.Warn("OpenAPI: undocumented content type", "contentType", mediaType)
+		return
+	}
+	if !strings.HasSuffix(mediaType, "json") {
+		return
+	}
+
+	var v any
+	if err := json.Unmarshal(rec.body.Bytes(), &v); err != nil {
+		logger.Warn("OpenAPI: invalid JSON response", "err", err)
+		return
+	}
+	for _, e := range d.validate(content.Schema, v, "response", strict) {
+		logger.Warn("OpenAPI: response does not match the schema", "violation", e)
+	}
+}
+
+// validate checks v, a decoded JSON value, against s. When strict is false
+// only the shape is checked.
+func (d *Document) validate(s *Schema, v any, at string, strict bool) []string {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		return d.validate(d.Components.Schemas[name], v, at, strict)
+	}
+
+	if s.Type != nil && !typeMatches(s.Type, v) {
+		return []string{fmt.Sprintf("%s must be of type %v", at, s.Type)}
+	}
+
+	var errs []string
+	switch val := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok && strict {
+				errs = append(errs, fmt.Sprintf("%s.%s is required", at, name))
+			}
+		}
+		for name, prop := range val {
+			if ps, ok := s.Properties[name]; ok {
+				errs = append(errs, d.validate(ps, prop, at+"."+name, strict)...)
+			} else if ap, ok := s.AdditionalProperties.(*Schema); ok {
+				errs = append(errs, d.validate(ap, prop, at+"."+name, strict)...)
+			}
+		}
+	case []any:
+		for i, item := range val {
+			errs = append(errs, d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i), strict)...)
+		}
+	case string:
+		if strict {
+			errs = append(errs, checkString(s, val, at)...)
+		}
+	}
+	return errs
+}
+
+func checkString(s *Schema, v, at string) []string {
+	var errs []string
+	n := utf8.RuneCountInString(v)
+	if s.MinLength != nil && n < *s.MinLength {
+		errs = append(errs, fmt.Sprintf("%s must be at least %d characters", at, *s.MinLength))
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		errs = append(errs, fmt.Sprintf("%s must be at most %d characters", at, *s.MaxLength))
+	}
+	if s.MaxBytes != nil && len(v) > *s.MaxBytes {
+		errs = append(errs, fmt.Sprintf("%s must be at most %d bytes", at, *s.MaxBytes))
+	}
+	if s.Pattern != "" {
+		if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+			errs = append(errs, fmt.Sprintf("%s must match %s", at, s.Pattern))
+		}
+	}
+	if s.Format == "email" && !strings.Contains(v, "@") {
+		errs = append(errs, fmt.Sprintf("%s must be an email address", at))
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, v) {
+		errs = append(errs, fmt.Sprintf("%s must be one of %s", at, strings.Join(s.Enum, ", ")))
+	}
+	return errs
+}
+
+func typeMatches(t any, v any) bool {
+	switch t := t.(type) {
+	case string:
+		return jsonTypeIs(t, v)
+	case []string:
+		for _, typ := range t {
+			if jsonTypeIs(typ, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func jsonTypeIs(t string, v any) bool {
+	switch t {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+// recorder passes the response through while keeping a copy of the body.
+type recorder struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.body.Len()+len(b) <= maxCapture {
+		r.body.Write(b)
+	} else {
+		r.truncated = true
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades through.
+func (r *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(r.ResponseWriter).Hijack()
+}
+
+func (r *recorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,141 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/response"
+)
+
+type noteRequest struct {
+	Title string   `json:"title" validate:"required,max=5"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+type note struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+}
+
+func testDocument() *Document {
+	return Build(Info{Title: "Notes", Version: "v1"}, []Operation{
+		{Method: http.MethodPost, Path: "/notes", Request: noteRequest{}, Response: note{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/notes/{id}", Response: note{}, Sparse: true},
+	})
+}
+
+// validated serves handler behind the validator of one operation and
+// returns the response and what the validator logged.
+func validated(method, path string, handler http.HandlerFunc, r *http.Request) (*httptest.ResponseRecorder, string, bool) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	r = r.WithContext(logging.WithLogger(r.Context(), logger))
+
+	called := false
+	h := testDocument().Middleware(method, path)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		handler(w, r)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w, logs.String(), called
+}
+
+func created(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusCreated, note{ID: 1, Title: "a"})
+}
+
+func TestMiddlewareRejectsRequestOfWrongShape(t *testing.T) {
+	for _, body := range []string{`{"title": 5}`, `{"title": "a", "tags": "x"}`, `[]`} {
+		r := httptest.NewRequest(http.MethodPost, "/notes", strings.NewReader(body))
+		w, _, called := validated(http.MethodPost, "/notes", created, r)
+
+		if called {
+			t.Errorf("%s reached the handler", body)
+		}
+		var p response.Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusBadRequest || p.Type != "/problems/openapi-violation" {
+			t.Errorf("%s: %d %s, want 400 /problems/openapi-violation", body, w.Code, p.Type)
+		}
+	}
+}
+
+// Content rules are reported by the handlers as validation errors, as in
+// production, so the validator lets such requests through.
+func TestMiddlewareLeavesContentRulesToHandlers(t *testing.T) {
+	tests := []struct {
+		body, contentType string
+	}{
+		{`{"title": "far too long"}`, "application/json"},
+		{`{}`, "application/json"},
+		{`title=a`, "application/x-www-form-urlencoded"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/notes", strings.NewReader(tt.body))
+		r.Header.Set("Content-Type", tt.contentType)
+		if _, _, called := validated(http.MethodPost, "/notes", created, r); !called {
+			t.Errorf("%s (%s) did not reach the handler", tt.body, tt.contentType)
+		}
+	}
+}
+
+func TestMiddlewareLogsResponsesThatDoNotMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantLog string
+	}{
+		{"matching", `{"id": 1, "title": "a"}`, ""},
+		{"wrong type", `{"id": "1", "title": "a"}`, "response.id must be of type integer"},
+		{"missing field", `{"id": 1}`, "response.title is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.body))
+			}
+			w, logs, _ := validated(http.MethodGet, "/notes/{id}", handler, httptest.NewRequest(http.MethodGet, "/notes/1", nil))
+
+			if w.Body.String() != tt.body {
+				t.Errorf("the response was changed to %s", w.Body)
+			}
+			if tt.wantLog == "" && logs != "" {
+				t.Errorf("logged %s for a matching response", logs)
+			}
+			if tt.wantLog != "" && !strings.Contains(logs, tt.wantLog) {
+				t.Errorf("logs = %s, want %q", logs, tt.wantLog)
+			}
+		})
+	}
+}
+
+// Sparse responses only hold the fields asked for.
+func TestMiddlewareAcceptsSparseResponses(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		response.JSON(w, http.StatusOK, map[string]any{"id": 1})
+	}
+	r := httptest.NewRequest(http.MethodGet, "/notes/1?fields=id", nil)
+	if _, logs, _ := validated(http.MethodGet, "/notes/{id}", handler, r); logs != "" {
+		t.Errorf("logged %s for a sparse response", logs)
+	}
+}
+
+func TestMiddlewarePanicsOnUndocumentedOperation(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for an operation the document does not have")
+		}
+	}()
+	testDocument().Middleware(http.MethodDelete, "/notes/{id}")
+}
@@ -0,0 +1,345 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON documents.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Media types of the two patch formats.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalid is returned for patch documents that are not well formed.
+	ErrInvalid = errors.New("invalid patch document")
+	// ErrTestFailed is returned when a test operation does not match.
+	ErrTestFailed = errors.New("patch test failed")
+	// ErrUnprocessable is returned when a well-formed patch cannot be
+	// applied to the document, e.g. because a path does not exist.
+	ErrUnprocessable = errors.New("patch cannot be applied")
+)
+
+// Merge applies a merge patch to doc. Members set to null in the patch are
+// removed; objects are merged recursively and any other value replaces the
+// member.
+func Merge(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = merge(t[name], value)
+		}
+	}
+	return t
+}
+
+// operation is one operation of a JSON Patch.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies a JSON Patch to doc. The operations run in order and the
+// patch is applied as a whole or not at all.
+func Apply(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: a JSON Patch must be an array of operations", ErrInvalid)
+	}
+
+	for i, op := range ops {
+		target, err = apply(target, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %q): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func apply(doc any, op operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: value is required", ErrInvalid)
+		}
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			if doc, _, err = remove(doc, path); err != nil {
+				return nil, err
+			}
+			return add(doc, path, value)
+		}
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(current, value) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if op.Op == "move" {
+			if isPrefix(from, path) && len(from) < len(path) {
+				return nil, fmt.Errorf("%w: cannot move a value into itself", ErrUnprocessable)
+			}
+			doc, value, err = remove(doc, from)
+		} else {
+			value, err = get(doc, from)
+			// Copies must not share containers with the original.
+			value = clone(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	}
+	return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalid, op.Op)
+}
+
+// add sets the value at path, inserting into arrays.
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+		return doc, nil
+	case []any:
+		i := len(p)
+		if last != "-" {
+			if i, err = index(last, len(p)); err != nil {
+				return nil, err
+			}
+		}
+		grown := append(p[:i:i], append([]any{value}, p[i:]...)...)
+		return replaceContainer(doc, path[:len(path)-1], grown)
+	}
+	return nil, fmt.Errorf("%w: %s is not a container", ErrUnprocessable, formatPointer(path[:len(path)-1]))
+}
+
+// remove deletes the value at path and returns it.
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch p := parent.(type) {
+	case map[string]any:
+		value, ok := p[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s does not exist", ErrUnprocessable, formatPointer(path))
+		}
+		delete(p, last)
+		return doc, value, nil
+	case []any:
+		i, err := index(last, len(p)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := p[i]
+		shrunk := append(p[:i:i], p[i+1:]...)
+		doc, err = replaceContainer(doc, path[:len(path)-1], shrunk)
+		return doc, value, err
+	}
+	return nil, nil, fmt.Errorf("%w: %s does not exist", ErrUnprocessable, formatPointer(path))
+}
+
+// get returns the value at path.
+func get(doc any, path []string) (any, error) {
+	current := doc
+	for i, token := range path {
+		switch c := current.(type) {
+		case map[string]any:
+			value, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s does not exist", ErrUnprocessable, formatPointer(path[:i+1]))
+			}
+			current = value
+		case []any:
+			n, err := index(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			current = c[n]
+		default:
+			return nil, fmt.Errorf("%w: %s does not exist", ErrUnprocessable, formatPointer(path[:i+1]))
+		}
+	}
+	return current, nil
+}
+
+// replaceContainer stores a resized array at path, since resizing may
+// have moved it.
+func replaceContainer(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+	case []any:
+		i, _ := index(last, len(p)-1)
+		p[i] = value
+	}
+	return doc, nil
+}
+
+// index parses an array index of at most max.
+func index(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrUnprocessable, token)
+	}
+	i := 0
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%w: invalid array index %q", ErrUnprocessable, token)
+		}
+		i = i*10 + int(c-'0')
+		if i > max {
+			return 0, fmt.Errorf("%w: array index %s is out of range", ErrUnprocessable, token)
+		}
+	}
+	return i, nil
+}
+
+// equal compares JSON values; numbers are compared by value.
+func equal(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for name, value := range a {
+			other, ok := b[name]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, _, errA := big.ParseFloat(string(a), 10, 256, big.ToNearestEven)
+		y, _, errB := big.ParseFloat(string(b), 10, 256, big.ToNearestEven)
+		return errA == nil && errB == nil && x.Cmp(y) == 0
+	}
+	return a == b
+}
+
+func clone(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for name, value := range v {
+			c[name] = clone(value)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, value := range v {
+			c[i] = clone(value)
+		}
+		return c
+	}
+	return v
+}
+
+// decode parses a JSON value, keeping numbers exact.
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return v, nil
+}
@@ -0,0 +1,121 @@
+package patch
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// The pointers of RFC 6901 section 5, plus the escape ordering case.
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		pointer string
+		want    []string
+	}{
+		{"", nil},
+		{"/foo", []string{"foo"}},
+		{"/foo/0", []string{"foo", "0"}},
+		{"/", []string{""}},
+		{"/a~1b", []string{"a/b"}},
+		{"/c%d", []string{"c%d"}},
+		{"/e^f", []string{"e^f"}},
+		{"/g|h", []string{"g|h"}},
+		{`/i\j`, []string{`i\j`}},
+		{`/k"l`, []string{`k"l`}},
+		{"/ ", []string{" "}},
+		{"/m~0n", []string{"m~n"}},
+		{"/~01", []string{"~1"}},
+	}
+	for _, tt := range tests {
+		got, err := parsePointer(tt.pointer)
+		if err != nil {
+			t.Fatalf("parsePointer(%q): %v", tt.pointer, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parsePointer(%q) = %q, want %q", tt.pointer, got, tt.want)
+		}
+		if tt.pointer != "" {
+			if back := formatPointer(got); back != tt.pointer {
+				t.Errorf("formatPointer(%q) = %q, want %q", got, back, tt.pointer)
+			}
+		}
+	}
+}
+
+func TestParsePointerRejectsRelative(t *testing.T) {
+	if _, err := parsePointer("foo/bar"); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("err = %v, want ErrInvalid", err)
+	}
+}
+
+// The document of RFC 6901 section 5 resolves every pointer to the listed
+// value.
+func TestGetRFC6901Examples(t *testing.T) {
+	doc, err := decode([]byte(`{
+		"foo": ["bar", "baz"],
+		"": 0,
+		"a/b": 1,
+		"c%d": 2,
+		"e^f": 3,
+		"g|h": 4,
+		"i\\j": 5,
+		"k\"l": 6,
+		" ": 7,
+		"m~n": 8
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pointer string
+		want    string
+	}{
+		{"/foo", `["bar", "baz"]`},
+		{"/foo/0", `"bar"`},
+		{"/", `0`},
+		{"/a~1b", `1`},
+		{"/c%d", `2`},
+		{"/e^f", `3`},
+		{"/g|h", `4`},
+		{`/i\j`, `5`},
+		{`/k"l`, `6`},
+		{"/ ", `7`},
+		{"/m~0n", `8`},
+	}
+	for _, tt := range tests {
+		path, err := parsePointer(tt.pointer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := get(doc, path)
+		if err != nil {
+			t.Fatalf("get(%q): %v", tt.pointer, err)
+		}
+		want, err := decode([]byte(tt.want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equal(got, want) {
+			t.Errorf("get(%q) = %v, want %s", tt.pointer, got, tt.want)
+		}
+	}
+}
+
+func TestIsPrefix(t *testing.T) {
+	tests := []struct {
+		prefix, path []string
+		want         bool
+	}{
+		{nil, []string{"a"}, true},
+		{[]string{"a"}, []string{"a", "b"}, true},
+		{[]string{"a", "b"}, []string{"a", "b"}, true},
+		{[]string{"a", "b"}, []string{"a"}, false},
+		{[]string{"a"}, []string{"ab"}, false},
+	}
+	for _, tt := range tests {
+		if got := isPrefix(tt.prefix, tt.path); got != tt.want {
+			t.Errorf("isPrefix(%q, %q) = %v, want %v", tt.prefix, tt.path, got, tt.want)
+		}
+	}
+}
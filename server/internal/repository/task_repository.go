@@ -2,52 +2,150 @@ package repository
 
 import (
 	"database/sql"
+	"time"
 
 	"task-manager-server/internal/models"
 )
 
+// TaskColumns are scanned by ScanTask.
+const TaskColumns = `id, title, description, done, user_id, parent_id, project_id, change_seq, created_at, updated_at`
+
+// TaskReader reads tasks on the pool or inside a transaction.
+type TaskReader interface {
+	// GetByID returns one of the user's tasks, or nil when there is none.
+	// forUpdate locks the row until the transaction ends.
+	GetByID(id, userID int, forUpdate bool) (*models.Task, error)
+	// GetShared returns a task the user owns or that is in one of the
+	// user's projects, or nil when there is none.
+	GetShared(id, userID int) (*models.Task, error)
+	// GetInProject returns the tasks of a project, newest first.
+	GetInProject(projectID int) ([]models.Task, error)
+	// GetChildren returns the subtasks of a task, locked like GetByID.
+	GetChildren(parentID, userID int, forUpdate bool) ([]*models.Task, error)
+	// GetChangedSince returns up to limit of the user's tasks changed after
+	// the change sequence number since, in sequence order.
+	GetChangedSince(userID int, since int64, limit int) ([]models.Task, error)
+	// GetDeletedSince returns up to limit of the user's tombstones with a
+	// change sequence number after since, in sequence order.
+	GetDeletedSince(userID int, since int64, limit int) ([]models.DeletedTask, error)
+	// GetSequence returns the user's last change sequence number and how
+	// far the user's tombstones were purged; both are 0 for a user who
+	// never changed a task.
+	GetSequence(userID int) (current, purgedThrough int64, err error)
+	// GetExpiredTombstones returns, for every user with tombstones of tasks
+	// deleted before cutoff, the change sequence number of the newest one.
+	GetExpiredTombstones(cutoff time.Time) (map[int]int64, error)
+}
+
+// TaskRepository reads and writes tasks. Only Tx.Tasks hands it out, so its
+// writes are always part of a unit of work, in which TaskService also records
+// each change in the audit log, the revisions and the undo journal.
 type TaskRepository interface {
+	TaskReader
+	// NextVersion takes the next number of the user's change sequence,
+	// which every change to one of their tasks is stamped with. The user's
+	// counter row stays locked until the transaction ends, so their changes
+	// become visible in sequence order and a sync token can never skip a
+	// change that commits later. Other users' writes do not wait for it.
+	NextVersion(userID int) (int64, error)
+	// Create inserts task and sets its ID.
 	Create(task *models.Task) error
-	GetByUserID(userID int) ([]*models.Task, error)
-	GetByID(id int) (*models.Task, error)
+	// Update stores the title, description, done state, parent, project,
+	// version and update time of one of the user's tasks.
 	Update(task *models.Task) error
-	Delete(id int) error
+	// Delete removes one of the user's tasks and leaves a tombstone stamped
+	// with version, so syncing clients learn about the delete.
+	Delete(id, userID int, version int64, deletedAt time.Time) error
+	// PurgeTombstones drops the user's tombstones up to the change sequence
+	// number through and records that they were purged.
+	PurgeTombstones(userID int, through int64) (int64, error)
 }
 
 type taskRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewTaskRepository(db *sql.DB) TaskRepository {
+// NewTaskRepository returns a reader running its statements on db, which is
+// the pool or a transaction.
+func NewTaskRepository(db DBTX) TaskReader {
 	return &taskRepository{db: db}
 }
 
-func (r *taskRepository) Create(task *models.Task) error {
+func (r *taskRepository) GetByID(id, userID int, forUpdate bool) (*models.Task, error) {
 	query := `
-		INSERT INTO tasks (title, description, completed, user_id)
-		VALUES (?, ?, ?, ?)
+		SELECT ` + TaskColumns + `
+		FROM tasks
+		WHERE id = ? AND user_id = ?
+		LIMIT 1
 	`
-	result, err := r.db.Exec(query, task.Title, task.Description, task.Done, task.UserID)
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	t, err := ScanTask(r.db.QueryRow(query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return t, nil
+}
 
-	id, err := result.LastInsertId()
+func (r *taskRepository) GetShared(id, userID int) (*models.Task, error) {
+	query := `
+		SELECT ` + TaskColumns + `
+		FROM tasks
+		WHERE id = ? AND (user_id = ? OR project_id IN (
+			SELECT project_id FROM project_members WHERE user_id = ?
+		))
+		LIMIT 1
+	`
+	t, err := ScanTask(r.db.QueryRow(query, id, userID, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
-	task.ID = int(id)
-	return nil
+	return t, nil
 }
 
-func (r *taskRepository) GetByUserID(userID int) ([]*models.Task, error) {
+func (r *taskRepository) GetInProject(projectID int) ([]models.Task, error) {
+	rows, err := r.db.Query(
+		`SELECT `+TaskColumns+`
+		FROM tasks
+		WHERE project_id = ?
+		ORDER BY created_at DESC`,
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		t, err := ScanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *t)
+	}
+	return tasks, rows.Err()
+}
+
+func (r *taskRepository) GetChildren(parentID, userID int, forUpdate bool) ([]*models.Task, error) {
 	query := `
-		SELECT id, title, description, completed, user_id, created_at
+		SELECT ` + TaskColumns + `
 		FROM tasks
-		WHERE user_id = ?
-		ORDER BY created_at DESC
+		WHERE parent_id = ? AND user_id = ?
 	`
-	rows, err := r.db.Query(query, userID)
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+
+	rows, err := r.db.Query(query, parentID, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -55,55 +153,221 @@ func (r *taskRepository) GetByUserID(userID int) ([]*models.Task, error) {
 
 	var tasks []*models.Task
 	for rows.Next() {
-		var t models.Task
-		if err := rows.Scan(&t.ID, &t.Title, &t.Description, &t.Done, &t.UserID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		t, err := ScanTask(rows)
+		if err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, &t)
+		tasks = append(tasks, t)
 	}
+	return tasks, rows.Err()
+}
 
-	if err := rows.Err(); err != nil {
+func (r *taskRepository) GetChangedSince(userID int, since int64, limit int) ([]models.Task, error) {
+	rows, err := r.db.Query(
+		`SELECT `+TaskColumns+`
+		FROM tasks
+		WHERE user_id = ? AND change_seq > ?
+		ORDER BY change_seq
+		LIMIT ?`,
+		userID,
+		since,
+		limit,
+	)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return tasks, nil
+	var tasks []models.Task
+	for rows.Next() {
+		t, err := ScanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *t)
+	}
+	return tasks, rows.Err()
 }
 
-func (r *taskRepository) GetByID(id int) (*models.Task, error) {
-	query := `
-		SELECT id, title, description, completed, user_id, created_at, updated_at
-		FROM tasks
-		WHERE id = ?
-		LIMIT 1
-	`
-	row := r.db.QueryRow(query, id)
+func (r *taskRepository) GetDeletedSince(userID int, since int64, limit int) ([]models.DeletedTask, error) {
+	rows, err := r.db.Query(
+		`SELECT task_id, change_seq
+		FROM task_tombstones
+		WHERE user_id = ? AND change_seq > ?
+		ORDER BY change_seq
+		LIMIT ?`,
+		userID,
+		since,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	var t models.Task
-	if err := row.Scan(&t.ID, &t.Title, &t.Description, &t.Done, &t.UserID, &t.CreatedAt, &t.UpdatedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
+	var deleted []models.DeletedTask
+	for rows.Next() {
+		var d models.DeletedTask
+		if err := rows.Scan(&d.ID, &d.Version); err != nil {
+			return nil, err
 		}
+		deleted = append(deleted, d)
+	}
+	return deleted, rows.Err()
+}
+
+func (r *taskRepository) GetSequence(userID int) (current, purgedThrough int64, err error) {
+	err = r.db.QueryRow(
+		`SELECT value, purged_through FROM change_sequences WHERE user_id = ?`,
+		userID,
+	).Scan(&current, &purgedThrough)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	return current, purgedThrough, err
+}
+
+func (r *taskRepository) GetExpiredTombstones(cutoff time.Time) (map[int]int64, error) {
+	rows, err := r.db.Query(
+		`SELECT user_id, MAX(change_seq)
+		FROM task_tombstones
+		WHERE deleted_at < ?
+		GROUP BY user_id`,
+		cutoff,
+	)
+	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &t, nil
+	expired := map[int]int64{}
+	for rows.Next() {
+		var userID int
+		var through int64
+		if err := rows.Scan(&userID, &through); err != nil {
+			return nil, err
+		}
+		expired[userID] = through
+	}
+	return expired, rows.Err()
+}
+
+func (r *taskRepository) NextVersion(userID int) (int64, error) {
+	// LAST_INSERT_ID(expr) hands the new value back without a second read.
+	res, err := r.db.Exec(
+		`INSERT INTO change_sequences (user_id, value) VALUES (?, LAST_INSERT_ID(1))
+       ON DUPLICATE KEY UPDATE value = LAST_INSERT_ID(value + 1)`,
+		userID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (r *taskRepository) Create(task *models.Task) error {
+	res, err := r.db.Exec(
+		`INSERT INTO tasks (title, description, done, user_id, parent_id, project_id, change_seq, created_at, updated_at)
+       VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.Title,
+		task.Description,
+		task.Done,
+		task.UserID,
+		task.ParentID,
+		task.ProjectID,
+		task.Version,
+		task.CreatedAt,
+		task.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	task.ID = int(id)
+	return nil
 }
 
 func (r *taskRepository) Update(task *models.Task) error {
-	query := `
-		UPDATE tasks
-		SET title = ?, description = ?, completed = ?
-		WHERE id = ?
-	`
-	_, err := r.db.Exec(query, task.Title, task.Description, task.Done, task.ID)
+	_, err := r.db.Exec(
+		`UPDATE tasks
+       SET title = ?, description = ?, done = ?, parent_id = ?, project_id = ?, change_seq = ?, updated_at = ?
+       WHERE id = ? AND user_id = ?`,
+		task.Title,
+		task.Description,
+		task.Done,
+		task.ParentID,
+		task.ProjectID,
+		task.Version,
+		task.UpdatedAt,
+		task.ID,
+		task.UserID,
+	)
 	return err
 }
 
-func (r *taskRepository) Delete(id int) error {
-	query := `
-		DELETE FROM tasks
-		WHERE id = ?
-	`
-	_, err := r.db.Exec(query, id)
+func (r *taskRepository) Delete(id, userID int, version int64, deletedAt time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM tasks WHERE id = ? AND user_id = ?`, id, userID); err != nil {
+		return err
+	}
+	_, err := r.db.Exec(
+		`INSERT INTO task_tombstones (task_id, user_id, change_seq, deleted_at) VALUES (?, ?, ?, ?)`,
+		id,
+		userID,
+		version,
+		deletedAt,
+	)
 	return err
 }
+
+func (r *taskRepository) PurgeTombstones(userID int, through int64) (int64, error) {
+	// The sequence row is locked first, in the same order as writers.
+	if _, err := r.db.Exec(
+		`UPDATE change_sequences SET purged_through = GREATEST(purged_through, ?) WHERE user_id = ?`,
+		through,
+		userID,
+	); err != nil {
+		return 0, err
+	}
+	res, err := r.db.Exec(`DELETE FROM task_tombstones WHERE user_id = ? AND change_seq <= ?`, userID, through)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ScanTask reads a row selected with TaskColumns.
+func ScanTask(row interface{ Scan(dest ...any) error }) (*models.Task, error) {
+	var (
+		t         models.Task
+		parentID  sql.NullInt64
+		projectID sql.NullInt64
+	)
+	err := row.Scan(
+		&t.ID,
+		&t.Title,
+		&t.Description,
+		&t.Done,
+		&t.UserID,
+		&parentID,
+		&projectID,
+		&t.Version,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		t.ParentID = &id
+	}
+	if projectID.Valid {
+		id := int(projectID.Int64)
+		t.ProjectID = &id
+	}
+	return &t, nil
+}
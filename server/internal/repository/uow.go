@@ -0,0 +1,171 @@
+// Package repository reads and writes the models stored in MySQL.
+// Repositories run their statements on a DBTX, which is either the pool or
+// the transaction of a unit of work, so the same repository code takes part
+// in whatever transaction the caller runs.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"task-manager-server/internal/config"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL errors after which a transaction is run again. InnoDB rolls back
+// the victim of a deadlock; a lock wait timeout only fails the statement,
+// but the unit of work rolls back the rest.
+const (
+	errLockWaitTimeout = 1205
+	errLockDeadlock    = 1213
+)
+
+// DBTX runs queries on the pool or inside a transaction.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Conn runs queries with a context; *sql.DB and *sql.Tx are both one.
+type Conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Bind returns a DBTX running its statements on db with ctx, so they are
+// cancelled with the request and traced as part of it.
+func Bind(ctx context.Context, db Conn) DBTX {
+	return boundDB{ctx: ctx, db: db}
+}
+
+type boundDB struct {
+	ctx context.Context
+	db  Conn
+}
+
+func (b boundDB) Exec(query string, args ...any) (sql.Result, error) {
+	return b.db.ExecContext(b.ctx, query, args...)
+}
+
+func (b boundDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return b.db.QueryContext(b.ctx, query, args...)
+}
+
+func (b boundDB) QueryRow(query string, args ...any) *sql.Row {
+	return b.db.QueryRowContext(b.ctx, query, args...)
+}
+
+// UnitOfWork runs groups of statements as transactions.
+type UnitOfWork struct {
+	db  *sql.DB
+	cfg config.TxConfig
+}
+
+func NewUnitOfWork(db *sql.DB, cfg config.TxConfig) *UnitOfWork {
+	return &UnitOfWork{db: db, cfg: cfg}
+}
+
+// Tx is the transaction of one attempt of a unit of work. Its statements
+// run with the context WithTx was called with.
+type Tx struct {
+	*sql.Tx
+	ctx         context.Context
+	afterCommit []func()
+}
+
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.ExecContext(tx.ctx, query, args...)
+}
+
+func (tx *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.QueryContext(tx.ctx, query, args...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.QueryRowContext(tx.ctx, query, args...)
+}
+
+// Users returns the user repository bound to the transaction.
+func (tx *Tx) Users() UserRepository {
+	return NewUserRepository(tx)
+}
+
+// Tasks returns the task repository bound to the transaction.
+func (tx *Tx) Tasks() TaskRepository {
+	return &taskRepository{db: tx}
+}
+
+// AfterCommit registers fn to run once the transaction committed, such as
+// publishing events or sending mail. Nothing registered runs when the
+// transaction is rolled back, so an attempt that is retried leaves no trace.
+func (tx *Tx) AfterCommit(fn func()) {
+	tx.afterCommit = append(tx.afterCommit, fn)
+}
+
+// WithTx runs fn in a transaction, which is committed when fn returns nil
+// and rolled back when it returns an error or panics; the panic goes on
+// once the transaction is rolled back. When MySQL aborts the transaction
+// because of a deadlock or a lock wait timeout, fn runs again in a new one,
+// up to MaxAttempts times in all. fn must therefore keep its effects inside
+// tx and reset any state it builds up, or defer them with AfterCommit.
+func (u *UnitOfWork) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	delay := u.cfg.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := u.run(ctx, fn)
+		if err == nil || attempt >= u.cfg.MaxAttempts || !Retryable(err) {
+			return err
+		}
+
+		wait := delay + rand.N(delay+1)
+		delay *= 2
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (u *UnitOfWork) run(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	sqlTx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	tx := &Tx{Tx: sqlTx, ctx: ctx}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		_ = sqlTx.Rollback()
+		return err
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return err
+	}
+
+	for _, f := range tx.afterCommit {
+		f()
+	}
+	return nil
+}
+
+// Retryable reports whether err, or an error it wraps, is a MySQL error
+// after which running the whole transaction again may succeed.
+func Retryable(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return false
+	}
+	return myErr.Number == errLockDeadlock || myErr.Number == errLockWaitTimeout
+}
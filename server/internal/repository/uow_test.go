@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+
+	"task-manager-server/internal/config"
+)
+
+var (
+	errDeadlock = &mysql.MySQLError{Number: errLockDeadlock, Message: "Deadlock found when trying to get lock"}
+	errConflict = errors.New("conflict")
+)
+
+func newTestUnitOfWork(t *testing.T, cfg config.TxConfig) (*UnitOfWork, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return NewUnitOfWork(db, cfg), mock
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errDeadlock, true},
+		{&mysql.MySQLError{Number: errLockWaitTimeout}, true},
+		{fmt.Errorf("moving task 3: %w", errDeadlock), true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, false},
+		{errConflict, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// A deadlocked attempt is rolled back and run again in a new transaction;
+// only the committed attempt runs what it deferred.
+func TestWithTxRetriesDeadlocks(t *testing.T) {
+	u, mock := newTestUnitOfWork(t, config.TxConfig{MaxAttempts: 3, RetryDelay: time.Millisecond})
+
+	for range 2 {
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE tasks").WillReturnError(errDeadlock)
+		mock.ExpectRollback()
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE tasks").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	attempts, committed := 0, 0
+	err := u.WithTx(context.Background(), func(tx *Tx) error {
+		attempts++
+		n := attempts
+		tx.AfterCommit(func() { committed = n })
+		_, err := tx.Exec("UPDATE tasks SET done = 1 WHERE id = ?", 3)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || committed != 3 {
+		t.Errorf("%d attempts, after commit of attempt %d; want 3 and 3", attempts, committed)
+	}
+}
+
+func TestWithTxGivesUpAfterMaxAttempts(t *testing.T) {
+	u, mock := newTestUnitOfWork(t, config.TxConfig{MaxAttempts: 2})
+
+	for range 2 {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+	}
+
+	attempts := 0
+	err := u.WithTx(context.Background(), func(tx *Tx) error {
+		attempts++
+		tx.AfterCommit(func() { t.Error("a rolled back attempt ran its after commit hooks") })
+		return errDeadlock
+	})
+	if !errors.Is(err, errDeadlock) || attempts != 2 {
+		t.Errorf("err = %v after %d attempts, want the deadlock after 2", err, attempts)
+	}
+}
+
+func TestWithTxDoesNotRetryOtherErrors(t *testing.T) {
+	u, mock := newTestUnitOfWork(t, config.TxConfig{MaxAttempts: 3})
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	attempts := 0
+	err := u.WithTx(context.Background(), func(tx *Tx) error {
+		attempts++
+		return errConflict
+	})
+	if !errors.Is(err, errConflict) || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want the conflict after 1", err, attempts)
+	}
+}
+
+// Retrying stops with the context, returning the error of the last attempt.
+func TestWithTxStopsRetryingWhenContextIsDone(t *testing.T) {
+	u, mock := newTestUnitOfWork(t, config.TxConfig{MaxAttempts: 3, RetryDelay: time.Hour})
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := u.WithTx(ctx, func(tx *Tx) error {
+		cancel()
+		return errDeadlock
+	})
+	if !errors.Is(err, errDeadlock) {
+		t.Errorf("err = %v, want the deadlock", err)
+	}
+}
+
+func TestWithTxRollsBackOnPanic(t *testing.T) {
+	u, mock := newTestUnitOfWork(t, config.TxConfig{MaxAttempts: 3})
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("recovered %v, want the panic to go on", p)
+		}
+	}()
+	_ = u.WithTx(context.Background(), func(tx *Tx) error {
+		panic("boom")
+	})
+}
+
+func TestWithTxReturnsCommitErrors(t *testing.T) {
+	u, mock := newTestUnitOfWork(t, config.TxConfig{MaxAttempts: 1})
+
+	mock.ExpectBegin()
+	mock.ExpectCommit().WillReturnError(errConflict)
+
+	err := u.WithTx(context.Background(), func(tx *Tx) error {
+		tx.AfterCommit(func() { t.Error("a failed commit ran its after commit hooks") })
+		return nil
+	})
+	if !errors.Is(err, errConflict) {
+		t.Errorf("err = %v, want the commit error", err)
+	}
+}
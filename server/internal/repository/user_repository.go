@@ -6,26 +6,33 @@ import (
 	"task-manager-server/internal/models"
 )
 
+const userColumns = `id, name, email, email_verified_at, is_admin, created_at`
+
 type UserRepository interface {
+	// Create inserts user and sets its ID. Password must already be hashed.
 	Create(user *models.User) error
+	// GetByEmail and GetByID return nil when there is no such user. The
+	// password hash is not loaded.
 	GetByEmail(email string) (*models.User, error)
 	GetByID(id int) (*models.User, error)
 }
 
 type userRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewUserRepository(db *sql.DB) UserRepository {
+// NewUserRepository returns a repository running its statements on db,
+// which is the pool or a transaction.
+func NewUserRepository(db DBTX) UserRepository {
 	return &userRepository{db: db}
 }
 
 func (r *userRepository) Create(user *models.User) error {
 	query := `
-		INSERT INTO users (name, email, password)
-		VALUES (?, ?, ?)
+		INSERT INTO users (name, email, password, created_at)
+		VALUES (?, ?, ?, ?)
 	`
-	result, err := r.db.Exec(query, user.Name, user.Email, user.Password)
+	result, err := r.db.Exec(query, user.Name, user.Email, user.Password, user.CreatedAt)
 	if err != nil {
 		return err
 	}
@@ -40,41 +47,34 @@ func (r *userRepository) Create(user *models.User) error {
 
 func (r *userRepository) GetByEmail(email string) (*models.User, error) {
 	query := `
-		SELECT id, name, email, password, created_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE email = ?
 		LIMIT 1
 	`
-	row := r.db.QueryRow(query, email)
-
-	var u models.User
-	if err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.CreatedAt); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	return &u, nil
+	return scanUser(r.db.QueryRow(query, email))
 }
 
 func (r *userRepository) GetByID(id int) (*models.User, error) {
 	query := `
-		SELECT id, name, email, password, created_at
+		SELECT ` + userColumns + `
 		FROM users
 		WHERE id = ?
 		LIMIT 1
 	`
-	row := r.db.QueryRow(query, id)
+	return scanUser(r.db.QueryRow(query, id))
+}
 
+func scanUser(row *sql.Row) (*models.User, error) {
 	var u models.User
-	if err := row.Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.CreatedAt); err != nil {
+	var verifiedAt sql.NullTime
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &verifiedAt, &u.IsAdmin, &u.CreatedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
 
+	u.EmailVerified = verifiedAt.Valid
 	return &u, nil
 }
-
@@ -0,0 +1,83 @@
+// Package response writes JSON and RFC 7807 problem responses. It is shared
+// by handlers and middleware so every error leaves the API in one shape.
+package response
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the media type of problem responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. TraceID and Errors are
+// extension members.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	TraceID  string `json:"traceId"`
+	Errors   any    `json:"errors,omitempty"`
+}
+
+// TypeURI returns the problem type URI for a slug such as "not-found".
+func TypeURI(slug string) string {
+	return "/problems/" + slug
+}
+
+type contextKey struct{}
+
+// WithTraceID attaches the ID that problem responses report, so a client can
+// quote it when asking about a failed request.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// TraceID returns the trace ID of the request, generating one when no
+// middleware has set it.
+func TraceID(r *http.Request) string {
+	if id, ok := r.Context().Value(contextKey{}).(string); ok && id != "" {
+		return id
+	}
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// JSON writes data as a JSON response.
+func JSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// WriteProblem fills in the request specific members of p and writes it.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	p.TraceID = TraceID(r)
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Error writes a generic problem whose title is the HTTP status text.
+func Error(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	WriteProblem(w, r, &Problem{
+		Status: status,
+		Detail: detail,
+	})
+}
@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"task-manager-server/internal/middleware"
+	"task-manager-server/internal/response"
+)
+
+// Route describes one endpoint of the API.
+type Route struct {
+	Method  string
+	Path    string // ServeMux pattern path, e.g. "/api/tasks/{id}"
+	Handler http.HandlerFunc
+
+	// Auth requires a valid session token.
+	Auth bool
+	// Scopes must all be present in the session token. Implies Auth.
+	Scopes []string
+	// QueryToken also accepts the token in the access_token query
+	// parameter, for clients that cannot set headers.
+	QueryToken bool
+	// Middleware runs for this route only, after authentication.
+	Middleware []middleware.Middleware
+
+	// Documentation used to generate the OpenAPI document. Request and
+	// Response hold zero values of the models, e.g. models.Task{}.
+	Summary  string
+	Tag      string
+	Request  any
+	Response any
+	Status   int
+	// ContentType of the success response, application/json when empty.
+	ContentType string
+	// Sparse routes accept the fields and include query parameters.
+	Sparse bool
+	// RequestTypes documents the request body per media type for routes
+	// that accept bodies other than JSON. It replaces Request.
+	RequestTypes map[string]any
+}
+
+// Router dispatches requests on method and path using ServeMux patterns.
+// Requests for a known path with the wrong method get a 405 listing the
+// allowed methods; unknown paths get a 404. Both are problem responses.
+type Router struct {
+	mux     *http.ServeMux
+	routes  []Route
+	methods []string // every method some route accepts
+}
+
+func NewRouter() *Router {
+	rt := &Router{mux: http.NewServeMux()}
+	// Only requests no route accepts reach the catch-all pattern.
+	rt.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if methods := rt.allowed(r); len(methods) > 0 {
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			response.Error(w, r, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed on this resource")
+			return
+		}
+		response.Error(w, r, http.StatusNotFound, "No route matches "+r.URL.Path)
+	})
+	return rt
+}
+
+// Handle registers routes. Subsystems contribute their routes through it.
+func (rt *Router) Handle(routes ...Route) {
+	for _, route := range routes {
+		rt.handle(route, nil)
+	}
+}
+
+// Group returns a registrar that prefixes route paths and wraps the routes
+// with middlewares that run before authentication.
+func (rt *Router) Group(prefix string, middlewares ...middleware.Middleware) *Group {
+	return &Group{
+		router:     rt,
+		prefix:     prefix,
+		middleware: middlewares,
+	}
+}
+
+func (rt *Router) handle(route Route, outer []middleware.Middleware) {
+	pattern := route.Method + " " + route.Path
+	mws := append([]middleware.Middleware{middleware.Route(pattern)}, outer...)
+	if route.QueryToken {
+		mws = append(mws, middleware.TokenFromQuery)
+	}
+	if route.Auth || len(route.Scopes) > 0 {
+		mws = append(mws, middleware.AuthMiddleware)
+	}
+	for _, scope := range route.Scopes {
+		mws = append(mws, middleware.RequireScope(scope))
+	}
+	mws = append(mws, route.Middleware...)
+
+	rt.mux.Handle(pattern, middleware.Chain(route.Handler, mws...))
+	rt.routes = append(rt.routes, route)
+	if !slices.Contains(rt.methods, route.Method) {
+		rt.methods = append(rt.methods, route.Method)
+	}
+}
+
+// allowed lists the methods some route accepts on the path of r, found by
+// asking the mux how it would route r with each method. Method-less
+// patterns per path would do the same, but they conflict with wildcard
+// siblings such as /tasks/{id} and /tasks/batch. ServeMux serves HEAD
+// through GET routes, so it is listed with them.
+func (rt *Router) allowed(r *http.Request) []string {
+	var methods []string
+	for _, method := range rt.methods {
+		probe := &http.Request{Method: method, Host: r.Host, URL: r.URL}
+		if _, pattern := rt.mux.Handler(probe); pattern != "/" {
+			methods = append(methods, method)
+		}
+	}
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	slices.Sort(methods)
+	return methods
+}
+
+// Group registers routes under a common prefix, such as an API version.
+type Group struct {
+	router     *Router
+	prefix     string
+	middleware []middleware.Middleware
+}
+
+// Handle registers routes whose paths are relative to the group prefix.
+func (g *Group) Handle(routes ...Route) {
+	for _, route := range routes {
+		route.Path = g.prefix + route.Path
+		g.router.handle(route, g.middleware)
+	}
+}
+
+// Routes returns the registered routes in registration order.
+func (rt *Router) Routes() []Route {
+	return slices.Clone(rt.routes)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
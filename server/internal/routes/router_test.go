@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"task-manager-server/internal/middleware"
+	"task-manager-server/internal/response"
+)
+
+// echo writes the name of the route and its id path parameter.
+func echo(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + ":" + r.PathValue("id")))
+	}
+}
+
+// tag appends name to the X-Trail header, to show the middleware order.
+func tag(name string) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trail", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newTestRouter() *Router {
+	router := NewRouter()
+	router.Group("/api/v1", tag("group")).Handle(
+		Route{Method: http.MethodGet, Path: "/tasks", Handler: echo("list")},
+		Route{Method: http.MethodPost, Path: "/tasks", Handler: echo("create"), Middleware: []middleware.Middleware{tag("route")}},
+		Route{Method: http.MethodGet, Path: "/tasks/{id}", Handler: echo("get")},
+		Route{Method: http.MethodDelete, Path: "/tasks/{id}", Handler: echo("delete"), Auth: true},
+		Route{Method: http.MethodPost, Path: "/tasks/batch", Handler: echo("batch")},
+	)
+	return router
+}
+
+func serve(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestRouterDispatchesOnMethodAndPath(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/api/v1/tasks", "list:"},
+		{http.MethodPost, "/api/v1/tasks", "create:"},
+		{http.MethodGet, "/api/v1/tasks/42", "get:42"},
+		{http.MethodPost, "/api/v1/tasks/batch", "batch:"},
+		// A literal segment beats the wildcard only for its own method.
+		{http.MethodGet, "/api/v1/tasks/batch", "get:batch"},
+	}
+	for _, tt := range tests {
+		w := serve(router, tt.method, tt.path)
+		if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Errorf("%s %s = %d %q, want 200 %q", tt.method, tt.path, w.Code, w.Body, tt.want)
+		}
+	}
+}
+
+func TestRouterRunsGroupThenRouteMiddleware(t *testing.T) {
+	router := newTestRouter()
+
+	if got := serve(router, http.MethodPost, "/api/v1/tasks").Header().Values("X-Trail"); strings.Join(got, ",") != "group,route" {
+		t.Errorf("POST trail = %v, want group,route", got)
+	}
+	if got := serve(router, http.MethodGet, "/api/v1/tasks").Header().Values("X-Trail"); strings.Join(got, ",") != "group" {
+		t.Errorf("GET trail = %v, want only the group middleware", got)
+	}
+}
+
+func TestRouterRequiresAuthPerRoute(t *testing.T) {
+	router := newTestRouter()
+
+	if w := serve(router, http.MethodDelete, "/api/v1/tasks/42"); w.Code != http.StatusUnauthorized {
+		t.Errorf("DELETE without a token: status %d, want 401", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/api/v1/tasks/42"); w.Code != http.StatusOK {
+		t.Errorf("GET of the same path: status %d, want 200", w.Code)
+	}
+}
+
+func TestRouterAnswersWrongMethodWith405(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		path, wantAllow string
+	}{
+		{"/api/v1/tasks", "GET, HEAD, POST"},
+		{"/api/v1/tasks/42", "DELETE, GET, HEAD"},
+		{"/api/v1/tasks/batch", "DELETE, GET, HEAD, POST"},
+	}
+	for _, tt := range tests {
+		w := serve(router, http.MethodPut, tt.path)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("PUT %s: status %d, want 405", tt.path, w.Code)
+		}
+		if got := w.Header().Get("Allow"); got != tt.wantAllow {
+			t.Errorf("PUT %s: Allow %q, want %q", tt.path, got, tt.wantAllow)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != response.ProblemContentType {
+			t.Errorf("PUT %s: Content-Type %q, want a problem", tt.path, ct)
+		}
+	}
+}
+
+func TestRouterAnswersUnknownPathWith404(t *testing.T) {
+	w := serve(newTestRouter(), http.MethodGet, "/api/v1/projects")
+	if w.Code != http.StatusNotFound || w.Header().Get("Allow") != "" {
+		t.Errorf("status %d, Allow %q; want 404 without Allow", w.Code, w.Header().Get("Allow"))
+	}
+	if ct := w.Header().Get("Content-Type"); ct != response.ProblemContentType {
+		t.Errorf("Content-Type %q, want a problem", ct)
+	}
+}
+
+func TestRouterListsRoutesWithPrefixes(t *testing.T) {
+	routes := newTestRouter().Routes()
+	if len(routes) != 5 || routes[2].Path != "/api/v1/tasks/{id}" {
+		t.Errorf("routes = %+v, want the five routes under /api/v1", routes)
+	}
+}
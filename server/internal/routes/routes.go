@@ -2,45 +2,437 @@ package routes
 
 import (
 	"net/http"
+	"slices"
+	"time"
 
+	"task-manager-server/internal/config"
 	"task-manager-server/internal/handlers"
+	"task-manager-server/internal/idempotency"
 	"task-manager-server/internal/middleware"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/openapi"
+	"task-manager-server/internal/patch"
 )
 
-func SetupRoutes(authHandler *handlers.AuthHandler, taskHandler *handlers.TaskHandler) http.Handler {
-	mux := http.NewServeMux()
-
-	// Auth routes (no auth middleware needed)
-	mux.HandleFunc("/api/register", authHandler.Register)
-	mux.HandleFunc("/api/login", authHandler.Login)
-
-	// Task routes (protected with auth middleware)
-	taskMux := http.NewServeMux()
-	taskMux.HandleFunc("/api/tasks", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			taskHandler.GetTasks(w, r)
-		case http.MethodPost:
-			taskHandler.CreateTask(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// SetupRoutes serves every API version under its own prefix, see Version.
+//
+// There is only v1 so far. A new major version is added to versions with
+// its own route table and handlers. Its request and response types need a
+// models package of their own, because internal/models holds the v1
+// shapes. v1 keeps serving its existing clients next to it.
+func SetupRoutes(authHandler *handlers.AuthHandler, taskHandler *handlers.TaskHandler, eventsHandler *handlers.EventsHandler, collabHandler *handlers.CollabHandler, syncHandler *handlers.SyncHandler, webhookHandler *handlers.WebhookHandler, projectHandler *handlers.ProjectHandler, auditHandler *handlers.AuditHandler, graphqlHandler *handlers.GraphQLHandler, idempotencyStore idempotency.Store, apiCfg config.APIConfig) http.Handler {
+	router := NewRouter()
+
+	var v1 []Route
+	v1 = append(v1, authRoutes(authHandler)...)
+	v1 = append(v1, taskRoutes(taskHandler)...)
+	v1 = append(v1, syncRoutes(syncHandler)...)
+	v1 = append(v1, webhookRoutes(webhookHandler)...)
+	v1 = append(v1, projectRoutes(projectHandler)...)
+	v1 = append(v1, auditRoutes(auditHandler)...)
+	v1 = append(v1, eventRoutes(eventsHandler, collabHandler)...)
+
+	versions := []Version{
+		{Name: "v1", Routes: v1},
+	}
+
+	var legacy []Route
+	var spec *openapi.Document
+	for _, version := range versions {
+		served, versionSpec := mountVersion(router, version, idempotencyStore, apiCfg)
+		if version.Name == "v1" {
+			legacy, spec = served, versionSpec
 		}
-	})
-	taskMux.HandleFunc("/api/tasks/", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPut:
-			taskHandler.UpdateTask(w, r)
-		case http.MethodDelete:
-			taskHandler.DeleteTask(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+
+	// The unversioned paths predate /api/v1. They stay as deprecated
+	// aliases until the sunset date.
+	router.Group("/api",
+		middleware.APIVersion("v1"),
+		middleware.Deprecated("/api", "/api/v1", apiCfg.LegacyDeprecatedAt, apiCfg.LegacySunset),
+	).Handle(legacy...)
+
+	// /api/openapi.json describes v1, the version the docs are for.
+	// internal/openapi tests it against a committed copy, so every change
+	// to it shows up in review.
+	router.Handle(
+		Route{Method: http.MethodGet, Path: "/api/openapi.json", Handler: spec.SpecHandler()},
+		Route{Method: http.MethodGet, Path: "/api/docs", Handler: openapi.DocsHandler("/api/openapi.json")},
+	)
+
+	// GraphQL is not versioned: the schema evolves by adding fields and
+	// deprecating old ones, and clients discover it by introspection.
+	// Mutations are retried with an Idempotency-Key like REST writes.
+	router.Handle(withIdempotency(idempotencyStore, apiCfg.IdempotencyTTL, graphqlRoutes(graphqlHandler))...)
+
+	// Apply CORS middleware to the entire router; every request, preflights
+	// included, is traced, gets an ID and a log line, and is counted.
+	return middleware.Tracing(middleware.RequestLogger(middleware.RequestMetrics(middleware.CORSMiddleware(middleware.ClientInfo(router)))))
+}
+
+// operations describes routes served under prefix for the OpenAPI document.
+func operations(prefix string, routes []Route) []openapi.Operation {
+	ops := make([]openapi.Operation, 0, len(routes))
+	for _, route := range routes {
+		ops = append(ops, openapi.Operation{
+			Method:   route.Method,
+			Path:     prefix + route.Path,
+			Summary:  route.Summary,
+			Tag:      route.Tag,
+			Auth:     route.Auth || len(route.Scopes) > 0,
+			Request:  route.Request,
+			Response: route.Response,
+			Status:   route.Status,
+
+			ContentType:  route.ContentType,
+			RequestTypes: route.RequestTypes,
+			Idempotent:   idempotent(route),
+			Sparse:       route.Sparse,
+		})
+	}
+	return ops
+}
+
+// withSpecValidation checks the traffic of every route against spec. It is
+// only enabled in development.
+func withSpecValidation(spec *openapi.Document, prefix string, routes []Route) []Route {
+	validated := make([]Route, len(routes))
+	for i, route := range routes {
+		route.Middleware = append([]middleware.Middleware{spec.Middleware(route.Method, prefix+route.Path)}, route.Middleware...)
+		validated[i] = route
+	}
+	return validated
+}
+
+// withIdempotency lets clients retry the authenticated mutating routes with
+// an Idempotency-Key. Unauthenticated routes are left out: their responses,
+// such as login tokens, must never be replayed to whoever sends the key.
+func withIdempotency(store idempotency.Store, ttl time.Duration, routes []Route) []Route {
+	wrapped := make([]Route, len(routes))
+	for i, route := range routes {
+		if idempotent(route) {
+			route.Middleware = append(slices.Clone(route.Middleware), middleware.Idempotency(store, ttl))
 		}
-	})
+		wrapped[i] = route
+	}
+	return wrapped
+}
+
+func idempotent(route Route) bool {
+	mutating := route.Method != http.MethodGet && route.Method != http.MethodHead
+	return mutating && (route.Auth || len(route.Scopes) > 0)
+}
+
+func authRoutes(h *handlers.AuthHandler) []Route {
+	return []Route{
+		// Public account routes
+		{
+			Method: http.MethodPost, Path: "/register", Handler: h.Register,
+			Summary: "Create an account", Tag: "auth",
+			Request: models.RegisterRequest{}, Response: models.User{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/login", Handler: h.Login,
+			Summary: "Log in, possibly returning an MFA challenge", Tag: "auth",
+			Request: models.LoginRequest{}, Response: models.AuthResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/login/mfa", Handler: h.LoginMFA,
+			Summary: "Complete a login with a TOTP or recovery code", Tag: "auth",
+			Request: models.MFALoginRequest{}, Response: models.AuthResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/verify-email", Handler: h.VerifyEmail,
+			Summary: "Verify an email address", Tag: "auth",
+			Request: models.VerifyEmailRequest{}, Response: models.MessageResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/verify-email/resend", Handler: h.ResendVerification,
+			Summary: "Send a new verification email", Tag: "auth",
+			Request: models.EmailRequest{}, Response: models.MessageResponse{}, Status: http.StatusAccepted,
+		},
+		{
+			Method: http.MethodPost, Path: "/password/forgot", Handler: h.ForgotPassword,
+			Summary: "Send a password reset email", Tag: "auth",
+			Request: models.EmailRequest{}, Response: models.MessageResponse{}, Status: http.StatusAccepted,
+		},
+		{
+			Method: http.MethodPost, Path: "/password/reset", Handler: h.ResetPassword,
+			Summary: "Set a new password with a reset token", Tag: "auth",
+			Request: models.ResetPasswordRequest{}, Response: models.MessageResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/unlock", Handler: h.UnlockAccount,
+			Summary: "Lift a lockout with an unlock token", Tag: "auth",
+			Request: models.UnlockAccountRequest{}, Response: models.MessageResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/unlock/request", Handler: h.RequestUnlock,
+			Summary: "Send an unlock email", Tag: "auth",
+			Request: models.EmailRequest{}, Response: models.MessageResponse{}, Status: http.StatusAccepted,
+		},
+
+		// Two-factor authentication management
+		{
+			Method: http.MethodPost, Path: "/2fa/setup", Handler: h.SetupTOTP, Auth: true,
+			Summary: "Start TOTP enrolment", Tag: "2fa",
+			Response: models.TOTPSetupResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/2fa/confirm", Handler: h.ConfirmTOTP, Auth: true,
+			Summary: "Confirm TOTP enrolment", Tag: "2fa",
+			Request: models.TOTPConfirmRequest{}, Response: models.RecoveryCodesResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/2fa/disable", Handler: h.DisableTOTP, Auth: true,
+			Summary: "Disable two-factor authentication", Tag: "2fa",
+			Request: models.MFAReauthRequest{}, Response: models.MessageResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/2fa/recovery-codes", Handler: h.RegenerateRecoveryCodes, Auth: true,
+			Summary: "Replace the recovery codes", Tag: "2fa",
+			Request: models.MFAReauthRequest{}, Response: models.RecoveryCodesResponse{},
+		},
+
+		// Administration
+		{
+			Method: http.MethodPost, Path: "/admin/unlock", Handler: h.AdminUnlock, Scopes: []string{"admin"},
+			Summary: "Clear the lockout of an account or IP", Tag: "admin",
+			Request: models.AdminUnlockRequest{}, Response: models.MessageResponse{},
+		},
+	}
+}
+
+func taskRoutes(h *handlers.TaskHandler) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/tasks", Handler: h.GetTasks, Auth: true,
+			Summary: "List tasks", Tag: "tasks",
+			Response: []models.Task{}, Sparse: true,
+		},
+		{
+			Method: http.MethodPost, Path: "/tasks", Handler: h.CreateTask, Auth: true,
+			Summary: "Create a task", Tag: "tasks",
+			Request: models.CreateTaskRequest{}, Response: models.Task{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodPost, Path: "/tasks/batch", Handler: h.Batch, Auth: true,
+			Summary: "Run several task operations in one transaction", Tag: "tasks",
+			Request: models.BatchRequest{}, Response: models.BatchResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/tasks/{id}", Handler: h.GetTask, Auth: true,
+			Summary: "Get a task", Tag: "tasks",
+			Response: models.Task{}, Sparse: true,
+		},
+		{
+			Method: http.MethodPut, Path: "/tasks/{id}", Handler: h.UpdateTask, Auth: true,
+			Summary: "Update a task", Tag: "tasks",
+			Request: models.UpdateTaskRequest{}, Response: models.Task{},
+		},
+		{
+			Method: http.MethodPatch, Path: "/tasks/{id}", Handler: h.PatchTask, Auth: true,
+			Summary: "Patch a task with a JSON Merge Patch or a JSON Patch", Tag: "tasks",
+			RequestTypes: map[string]any{
+				patch.MergePatchType: models.TaskMergePatch{},
+				patch.JSONPatchType:  []models.PatchOperation{},
+			},
+			Response: models.Task{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/tasks/{id}", Handler: h.DeleteTask, Auth: true,
+			Summary: "Delete a task", Tag: "tasks",
+			Response: models.MessageResponse{},
+		},
+		{
+			Method: http.MethodPut, Path: "/tasks/{id}/labels", Handler: h.SetTaskLabels, Auth: true,
+			Summary: "Replace the labels of a task", Tag: "tasks",
+			Request: models.SetTaskLabelsRequest{}, Response: []models.Label{},
+		},
+		{
+			Method: http.MethodGet, Path: "/tasks/{id}/comments", Handler: h.ListComments, Auth: true,
+			Summary: "List the comments on a task", Tag: "tasks",
+			Response: []models.Comment{},
+		},
+		{
+			Method: http.MethodPost, Path: "/tasks/{id}/comments", Handler: h.AddComment, Auth: true,
+			Summary: "Comment on a task", Tag: "tasks",
+			Request: models.CreateCommentRequest{}, Response: models.Comment{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/tasks/{id}/revisions", Handler: h.Revisions, Auth: true,
+			Summary: "List the revisions of a task with their changes", Tag: "tasks",
+			Response: models.TaskRevisionsResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/tasks/{id}/revisions/{rev}/revert", Handler: h.RevertTask, Auth: true,
+			Summary: "Restore a task to one of its revisions", Tag: "tasks",
+			Response: models.Task{},
+		},
+		{
+			Method: http.MethodGet, Path: "/labels", Handler: h.ListLabels, Auth: true,
+			Summary: "List labels", Tag: "tasks",
+			Response: []models.Label{},
+		},
+		{
+			Method: http.MethodPost, Path: "/labels", Handler: h.CreateLabel, Auth: true,
+			Summary: "Create a label", Tag: "tasks",
+			Request: models.CreateLabelRequest{}, Response: models.Label{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodDelete, Path: "/labels/{id}", Handler: h.DeleteLabel, Auth: true,
+			Summary: "Delete a label", Tag: "tasks",
+			Response: models.MessageResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/undo", Handler: h.Undo, Auth: true,
+			Summary: "Undo the last task command", Tag: "tasks",
+			Response: models.UndoResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/redo", Handler: h.Redo, Auth: true,
+			Summary: "Redo the last undone task command", Tag: "tasks",
+			Response: models.UndoResponse{},
+		},
+	}
+}
+
+func syncRoutes(h *handlers.SyncHandler) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/sync", Handler: h.Changes, Auth: true,
+			Summary: "Get the tasks changed and deleted since a sync token", Tag: "sync",
+			Response: models.SyncResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/sync", Handler: h.Apply, Auth: true,
+			Summary: "Apply mutations made offline", Tag: "sync",
+			Request: models.SyncRequest{}, Response: models.SyncApplyResponse{},
+		},
+	}
+}
+
+func webhookRoutes(h *handlers.WebhookHandler) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/webhooks", Handler: h.ListWebhooks, Auth: true,
+			Summary: "List webhooks", Tag: "webhooks",
+			Response: []models.Webhook{},
+		},
+		{
+			Method: http.MethodPost, Path: "/webhooks", Handler: h.CreateWebhook, Auth: true,
+			Summary: "Register a webhook; the response carries its signing secret", Tag: "webhooks",
+			Request: models.CreateWebhookRequest{}, Response: models.Webhook{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/webhooks/{id}", Handler: h.GetWebhook, Auth: true,
+			Summary: "Get a webhook", Tag: "webhooks",
+			Response: models.Webhook{},
+		},
+		{
+			Method: http.MethodPut, Path: "/webhooks/{id}", Handler: h.UpdateWebhook, Auth: true,
+			Summary: "Update or re-enable a webhook", Tag: "webhooks",
+			Request: models.UpdateWebhookRequest{}, Response: models.Webhook{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/webhooks/{id}", Handler: h.DeleteWebhook, Auth: true,
+			Summary: "Delete a webhook", Tag: "webhooks",
+			Response: models.MessageResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/webhooks/{id}/deliveries", Handler: h.Deliveries, Auth: true,
+			Summary: "List the latest deliveries of a webhook", Tag: "webhooks",
+			Response: []models.WebhookDelivery{},
+		},
+		{
+			Method: http.MethodPost, Path: "/webhooks/{id}/deliveries/{deliveryId}/redeliver", Handler: h.Redeliver, Auth: true,
+			Summary: "Send a delivery again", Tag: "webhooks",
+			Response: models.WebhookDelivery{}, Status: http.StatusAccepted,
+		},
+	}
+}
+
+func projectRoutes(h *handlers.ProjectHandler) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/projects", Handler: h.ListProjects, Auth: true,
+			Summary: "List the projects you are a member of", Tag: "projects",
+			Response: []models.Project{},
+		},
+		{
+			Method: http.MethodPost, Path: "/projects", Handler: h.CreateProject, Auth: true,
+			Summary: "Create a project", Tag: "projects",
+			Request: models.CreateProjectRequest{}, Response: models.Project{}, Status: http.StatusCreated,
+		},
+		{
+			Method: http.MethodGet, Path: "/projects/{id}", Handler: h.GetProject, Auth: true,
+			Summary: "Get a project with its members", Tag: "projects",
+			Response: models.Project{},
+		},
+		{
+			Method: http.MethodGet, Path: "/projects/{id}/tasks", Handler: h.ProjectTasks, Auth: true,
+			Summary: "List the tasks shared in a project", Tag: "projects",
+			Response: []models.Task{},
+		},
+		{
+			Method: http.MethodPost, Path: "/projects/{id}/members", Handler: h.AddMember, Auth: true,
+			Summary: "Add a member by email", Tag: "projects",
+			Request: models.AddProjectMemberRequest{}, Response: models.ProjectMember{},
+		},
+		{
+			Method: http.MethodDelete, Path: "/projects/{id}/members/{userId}", Handler: h.RemoveMember, Auth: true,
+			Summary: "Remove a member, or leave a project", Tag: "projects",
+			Response: models.MessageResponse{},
+		},
+		{
+			Method: http.MethodPut, Path: "/tasks/{id}/project", Handler: h.SetTaskProject, Auth: true,
+			Summary: "Move a task into a project or out of it", Tag: "projects",
+			Request: models.SetTaskProjectRequest{}, Response: models.Task{},
+		},
+	}
+}
+
+func auditRoutes(h *handlers.AuditHandler) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/admin/audit", Handler: h.Query, Scopes: []string{"admin"},
+			Summary: "Search the audit log", Tag: "admin",
+			Response: models.AuditLogResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/admin/audit/export", Handler: h.Export, Scopes: []string{"admin"},
+			Summary: "Export the audit log as CSV or NDJSON", Tag: "admin",
+			ContentType: "text/csv",
+		},
+	}
+}
 
-	// Mount protected task handlers under the main mux
-	mux.Handle("/api/tasks", middleware.AuthMiddleware(taskMux))
-	mux.Handle("/api/tasks/", middleware.AuthMiddleware(taskMux))
+func eventRoutes(events *handlers.EventsHandler, collab *handlers.CollabHandler) []Route {
+	return []Route{
+		{
+			Method: http.MethodGet, Path: "/events", Handler: events.Stream, Auth: true, QueryToken: true,
+			Summary: "Stream task changes as Server-Sent Events", Tag: "events",
+			ContentType: "text/event-stream",
+		},
+		{
+			Method: http.MethodGet, Path: "/ws", Handler: collab.Connect, Auth: true, QueryToken: true,
+			Summary: "Open the collaboration WebSocket", Tag: "events",
+			Status: http.StatusSwitchingProtocols,
+		},
+	}
+}
 
-	// Apply CORS middleware to the entire mux
-	return middleware.CORSMiddleware(mux)
+func graphqlRoutes(h *handlers.GraphQLHandler) []Route {
+	return []Route{
+		{
+			Method: http.MethodPost, Path: "/api/graphql", Handler: h.Query, Auth: true,
+			Summary: "Run a GraphQL query or mutation", Tag: "graphql",
+			Request: models.GraphQLRequest{}, Response: models.GraphQLResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/api/graphql", Handler: h.Subscribe, Auth: true, QueryToken: true,
+			Summary: "Open a graphql-transport-ws WebSocket for subscriptions", Tag: "graphql",
+			Status: http.StatusSwitchingProtocols,
+		},
+	}
 }
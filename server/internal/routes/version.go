@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/idempotency"
+	"task-manager-server/internal/middleware"
+	"task-manager-server/internal/openapi"
+)
+
+// Version is one major version of the REST API. Its route table uses paths
+// relative to the version prefix, and its routes document their own request
+// and response models, so versions can serve the same path with different
+// handlers and shapes.
+type Version struct {
+	Name   string // e.g. "v1", served under /api/v1
+	Routes []Route
+}
+
+// Prefix is the path every route of the version is served under.
+func (v Version) Prefix() string {
+	return "/api/" + v.Name
+}
+
+// mountVersion serves v under its prefix, with its own OpenAPI document at
+// <prefix>/openapi.json. It returns the routes as served, wrapped with spec
+// validation and idempotency, and the document.
+func mountVersion(router *Router, v Version, store idempotency.Store, apiCfg config.APIConfig) ([]Route, *openapi.Document) {
+	routes := v.Routes
+
+	// The document is generated from the route table, so it cannot drift
+	// from what is actually served.
+	spec := openapi.Build(openapi.Info{Title: "GoTask Pro API", Version: v.Name}, operations(v.Prefix(), routes))
+	if apiCfg.ValidateAgainstSpec {
+		routes = withSpecValidation(spec, v.Prefix(), routes)
+	}
+	routes = withIdempotency(store, apiCfg.IdempotencyTTL, routes)
+
+	router.Group(v.Prefix(), middleware.APIVersion(v.Name)).Handle(routes...)
+	router.Handle(Route{Method: http.MethodGet, Path: v.Prefix() + "/openapi.json", Handler: spec.SpecHandler()})
+	return routes, spec
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/handlers"
+	"task-manager-server/internal/idempotency"
+	"task-manager-server/internal/openapi"
+	"task-manager-server/internal/response"
+)
+
+// TaskPageV2 stands in for a response model of a v2 models package.
+type TaskPageV2 struct {
+	Items []string `json:"items"`
+	Next  string   `json:"next,omitempty"`
+}
+
+// v2Routes serves GET /tasks with a v2 shape and a path v1 does not have.
+func v2Routes() []Route {
+	list := func(w http.ResponseWriter, r *http.Request) {
+		response.JSON(w, http.StatusOK, TaskPageV2{Items: []string{}})
+	}
+	return []Route{
+		{Method: http.MethodGet, Path: "/tasks", Handler: list, Summary: "List tasks", Tag: "tasks", Response: TaskPageV2{}},
+		{Method: http.MethodGet, Path: "/boards", Handler: list, Summary: "List boards", Tag: "boards", Response: TaskPageV2{}},
+	}
+}
+
+func get(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestVersionsAreServedSideBySide(t *testing.T) {
+	router := NewRouter()
+	store := idempotency.NewMemoryStore()
+	mountVersion(router, Version{Name: "v1", Routes: taskRoutes(handlers.NewTaskHandler(nil))}, store, config.APIConfig{})
+	mountVersion(router, Version{Name: "v2", Routes: v2Routes()}, store, config.APIConfig{})
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantAPI    string
+	}{
+		// The v1 route requires a session; the v2 one on the same path does not.
+		{"/api/v1/tasks", http.StatusUnauthorized, "v1"},
+		{"/api/v2/tasks", http.StatusOK, "v2"},
+		{"/api/v2/boards", http.StatusOK, "v2"},
+		{"/api/v1/boards", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := get(t, router, tt.path)
+		if w.Code != tt.wantStatus {
+			t.Errorf("GET %s: status %d, want %d", tt.path, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("API-Version"); got != tt.wantAPI {
+			t.Errorf("GET %s: API-Version %q, want %q", tt.path, got, tt.wantAPI)
+		}
+	}
+
+	if body := get(t, router, "/api/v2/tasks").Body.String(); body != "{\"items\":[]}\n" {
+		t.Errorf("GET /api/v2/tasks = %s, want the v2 shape", body)
+	}
+}
+
+func TestEachVersionHasItsOwnDocument(t *testing.T) {
+	router := NewRouter()
+	store := idempotency.NewMemoryStore()
+	mountVersion(router, Version{Name: "v1", Routes: taskRoutes(handlers.NewTaskHandler(nil))}, store, config.APIConfig{})
+	mountVersion(router, Version{Name: "v2", Routes: v2Routes()}, store, config.APIConfig{})
+
+	docs := map[string]openapi.Document{}
+	for _, name := range []string{"v1", "v2"} {
+		w := get(t, router, "/api/"+name+"/openapi.json")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/%s/openapi.json: status %d", name, w.Code)
+		}
+		var doc openapi.Document
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Info.Version != name {
+			t.Errorf("the %s document has version %q", name, doc.Info.Version)
+		}
+		docs[name] = doc
+	}
+
+	if docs["v1"].Paths["/api/v1/tasks"] == nil || docs["v1"].Paths["/api/v2/tasks"] != nil {
+		t.Error("the v1 document does not describe exactly the v1 task paths")
+	}
+	if docs["v2"].Paths["/api/v2/boards"] == nil || docs["v2"].Paths["/api/v1/tasks"] != nil {
+		t.Error("the v2 document does not describe exactly the v2 paths")
+	}
+	if docs["v2"].Components.Schemas["TaskPageV2"] == nil || docs["v1"].Components.Schemas["TaskPageV2"] != nil {
+		t.Error("the v2 response model leaked into the v1 document or is missing from v2")
+	}
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"task-manager-server/internal/audit"
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/middleware"
+	"task-manager-server/internal/rpc/pb"
+)
+
+// publicMethods can be called without a token, like the public account
+// routes of the HTTP API.
+var publicMethods = map[string]bool{
+	pb.AuthService_Register_FullMethodName: true,
+	pb.AuthService_Login_FullMethodName:    true,
+	pb.AuthService_LoginMFA_FullMethodName: true,
+	// Reflection only describes the API.
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo":      true,
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo": true,
+}
+
+type contextKey struct{}
+
+// authenticate checks the "authorization: Bearer <token>" metadata of a call
+// the way AuthMiddleware checks the header of a request. The client of the
+// call is kept for the audit log, as middleware.ClientInfo does.
+func authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = audit.WithClient(ctx, audit.Client{
+		IP:        peerIP(ctx),
+		UserAgent: strings.Join(md.Get("user-agent"), " "),
+	})
+	if publicMethods[method] {
+		return ctx, nil
+	}
+
+	userID, _, err := middleware.Authenticate(strings.Join(md.Get("authorization"), ""))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if call := logging.RequestFrom(ctx); call != nil {
+		call.UserID = userID
+	}
+	ctx = logging.With(ctx, "userId", userID)
+	return context.WithValue(ctx, contextKey{}, userID), nil
+}
+
+// userID returns the user authenticated by the interceptors.
+func userID(ctx context.Context) int {
+	id, _ := ctx.Value(contextKey{}).(int)
+	return id
+}
+
+func unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func streamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextStream carries a context with the values the interceptors added,
+// such as the user, to stream handlers.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
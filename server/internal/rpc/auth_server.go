@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/rpc/pb"
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+)
+
+type authServer struct {
+	pb.UnimplementedAuthServiceServer
+	users *services.AuthService
+}
+
+func (s *authServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.User, error) {
+	register := &models.RegisterRequest{
+		Name:     req.GetName(),
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	}
+	if err := validation.Validate(register); err != nil {
+		return nil, statusError(ctx, pb.AuthService_Register_FullMethodName, err)
+	}
+
+	user, err := s.users.Register(ctx, register)
+	if err != nil {
+		return nil, statusError(ctx, pb.AuthService_Register_FullMethodName, err)
+	}
+	return userMessage(user), nil
+}
+
+func (s *authServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.AuthResponse, error) {
+	login := &models.LoginRequest{
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	}
+	if err := validation.Validate(login); err != nil {
+		return nil, statusError(ctx, pb.AuthService_Login_FullMethodName, err)
+	}
+
+	auth, err := s.users.Login(ctx, login, peerIP(ctx))
+	if err != nil {
+		return nil, statusError(ctx, pb.AuthService_Login_FullMethodName, err)
+	}
+	return authMessage(auth), nil
+}
+
+func (s *authServer) LoginMFA(ctx context.Context, req *pb.LoginMFARequest) (*pb.AuthResponse, error) {
+	login := &models.MFALoginRequest{
+		MFAToken:     req.GetMfaToken(),
+		Code:         req.GetCode(),
+		RecoveryCode: req.GetRecoveryCode(),
+	}
+	if err := validation.Validate(login); err != nil {
+		return nil, statusError(ctx, pb.AuthService_LoginMFA_FullMethodName, err)
+	}
+
+	auth, err := s.users.LoginMFA(ctx, login, peerIP(ctx))
+	if err != nil {
+		return nil, statusError(ctx, pb.AuthService_LoginMFA_FullMethodName, err)
+	}
+	return authMessage(auth), nil
+}
+
+func (s *authServer) GetMe(ctx context.Context, req *pb.GetMeRequest) (*pb.User, error) {
+	user, err := s.users.GetUser(ctx, userID(ctx))
+	if err != nil {
+		return nil, statusError(ctx, pb.AuthService_GetMe_FullMethodName, err)
+	}
+	return userMessage(user), nil
+}
+
+func userMessage(user *models.User) *pb.User {
+	return &pb.User{
+		Id:            int64(user.ID),
+		Name:          user.Name,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		IsAdmin:       user.IsAdmin,
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+	}
+}
+
+func authMessage(auth *models.AuthResponse) *pb.AuthResponse {
+	msg := &pb.AuthResponse{
+		Token:       auth.Token,
+		MfaRequired: auth.MFARequired,
+		MfaToken:    auth.MFAToken,
+	}
+	if auth.User != nil {
+		msg.User = userMessage(auth.User)
+	}
+	return msg
+}
+
+// peerIP is the client address that failed logins are counted against.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+)
+
+// serviceCodes maps the errors returned by the services to status codes,
+// as handlers.serviceErrors does to HTTP statuses.
+var serviceCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{services.ErrTaskNotFound, codes.NotFound},
+	{services.ErrUserNotFound, codes.NotFound},
+	{services.ErrVersionConflict, codes.Aborted},
+	{services.ErrParentNotFound, codes.FailedPrecondition},
+	{services.ErrTaskCycle, codes.FailedPrecondition},
+	{services.ErrTaskTooDeep, codes.FailedPrecondition},
+	{services.ErrEmailTaken, codes.AlreadyExists},
+	{services.ErrInvalidCredentials, codes.Unauthenticated},
+	{services.ErrInvalidPassword, codes.Unauthenticated},
+	{services.ErrEmailNotVerified, codes.PermissionDenied},
+	{services.ErrInvalidToken, codes.Unauthenticated},
+	{services.ErrMFACodeRequired, codes.InvalidArgument},
+	{services.ErrInvalidMFACode, codes.Unauthenticated},
+}
+
+// statusError turns an error returned by a service into a status. Errors
+// the services do not declare are logged and reported as INTERNAL so
+// internal details never reach the client.
+func statusError(ctx context.Context, method string, err error) error {
+	var locked *services.LockedError
+	if errors.As(err, &locked) {
+		st, _ := status.New(codes.ResourceExhausted, locked.Error()).WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(locked.RetryAfter),
+		})
+		return st.Err()
+	}
+
+	var invalid validation.Errors
+	if errors.As(err, &invalid) {
+		return validationStatus(invalid)
+	}
+
+	for _, e := range serviceCodes {
+		if errors.Is(err, e.err) {
+			return status.Error(e.code, e.err.Error())
+		}
+	}
+
+	logging.FromContext(ctx).Error("unexpected error", "method", method, "err", err)
+	trace.SpanFromContext(ctx).RecordError(err)
+	return status.Error(codes.Internal, "An unexpected error occurred")
+}
+
+// validationStatus lists every invalid field as a violation. The message
+// repeats the first one, like the detail of validation problems.
+func validationStatus(errs validation.Errors) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(errs))
+	for i, fe := range errs {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       fe.Field,
+			Description: fe.Message,
+			Reason:      fe.Code,
+		}
+	}
+	st, _ := status.New(codes.InvalidArgument, errs[0].Message).WithDetails(&errdetails.BadRequest{
+		FieldViolations: violations,
+	})
+	return st.Err()
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+)
+
+func TestStatusErrorMapsServiceErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{services.ErrTaskNotFound, codes.NotFound},
+		{fmt.Errorf("moving task 3: %w", services.ErrTaskCycle), codes.FailedPrecondition},
+		{services.ErrVersionConflict, codes.Aborted},
+		{services.ErrInvalidCredentials, codes.Unauthenticated},
+		{errors.New("dial tcp 10.0.0.7:3306: connection refused"), codes.Internal},
+	}
+	for _, tt := range tests {
+		st := status.Convert(statusError(context.Background(), "/test", tt.err))
+		if st.Code() != tt.want {
+			t.Errorf("%v: code %s, want %s", tt.err, st.Code(), tt.want)
+		}
+	}
+
+	if st := status.Convert(statusError(context.Background(), "/test", errors.New("secret detail"))); st.Message() != "An unexpected error occurred" {
+		t.Errorf("internal error message = %q, want the generic one", st.Message())
+	}
+}
+
+func TestStatusErrorDetails(t *testing.T) {
+	st := status.Convert(statusError(context.Background(), "/test", validation.Errors{
+		{Field: "title", Code: validation.CodeRequired, Message: "title is required"},
+		{Field: "description", Code: validation.CodeTooLong, Message: "description is too long"},
+	}))
+	if st.Code() != codes.InvalidArgument || st.Message() != "title is required" {
+		t.Fatalf("got %s %q, want InvalidArgument with the first message", st.Code(), st.Message())
+	}
+	bad, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok || len(bad.FieldViolations) != 2 || bad.FieldViolations[1].Reason != validation.CodeTooLong {
+		t.Errorf("details = %v, want both field violations", st.Details())
+	}
+
+	st = status.Convert(statusError(context.Background(), "/test", &services.LockedError{RetryAfter: time.Minute}))
+	retry, ok := st.Details()[0].(*errdetails.RetryInfo)
+	if st.Code() != codes.ResourceExhausted || !ok || retry.RetryDelay.AsDuration() != time.Minute {
+		t.Errorf("got %s %v, want ResourceExhausted retrying after a minute", st.Code(), st.Details())
+	}
+}
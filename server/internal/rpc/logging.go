@@ -0,0 +1,71 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"task-manager-server/internal/logging"
+)
+
+// requestIDKey is the metadata key of request IDs, the gRPC form of the
+// X-Request-ID header.
+const requestIDKey = "x-request-id"
+
+// startCall gives a call an ID and a logger tagged with it, as
+// middleware.RequestLogger does for HTTP requests. The ID is taken from the
+// x-request-id metadata when usable and sent back in the response header.
+func startCall(ctx context.Context, method string) (context.Context, *logging.Request) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := strings.Join(md.Get(requestIDKey), "")
+	if !logging.ValidRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+
+	call := &logging.Request{ID: id, Route: method}
+	ctx = logging.WithRequest(ctx, call)
+	ctx = logging.WithLogger(ctx, logging.ForRequest(ctx, id))
+	return ctx, call
+}
+
+// endCall logs one line per call once it is answered.
+func endCall(ctx context.Context, call *logging.Request, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.String("method", call.Route),
+		slog.String("grpcCode", code.String()),
+		slog.Float64("latencyMs", float64(time.Since(start).Microseconds())/1000),
+	}
+	if call.UserID != 0 {
+		attrs = append(attrs, slog.Int("userId", call.UserID))
+	}
+	logging.ForRequest(ctx, call.ID).LogAttrs(ctx, level, "call", attrs...)
+}
+
+func unaryLog(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx, call := startCall(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	endCall(ctx, call, start, err)
+	return resp, err
+}
+
+func streamLog(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, call := startCall(ss.Context(), info.FullMethod)
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	endCall(ctx, call, start, err)
+	return err
+}
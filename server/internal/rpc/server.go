@@ -0,0 +1,30 @@
+// Package rpc serves the gRPC API. It shares the services, and so the
+// validation, events and tokens, with the HTTP server. The messages and
+// service definitions are generated into pb from proto/taskmanager/v1.
+package rpc
+
+import (
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+
+	"task-manager-server/internal/events"
+	"task-manager-server/internal/rpc/pb"
+	"task-manager-server/internal/services"
+)
+
+// NewServer creates the gRPC server with the task and auth services. It
+// also serves reflection so tools such as grpcurl can discover the API.
+// Calls are traced like HTTP requests, continuing the trace of the
+// traceparent metadata.
+func NewServer(tasks *services.TaskService, users *services.AuthService, broker *events.Broker) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unaryLog, unaryAuth),
+		grpc.ChainStreamInterceptor(streamLog, streamAuth),
+	)
+	pb.RegisterTaskServiceServer(srv, &taskServer{tasks: tasks, events: broker})
+	pb.RegisterAuthServiceServer(srv, &authServer{users: users})
+	reflection.Register(srv)
+	return srv
+}
@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"task-manager-server/internal/events"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/rpc/pb"
+	"task-manager-server/internal/services"
+	"task-manager-server/internal/validation"
+)
+
+type taskServer struct {
+	pb.UnimplementedTaskServiceServer
+	tasks  *services.TaskService
+	events *events.Broker
+}
+
+func (s *taskServer) ListTasks(ctx context.Context, req *pb.ListTasksRequest) (*pb.ListTasksResponse, error) {
+	tasks, err := s.tasks.GetTasks(ctx, userID(ctx))
+	if err != nil {
+		return nil, statusError(ctx, pb.TaskService_ListTasks_FullMethodName, err)
+	}
+	resp := &pb.ListTasksResponse{Tasks: make([]*pb.Task, len(tasks))}
+	for i := range tasks {
+		resp.Tasks[i] = taskMessage(&tasks[i])
+	}
+	return resp, nil
+}
+
+func (s *taskServer) GetTask(ctx context.Context, req *pb.GetTaskRequest) (*pb.Task, error) {
+	task, err := s.tasks.GetTask(ctx, int(req.GetId()), userID(ctx))
+	if err != nil {
+		return nil, statusError(ctx, pb.TaskService_GetTask_FullMethodName, err)
+	}
+	return taskMessage(task), nil
+}
+
+func (s *taskServer) CreateTask(ctx context.Context, req *pb.CreateTaskRequest) (*pb.Task, error) {
+	create := &models.CreateTaskRequest{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Done:        req.GetDone(),
+		ParentID:    intPtr(req.ParentId),
+	}
+	if err := validation.Validate(create); err != nil {
+		return nil, statusError(ctx, pb.TaskService_CreateTask_FullMethodName, err)
+	}
+
+	task, err := s.tasks.CreateTask(ctx, create, userID(ctx))
+	if err != nil {
+		return nil, statusError(ctx, pb.TaskService_CreateTask_FullMethodName, err)
+	}
+	return taskMessage(task), nil
+}
+
+func (s *taskServer) UpdateTask(ctx context.Context, req *pb.UpdateTaskRequest) (*pb.Task, error) {
+	update := &models.UpdateTaskRequest{
+		Title:       req.Title,
+		Description: req.Description,
+		Done:        req.Done,
+	}
+	if err := validation.Validate(update); err != nil {
+		return nil, statusError(ctx, pb.TaskService_UpdateTask_FullMethodName, err)
+	}
+
+	task, err := s.tasks.UpdateTask(ctx, int(req.GetId()), userID(ctx), update, req.IfVersion)
+	if err != nil {
+		return nil, statusError(ctx, pb.TaskService_UpdateTask_FullMethodName, err)
+	}
+	return taskMessage(task), nil
+}
+
+func (s *taskServer) MoveTask(ctx context.Context, req *pb.MoveTaskRequest) (*pb.Task, error) {
+	task, err := s.tasks.MoveTask(ctx, int(req.GetId()), userID(ctx), intPtr(req.ParentId), req.IfVersion)
+	if err != nil {
+		return nil, statusError(ctx, pb.TaskService_MoveTask_FullMethodName, err)
+	}
+	return taskMessage(task), nil
+}
+
+func (s *taskServer) DeleteTask(ctx context.Context, req *pb.DeleteTaskRequest) (*pb.DeleteTaskResponse, error) {
+	if err := s.tasks.DeleteTask(ctx, int(req.GetId()), userID(ctx), req.IfVersion); err != nil {
+		return nil, statusError(ctx, pb.TaskService_DeleteTask_FullMethodName, err)
+	}
+	return &pb.DeleteTaskResponse{}, nil
+}
+
+// WatchTasks streams the user's task events from the broker that feeds the
+// Server-Sent Events and WebSocket endpoints.
+func (s *taskServer) WatchTasks(req *pb.WatchTasksRequest, stream grpc.ServerStreamingServer[pb.TaskEvent]) error {
+	ctx := stream.Context()
+	sub, _, _ := s.events.Subscribe(userID(ctx), "")
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "Fell behind the task events; list the tasks and watch again")
+			}
+			msg := eventMessage(e)
+			if req.TaskId != nil && msg.TaskId != req.GetTaskId() {
+				continue
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func taskMessage(task *models.Task) *pb.Task {
+	msg := &pb.Task{
+		Id:          int64(task.ID),
+		Title:       task.Title,
+		Description: task.Description,
+		Done:        task.Done,
+		UserId:      int64(task.UserID),
+		Version:     task.Version,
+		CreatedAt:   timestamppb.New(task.CreatedAt),
+		UpdatedAt:   timestamppb.New(task.UpdatedAt),
+	}
+	if task.ParentID != nil {
+		parentID := int64(*task.ParentID)
+		msg.ParentId = &parentID
+	}
+	return msg
+}
+
+func eventMessage(e events.Event) *pb.TaskEvent {
+	msg := &pb.TaskEvent{
+		Id:   e.ID,
+		Type: e.Type,
+		Time: timestamppb.New(e.Time),
+	}
+	switch data := e.Data.(type) {
+	case *models.Task:
+		msg.TaskId = int64(data.ID)
+		msg.Version = data.Version
+		msg.Task = taskMessage(data)
+	case models.DeletedTask:
+		msg.TaskId = int64(data.ID)
+		msg.Version = data.Version
+	}
+	return msg
+}
+
+func intPtr(v *int64) *int {
+	if v == nil {
+		return nil
+	}
+	i := int(*v)
+	return &i
+}
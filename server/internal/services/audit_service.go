@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager-server/internal/audit"
+	"task-manager-server/internal/models"
+)
+
+// Audit actions. Task changes are recorded under the types of their events,
+// e.g. task.updated; labels and comments are not part of the versioned task
+// and have actions of their own.
+const (
+	AuditTaskLabeled   = "task.labeled"
+	AuditTaskCommented = "task.commented"
+
+	AuditUserRegistered           = "user.registered"
+	AuditUserLoggedIn             = "user.logged_in"
+	AuditVerificationRequested    = "user.verification_requested"
+	AuditEmailVerified            = "user.email_verified"
+	AuditPasswordResetRequested   = "user.password_reset_requested"
+	AuditPasswordReset            = "user.password_reset"
+	AuditUnlockRequested          = "user.unlock_requested"
+	AuditAccountUnlocked          = "user.unlocked"
+	AuditLockoutCleared           = "lockout.cleared"
+	AuditMFASetupStarted          = "user.mfa_setup_started"
+	AuditMFAEnabled               = "user.mfa_enabled"
+	AuditMFADisabled              = "user.mfa_disabled"
+	AuditRecoveryCodesRegenerated = "user.recovery_codes_regenerated"
+	AuditProjectCreated           = "project.created"
+	AuditProjectMemberAdded       = "project.member_added"
+	AuditProjectMemberRemoved     = "project.member_removed"
+)
+
+// Audited entity types. Lockouts are kept per normalized email and per IP
+// address, so their entities are identified by those.
+const (
+	EntityTask    = "task"
+	EntityUser    = "user"
+	EntityAccount = "account"
+	EntityIP      = "ip"
+	EntityProject = "project"
+)
+
+const (
+	defaultAuditPage = 100
+	maxAuditPage     = 1000
+	maxUserAgent     = 255
+)
+
+const auditColumns = `id, actor_id, action, entity_type, entity_id, changes, ip_address, user_agent, created_at`
+
+// recordAudit appends e to the audit log with the client of ctx. Task
+// changes run it in their transaction, so an entry exists exactly when its
+// change was committed; account changes record right after they are made.
+func recordAudit(ctx context.Context, q dbtx, e *models.AuditEntry) error {
+	client := audit.ClientFrom(ctx)
+	e.IP = client.IP
+	e.UserAgent = client.UserAgent
+	if len(e.UserAgent) > maxUserAgent {
+		e.UserAgent = e.UserAgent[:maxUserAgent]
+	}
+	e.CreatedAt = time.Now()
+
+	var changes *string
+	if len(e.Changes) > 0 {
+		data, err := json.Marshal(e.Changes)
+		if err != nil {
+			return err
+		}
+		s := string(data)
+		changes = &s
+	}
+
+	res, err := q.Exec(
+		`INSERT INTO audit_log (actor_id, action, entity_type, entity_id, changes, ip_address, user_agent, created_at)
+       VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ActorID,
+		e.Action,
+		e.EntityType,
+		e.EntityID,
+		changes,
+		e.IP,
+		e.UserAgent,
+		e.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	e.ID, err = res.LastInsertId()
+	return err
+}
+
+// auditUser records a change of a user's account. actorID is nil when it
+// was asked for anonymously, e.g. a password reset email.
+func auditUser(ctx context.Context, q dbtx, actorID *int, action string, userID int, changes map[string]models.AuditChange) error {
+	return recordAudit(ctx, q, &models.AuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		EntityType: EntityUser,
+		EntityID:   strconv.Itoa(userID),
+		Changes:    changes,
+	})
+}
+
+// AuditFilter selects audit entries. Zero fields match everything; From is
+// inclusive and To exclusive. Before is the page cursor: only entries with
+// a smaller ID match.
+type AuditFilter struct {
+	ActorID    *int
+	Action     string
+	EntityType string
+	EntityID   string
+	From       time.Time
+	To         time.Time
+	Before     int64
+	Limit      int
+}
+
+func (f AuditFilter) where() (string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+	if f.ActorID != nil {
+		add("actor_id = ?", *f.ActorID)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.EntityType != "" {
+		add("entity_type = ?", f.EntityType)
+	}
+	if f.EntityID != "" {
+		add("entity_id = ?", f.EntityID)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < ?", f.To)
+	}
+	if f.Before > 0 {
+		add("id < ?", f.Before)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// AuditService reads the audit log. Entries are only ever appended, by the
+// services making the changes.
+type AuditService struct {
+	db *sql.DB
+}
+
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Query returns a page of the entries matching f, newest first.
+func (s *AuditService) Query(ctx context.Context, f AuditFilter) (*models.AuditLogResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuditService.Query")
+	defer span.End()
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultAuditPage
+	}
+	limit = min(limit, maxAuditPage)
+
+	where, args := f.where()
+	// One more than the page tells whether there is a next one.
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT `+auditColumns+` FROM audit_log`+where+` ORDER BY id DESC LIMIT `+strconv.Itoa(limit+1),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.AuditLogResponse{Entries: []models.AuditEntry{}}
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Entries = append(page.Entries, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Entries) > limit {
+		page.Entries = page.Entries[:limit]
+		page.Before = &page.Entries[limit-1].ID
+	}
+	return page, nil
+}
+
+// Export calls fn with every entry matching f, oldest first, without
+// holding them all in memory. f.Limit and f.Before are ignored. It stops
+// when ctx is done, e.g. when the client of a download goes away.
+func (s *AuditService) Export(ctx context.Context, f AuditFilter, fn func(e *models.AuditEntry) error) error {
+	ctx, span := tracer.Start(ctx, "AuditService.Export")
+	defer span.End()
+
+	f.Before = 0
+	where, args := f.where()
+	rows, err := s.db.QueryContext(ctx, `SELECT `+auditColumns+` FROM audit_log`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanAuditEntry(row interface{ Scan(dest ...any) error }) (*models.AuditEntry, error) {
+	var e models.AuditEntry
+	var actorID sql.NullInt64
+	var changes sql.NullString
+	if err := row.Scan(
+		&e.ID,
+		&actorID,
+		&e.Action,
+		&e.EntityType,
+		&e.EntityID,
+		&changes,
+		&e.IP,
+		&e.UserAgent,
+		&e.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		e.ActorID = &id
+	}
+	if changes.Valid {
+		if err := json.Unmarshal([]byte(changes.String), &e.Changes); err != nil {
+			return nil, err
+		}
+	}
+	return &e, nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"task-manager-server/internal/models"
+)
+
+func newTestAuditService(t *testing.T) (*AuditService, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return NewAuditService(db), mock
+}
+
+var auditRow = []string{"id", "actor_id", "action", "entity_type", "entity_id", "changes", "ip_address", "user_agent", "created_at"}
+
+func TestAuditFilterWhere(t *testing.T) {
+	actor := 7
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	where, args := AuditFilter{}.where()
+	if where != "" || args != nil {
+		t.Errorf("empty filter: %q %v, want no conditions", where, args)
+	}
+
+	where, args = AuditFilter{
+		ActorID: &actor, Action: "task.updated", EntityType: EntityTask, EntityID: "3",
+		From: from, To: to, Before: 50,
+	}.where()
+	want := " WHERE actor_id = ? AND action = ? AND entity_type = ? AND entity_id = ?" +
+		" AND created_at >= ? AND created_at < ? AND id < ?"
+	if where != want {
+		t.Errorf("where = %q, want %q", where, want)
+	}
+	if len(args) != 7 || args[0] != 7 || args[4] != from || args[5] != to || args[6] != int64(50) {
+		t.Errorf("args = %v", args)
+	}
+}
+
+// A page fetches one entry more than its limit; when that one exists, the
+// page ends with a cursor to the next.
+func TestAuditQueryPages(t *testing.T) {
+	s, mock := newTestAuditService(t)
+	now := time.Now()
+
+	mock.ExpectQuery(q("SELECT "+auditColumns+" FROM audit_log WHERE action = ? AND id < ? ORDER BY id DESC LIMIT 3")).
+		WithArgs("task.deleted", int64(90)).
+		WillReturnRows(sqlmock.NewRows(auditRow).
+			AddRow(12, 1, "task.deleted", EntityTask, "3", `{"title":{"from":"Milk","to":"Bread"}}`, "", "", now).
+			AddRow(11, nil, "task.deleted", EntityTask, "2", nil, "", "", now).
+			AddRow(10, 1, "task.deleted", EntityTask, "1", nil, "", "", now))
+
+	page, err := s.Query(context.Background(), AuditFilter{Action: "task.deleted", Before: 90, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 2 || page.Before == nil || *page.Before != 11 {
+		t.Fatalf("page = %+v, want entries 12 and 11 and a cursor at 11", page)
+	}
+	if page.Entries[0].ActorID == nil || page.Entries[1].ActorID != nil {
+		t.Errorf("actors = %v, %v; want 1 and none", page.Entries[0].ActorID, page.Entries[1].ActorID)
+	}
+	if page.Entries[0].Changes["title"].From != "Milk" {
+		t.Errorf("changes = %+v", page.Entries[0].Changes)
+	}
+}
+
+func TestAuditQueryBoundsLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  string
+	}{
+		{0, "LIMIT 101"},
+		{5000, "LIMIT 1001"},
+	}
+	for _, tt := range tests {
+		s, mock := newTestAuditService(t)
+		mock.ExpectQuery(q(tt.want)).WillReturnRows(sqlmock.NewRows(auditRow))
+
+		page, err := s.Query(context.Background(), AuditFilter{Limit: tt.limit})
+		if err != nil {
+			t.Fatalf("limit %d: %v", tt.limit, err)
+		}
+		if page.Entries == nil || page.Before != nil {
+			t.Errorf("limit %d: page = %+v, want an empty last page", tt.limit, page)
+		}
+	}
+}
+
+// Exports run oldest first over every page, whatever the cursor.
+func TestAuditExportIgnoresCursor(t *testing.T) {
+	s, mock := newTestAuditService(t)
+
+	mock.ExpectQuery(q("SELECT " + auditColumns + " FROM audit_log WHERE entity_type = ? ORDER BY id")).
+		WithArgs(EntityUser).
+		WillReturnRows(sqlmock.NewRows(auditRow).
+			AddRow(1, 1, AuditUserRegistered, EntityUser, "1", nil, "", "", time.Now()).
+			AddRow(2, 1, AuditUserLoggedIn, EntityUser, "1", nil, "", "", time.Now()))
+
+	var ids []int64
+	err := s.Export(context.Background(), AuditFilter{EntityType: EntityUser, Before: 2, Limit: 1}, func(e *models.AuditEntry) error {
+		ids = append(ids, e.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != 1 {
+		t.Errorf("exported %v, want 1 and 2", ids)
+	}
+}
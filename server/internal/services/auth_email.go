@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"task-manager-server/internal/mailer"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := s.issueActionToken(ctx, user.ID, purposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.cfg.AppURL, url.QueryEscape(token))
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in 24 hours.\n",
+			user.Name, link,
+		),
+	})
+}
+
+// ResendVerification sends a fresh verification link. Unknown or already
+// verified addresses are ignored so the endpoint cannot be used to probe
+// which emails are registered.
+func (s *AuthService) ResendVerification(ctx context.Context, email string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.ResendVerification")
+	defer span.End()
+
+	user, err := s.findUserByEmail(ctx, email)
+	if err != nil || user == nil || user.EmailVerified {
+		return err
+	}
+	if err := auditUser(ctx, repository.Bind(ctx, s.db), nil, AuditVerificationRequested, user.ID, nil); err != nil {
+		return err
+	}
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// VerifyEmail consumes a verification token and marks the address verified.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.VerifyEmail")
+	defer span.End()
+
+	return s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		userID, err := s.consumeActionToken(tx, token, purposeVerifyEmail)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Exec(
+			`UPDATE users SET email_verified_at = ? WHERE id = ? AND email_verified_at IS NULL`,
+			time.Now(),
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		return auditUser(ctx, tx, &userID, AuditEmailVerified, userID, map[string]models.AuditChange{
+			"emailVerified": {From: false, To: true},
+		})
+	})
+}
+
+// RequestPasswordReset emails a reset link. Like ResendVerification it stays
+// silent about unknown addresses.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.RequestPasswordReset")
+	defer span.End()
+
+	user, err := s.findUserByEmail(ctx, email)
+	if err != nil || user == nil {
+		return err
+	}
+	if err := auditUser(ctx, repository.Bind(ctx, s.db), nil, AuditPasswordResetRequested, user.ID, nil); err != nil {
+		return err
+	}
+
+	token, err := s.issueActionToken(ctx, user.ID, purposeResetPassword, resetPasswordTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", s.cfg.AppURL, url.QueryEscape(token))
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password of your account. If it was you, open the link below:\n\n%s\n\nThe link expires in 1 hour. If you did not ask for this, you can ignore this email.\n",
+			user.Name, link,
+		),
+	})
+}
+
+// ResetPassword consumes a reset token and sets the new password. Any other
+// outstanding reset links for the account stop working. All of it is one
+// transaction, so a failure leaves the link usable.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.ResetPassword")
+	defer span.End()
+
+	// Reject bad tokens before spending time on the hash.
+	if _, _, err := s.parseActionToken(token, purposeResetPassword); err != nil {
+		return err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		userID, err := s.consumeActionToken(tx, token, purposeResetPassword)
+		if err != nil {
+			return err
+		}
+
+		// Following a link from the inbox also proves ownership of the address.
+		if _, err := tx.Exec(
+			`UPDATE users SET password = ?, email_verified_at = COALESCE(email_verified_at, ?) WHERE id = ?`,
+			string(hashedPassword),
+			time.Now(),
+			userID,
+		); err != nil {
+			return err
+		}
+		// The hashes are never recorded, only that the password changed.
+		if err := auditUser(ctx, tx, &userID, AuditPasswordReset, userID, nil); err != nil {
+			return err
+		}
+
+		return s.revokeActionTokens(tx, userID, purposeResetPassword)
+	})
+}
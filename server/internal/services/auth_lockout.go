@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/mailer"
+	"task-manager-server/internal/metrics"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+)
+
+const (
+	purposeUnlockAccount = "unlock_account"
+	unlockAccountTTL     = time.Hour
+)
+
+// checkLockout returns a *LockedError when either the account or the IP is
+// currently locked.
+func (s *AuthService) checkLockout(ctx context.Context, email, ip string) error {
+	accountDelay, err := s.accountLimiter.Check(ctx, email)
+	if err != nil {
+		return err
+	}
+	ipDelay, err := s.ipLimiter.Check(ctx, ip)
+	if err != nil {
+		return err
+	}
+
+	if delay := max(accountDelay, ipDelay); delay > 0 {
+		s.auditLoginFailure(ctx, email, ip, "locked")
+		return &LockedError{RetryAfter: delay}
+	}
+	return nil
+}
+
+// recordLoginFailure audits a failed attempt and counts it against the
+// account and the IP. It returns a *LockedError when this attempt triggered
+// a lockout.
+func (s *AuthService) recordLoginFailure(ctx context.Context, email, ip, reason string) error {
+	metrics.LoginFailures.WithLabelValues(reason).Inc()
+	s.auditLoginFailure(ctx, email, ip, reason)
+
+	accountDelay, err := s.accountLimiter.Fail(ctx, email)
+	if err != nil {
+		return err
+	}
+	ipDelay, err := s.ipLimiter.Fail(ctx, ip)
+	if err != nil {
+		return err
+	}
+
+	if accountDelay > 0 {
+		if err := s.sendUnlockEmail(ctx, email); err != nil {
+			logging.FromContext(ctx).Error("Lockout: failed to send unlock email", "err", err)
+		}
+	}
+
+	if delay := max(accountDelay, ipDelay); delay > 0 {
+		return &LockedError{RetryAfter: delay}
+	}
+	return nil
+}
+
+// auditLoginFailure keeps a trail of failed attempts. It is best effort and
+// never blocks the login flow.
+func (s *AuthService) auditLoginFailure(ctx context.Context, email, ip, reason string) {
+	if _, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO login_failures (email, ip_address, reason, created_at) VALUES (?, ?, ?, ?)`,
+		email,
+		ip,
+		reason,
+		time.Now(),
+	); err != nil {
+		logging.FromContext(ctx).Error("Lockout: failed to audit login failure", "err", err)
+	}
+}
+
+// RequestUnlock emails the account owner a link that lifts the lockout.
+// Unknown addresses are ignored. Requests are throttled per address and per
+// client IP and return a *LockedError once either is over the limit.
+func (s *AuthService) RequestUnlock(ctx context.Context, email, ip string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.RequestUnlock")
+	defer span.End()
+
+	email = normalizeEmail(email)
+	keys := []string{"email:" + email, "ip:" + ip}
+	for _, key := range keys {
+		delay, err := s.unlockLimiter.Check(ctx, key)
+		if err != nil {
+			return err
+		}
+		if delay > 0 {
+			return &LockedError{RetryAfter: delay}
+		}
+	}
+	for _, key := range keys {
+		if _, err := s.unlockLimiter.Fail(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return s.sendUnlockEmail(ctx, email)
+}
+
+// sendUnlockEmail mails an unlock link unless the user still holds an
+// unused one, so an account gets one email per lock window however often it
+// is relocked or a link is requested.
+func (s *AuthService) sendUnlockEmail(ctx context.Context, email string) error {
+	user, err := s.findUserByEmail(ctx, email)
+	if err != nil || user == nil {
+		return err
+	}
+
+	var pending int
+	if err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM auth_tokens
+       WHERE user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?`,
+		user.ID,
+		purposeUnlockAccount,
+		time.Now(),
+	).Scan(&pending); err != nil {
+		return err
+	}
+	if pending > 0 {
+		return nil
+	}
+
+	if err := auditUser(ctx, repository.Bind(ctx, s.db), nil, AuditUnlockRequested, user.ID, nil); err != nil {
+		return err
+	}
+
+	token, err := s.issueActionToken(ctx, user.ID, purposeUnlockAccount, unlockAccountTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/unlock-account?token=%s", s.cfg.AppURL, url.QueryEscape(token))
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your account has been locked",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nWe locked your account after several failed sign-in attempts. If they were yours, you can unlock it with the link below:\n\n%s\n\nIf they were not, consider resetting your password.\n",
+			user.Name, link,
+		),
+	})
+}
+
+// UnlockAccount consumes an unlock token and clears the account lockout.
+func (s *AuthService) UnlockAccount(ctx context.Context, token string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.UnlockAccount")
+	defer span.End()
+
+	userID, err := s.consumeActionToken(repository.Bind(ctx, s.db), token, purposeUnlockAccount)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.findUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrInvalidToken
+	}
+
+	if err := s.accountLimiter.Reset(ctx, normalizeEmail(user.Email)); err != nil {
+		return err
+	}
+	return auditUser(ctx, repository.Bind(ctx, s.db), &userID, AuditAccountUnlocked, userID, nil)
+}
+
+// AdminUnlock clears the lockout of an account, an IP address, or both, on
+// behalf of the administrator adminID.
+func (s *AuthService) AdminUnlock(ctx context.Context, adminID int, req *models.AdminUnlockRequest) error {
+	ctx, span := tracer.Start(ctx, "AuthService.AdminUnlock")
+	defer span.End()
+
+	if req.Email == "" && req.IP == "" {
+		return ErrUnlockTargetMissing
+	}
+	if req.Email != "" {
+		email := normalizeEmail(req.Email)
+		if err := s.accountLimiter.Reset(ctx, email); err != nil {
+			return err
+		}
+		if err := s.auditLockoutCleared(ctx, adminID, EntityAccount, email); err != nil {
+			return err
+		}
+	}
+	if req.IP != "" {
+		if err := s.ipLimiter.Reset(ctx, req.IP); err != nil {
+			return err
+		}
+		if err := s.auditLockoutCleared(ctx, adminID, EntityIP, req.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *AuthService) auditLockoutCleared(ctx context.Context, adminID int, entityType, entityID string) error {
+	return recordAudit(ctx, repository.Bind(ctx, s.db), &models.AuditEntry{
+		ActorID:    &adminID,
+		Action:     AuditLockoutCleared,
+		EntityType: entityType,
+		EntityID:   entityID,
+	})
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/lockout"
+	"task-manager-server/internal/mailer"
+	"task-manager-server/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// outbox is a mailer keeping what it was asked to send.
+type outbox struct {
+	sent []mailer.Message
+}
+
+func (o *outbox) Send(msg mailer.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+func newTestAuthService(t *testing.T) (*AuthService, sqlmock.Sqlmock, *outbox) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	uow := repository.NewUnitOfWork(db, config.TxConfig{MaxAttempts: 1})
+	cfg := config.AuthConfig{
+		AppURL: "https://tasks.example",
+		UnlockRequests: lockout.Policy{
+			MaxAttempts: 2,
+			BaseDelay:   time.Minute,
+			MaxDelay:    time.Hour,
+			Window:      time.Hour,
+		},
+	}
+	box := &outbox{}
+	return NewAuthService(db, uow, box, lockout.NewMemoryStore(), cfg), mock, box
+}
+
+var userRow = []string{"id", "name", "email", "email_verified_at", "is_admin", "created_at"}
+
+func expectUnknownUser(mock sqlmock.Sqlmock, email string) {
+	mock.ExpectQuery(q("FROM users")).WithArgs(email).WillReturnRows(sqlmock.NewRows(userRow))
+}
+
+func expectUser(mock sqlmock.Sqlmock, id int, email string) {
+	mock.ExpectQuery(q("FROM users")).WithArgs(email).
+		WillReturnRows(sqlmock.NewRows(userRow).AddRow(id, "Ada", email, nil, false, time.Now()))
+}
+
+func expectPendingUnlocks(mock sqlmock.Sqlmock, userID, pending int) {
+	mock.ExpectQuery(q("SELECT COUNT(*) FROM auth_tokens")).
+		WithArgs(userID, purposeUnlockAccount, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(pending))
+}
+
+func TestRequestUnlockThrottlesPerAddress(t *testing.T) {
+	s, mock, _ := newTestAuthService(t)
+	ctx := context.Background()
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		expectUnknownUser(mock, "ada@example.com")
+		if err := s.RequestUnlock(ctx, "Ada@Example.com ", ip); err != nil {
+			t.Fatalf("request from %s: %v", ip, err)
+		}
+	}
+
+	var locked *LockedError
+	if err := s.RequestUnlock(ctx, "ada@example.com", "10.0.0.9"); !errors.As(err, &locked) {
+		t.Fatalf("third request for the address: err = %v, want a *LockedError", err)
+	}
+}
+
+func TestRequestUnlockThrottlesPerIP(t *testing.T) {
+	s, mock, _ := newTestAuthService(t)
+	ctx := context.Background()
+
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		expectUnknownUser(mock, email)
+		if err := s.RequestUnlock(ctx, email, "10.0.0.1"); err != nil {
+			t.Fatalf("%s: %v", email, err)
+		}
+	}
+
+	var locked *LockedError
+	if err := s.RequestUnlock(ctx, "c@example.com", "10.0.0.1"); !errors.As(err, &locked) {
+		t.Fatalf("third request from the IP: err = %v, want a *LockedError", err)
+	}
+
+	expectUnknownUser(mock, "c@example.com")
+	if err := s.RequestUnlock(ctx, "c@example.com", "10.0.0.2"); err != nil {
+		t.Fatalf("request from another IP: %v", err)
+	}
+}
+
+func TestSendUnlockEmailOncePerLink(t *testing.T) {
+	s, mock, box := newTestAuthService(t)
+	ctx := context.Background()
+
+	expectUser(mock, 7, "ada@example.com")
+	expectPendingUnlocks(mock, 7, 0)
+	mock.ExpectExec(q("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(q("INSERT INTO auth_tokens")).
+		WithArgs(sqlmock.AnyArg(), 7, purposeUnlockAccount, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.sendUnlockEmail(ctx, "ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(box.sent) != 1 || !strings.Contains(box.sent[0].Body, "https://tasks.example/unlock-account?token=") {
+		t.Fatalf("sent %+v, want one unlock link", box.sent)
+	}
+
+	expectUser(mock, 7, "ada@example.com")
+	expectPendingUnlocks(mock, 7, 1)
+	if err := s.sendUnlockEmail(ctx, "ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(box.sent) != 1 {
+		t.Fatalf("sent %d emails while the first link is valid, want 1", len(box.sent))
+	}
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"task-manager-server/internal/metrics"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+	"task-manager-server/internal/totp"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpIssuer        = "GoTask Pro"
+	recoveryCodeCount = 10
+	recoveryAlphabet  = "abcdefghijkmnpqrstuvwxyz23456789"
+)
+
+type totpState struct {
+	password string
+	secret   sql.NullString
+	enabled  bool
+}
+
+// SetupTOTP starts enrolment by generating a new secret. The secret is
+// stored but 2FA stays off until ConfirmTOTP sees a valid code.
+func (s *AuthService) SetupTOTP(ctx context.Context, userID int) (*models.TOTPSetupResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.SetupTOTP")
+	defer span.End()
+
+	user, err := s.findUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	state, err := s.loadTOTPState(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.Exec(
+			`UPDATE users SET totp_secret = ?, totp_last_step = NULL WHERE id = ?`,
+			secret,
+			userID,
+		); err != nil {
+			return err
+		}
+		return auditUser(ctx, tx, &userID, AuditMFASetupStarted, userID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(totpIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA once the user proves the authenticator app works,
+// and hands out the first set of recovery codes.
+func (s *AuthService) ConfirmTOTP(ctx context.Context, userID int, code string) (*models.RecoveryCodesResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.ConfirmTOTP")
+	defer span.End()
+
+	state, err := s.loadTOTPState(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if !state.secret.Valid {
+		return nil, ErrMFASetupNotStarted
+	}
+
+	if err := verifyTOTPCode(repository.Bind(ctx, s.db), userID, state.secret.String, code); err != nil {
+		return nil, err
+	}
+
+	// 2FA is never on without recovery codes.
+	var codes *models.RecoveryCodesResponse
+	err = s.uow.WithTx(ctx, func(tx *repository.Tx) (err error) {
+		if _, err := tx.Exec(`UPDATE users SET totp_enabled = 1 WHERE id = ?`, userID); err != nil {
+			return err
+		}
+		if err := auditUser(ctx, tx, &userID, AuditMFAEnabled, userID, map[string]models.AuditChange{
+			"mfaEnabled": {From: false, To: true},
+		}); err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// LoginMFA completes a login that was answered with an MFA challenge. Wrong
+// codes count towards the same lockout as wrong passwords and leave the
+// challenge usable; the first right one uses it up with the session.
+func (s *AuthService) LoginMFA(ctx context.Context, req *models.MFALoginRequest, ip string) (*models.AuthResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.LoginMFA")
+	defer span.End()
+
+	_, userID, err := s.parseActionToken(req.MFAToken, purposeMFAChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.findUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+
+	email := normalizeEmail(user.Email)
+	if err := s.checkLockout(ctx, email, ip); err != nil {
+		return nil, err
+	}
+
+	state, err := s.loadTOTPState(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !state.enabled {
+		return nil, ErrInvalidToken
+	}
+
+	// The second factor is used up in the transaction that consumes the
+	// challenge, so a code or recovery code is never spent on a challenge
+	// that was already used.
+	var (
+		session *models.AuthResponse
+		badCode error
+	)
+	err = s.uow.WithTx(ctx, func(tx *repository.Tx) (err error) {
+		if badCode = verifySecondFactor(tx, userID, state, req.Code, req.RecoveryCode); badCode != nil {
+			return badCode
+		}
+		if _, err := s.consumeActionToken(tx, req.MFAToken, purposeMFAChallenge); err != nil {
+			return err
+		}
+		session, err = s.newSession(ctx, tx, user)
+		return err
+	})
+	if badCode != nil {
+		if lockErr := s.recordLoginFailure(ctx, email, ip, "invalid_mfa_code"); lockErr != nil {
+			return nil, lockErr
+		}
+		return nil, badCode
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.accountLimiter.Reset(ctx, email); err != nil {
+		return nil, err
+	}
+	metrics.Logins.Inc()
+	return session, nil
+}
+
+// DisableTOTP turns 2FA off after re-authenticating the user.
+func (s *AuthService) DisableTOTP(ctx context.Context, userID int, req *models.MFAReauthRequest) error {
+	ctx, span := tracer.Start(ctx, "AuthService.DisableTOTP")
+	defer span.End()
+
+	if err := s.reauthenticate(ctx, userID, req); err != nil {
+		return err
+	}
+
+	return s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := tx.Exec(
+			`UPDATE users SET totp_enabled = 0, totp_secret = NULL, totp_last_step = NULL WHERE id = ?`,
+			userID,
+		); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		return auditUser(ctx, tx, &userID, AuditMFADisabled, userID, map[string]models.AuditChange{
+			"mfaEnabled": {From: true, To: false},
+		})
+	})
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the user after
+// re-authenticating them.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID int, req *models.MFAReauthRequest) (*models.RecoveryCodesResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.RegenerateRecoveryCodes")
+	defer span.End()
+
+	if err := s.reauthenticate(ctx, userID, req); err != nil {
+		return nil, err
+	}
+	var codes *models.RecoveryCodesResponse
+	err := s.uow.WithTx(ctx, func(tx *repository.Tx) (err error) {
+		if codes, err = replaceRecoveryCodes(tx, userID); err != nil {
+			return err
+		}
+		return auditUser(ctx, tx, &userID, AuditRecoveryCodesRegenerated, userID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *AuthService) reauthenticate(ctx context.Context, userID int, req *models.MFAReauthRequest) error {
+	state, err := s.loadTOTPState(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(state.password), []byte(req.Password)); err != nil {
+		return ErrInvalidPassword
+	}
+	if !state.enabled {
+		return ErrMFANotEnabled
+	}
+	return verifySecondFactor(repository.Bind(ctx, s.db), userID, state, req.Code, req.RecoveryCode)
+}
+
+func (s *AuthService) loadTOTPState(ctx context.Context, userID int) (*totpState, error) {
+	var state totpState
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT password, totp_secret, totp_enabled FROM users WHERE id = ? LIMIT 1`,
+		userID,
+	).Scan(&state.password, &state.secret, &state.enabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// verifySecondFactor checks a TOTP code or, without one, a recovery code,
+// and uses it up on q.
+func verifySecondFactor(q dbtx, userID int, state *totpState, code, recoveryCode string) error {
+	switch {
+	case code != "":
+		return verifyTOTPCode(q, userID, state.secret.String, code)
+	case recoveryCode != "":
+		return useRecoveryCode(q, userID, recoveryCode)
+	default:
+		return ErrMFACodeRequired
+	}
+}
+
+// verifyTOTPCode accepts a code at most once: the matched time step is
+// stored and later codes must belong to a newer step.
+func verifyTOTPCode(q dbtx, userID int, secret, code string) error {
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	res, err := q.Exec(
+		`UPDATE users SET totp_last_step = ?
+       WHERE id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)`,
+		step,
+		userID,
+		step,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func useRecoveryCode(q dbtx, userID int, code string) error {
+	res, err := q.Exec(
+		`UPDATE recovery_codes SET used_at = ?
+       WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		time.Now(),
+		userID,
+		hashRecoveryCode(code),
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes swaps the user's recovery codes for new ones. It
+// runs on the caller's transaction so the old codes are only gone if the
+// new ones are stored.
+func replaceRecoveryCodes(tx dbtx, userID int) (*models.RecoveryCodesResponse, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, code := range codes {
+		if _, err := tx.Exec(
+			`INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)`,
+			userID,
+			hashRecoveryCode(code),
+			now,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// generateRecoveryCode returns a code such as "k7m2p-x9qrt". The alphabet
+// has 32 symbols, so taking each byte modulo its length is unbiased.
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i, c := range buf {
+		if i == 5 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(recoveryAlphabet[int(c)%len(recoveryAlphabet)])
+	}
+	return b.String(), nil
+}
+
+// Recovery codes are random and high entropy, so a plain SHA-256 is enough
+// and keeps the lookup a single indexed query.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+	"task-manager-server/internal/totp"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestVerifyTOTPCodeRejectsReusedStep(t *testing.T) {
+	s, mock, _ := newTestAuthService(t)
+	db := repository.Bind(context.Background(), s.db)
+
+	now := time.Now()
+	code, err := totp.Code(testTOTPSecret, totp.Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectExec(q("UPDATE users SET totp_last_step = ?")).
+		WithArgs(totp.Step(now), 7, totp.Step(now)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := verifyTOTPCode(db, 7, testTOTPSecret, code); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+
+	// totp_last_step already holds the step, so the update matches no row.
+	mock.ExpectExec(q("UPDATE users SET totp_last_step = ?")).
+		WithArgs(totp.Step(now), 7, totp.Step(now)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := verifyTOTPCode(db, 7, testTOTPSecret, code); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("reuse: err = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestVerifyTOTPCodeRejectsWrongCode(t *testing.T) {
+	s, _, _ := newTestAuthService(t)
+	db := repository.Bind(context.Background(), s.db)
+
+	code, err := totp.Code(testTOTPSecret, totp.Step(time.Now())-5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyTOTPCode(db, 7, testTOTPSecret, code); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("err = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestGenerateRecoveryCode(t *testing.T) {
+	format := regexp.MustCompile(`^[` + recoveryAlphabet + `]{5}-[` + recoveryAlphabet + `]{5}$`)
+	seen := map[string]bool{}
+	for range 100 {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !format.MatchString(code) {
+			t.Fatalf("code %q does not look like xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Fatalf("code %q generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	want := hashRecoveryCode("k7m2p-x9qrt")
+	for _, code := range []string{"k7m2px9qrt", "K7M2P-X9QRT", "k7m2p x9qrt"} {
+		if got := hashRecoveryCode(code); got != want {
+			t.Errorf("hash of %q differs from the hash of k7m2p-x9qrt", code)
+		}
+	}
+	if hashRecoveryCode("k7m2p-x9qrs") == want {
+		t.Error("different codes have the same hash")
+	}
+}
+
+func TestReplaceRecoveryCodes(t *testing.T) {
+	s, mock, _ := newTestAuthService(t)
+	db := repository.Bind(context.Background(), s.db)
+
+	mock.ExpectExec(q("DELETE FROM recovery_codes WHERE user_id = ?")).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	for range recoveryCodeCount {
+		mock.ExpectExec(q("INSERT INTO recovery_codes")).
+			WithArgs(7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	codes, err := replaceRecoveryCodes(db, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes.RecoveryCodes), recoveryCodeCount)
+	}
+}
+
+func TestUseRecoveryCodeOnce(t *testing.T) {
+	s, mock, _ := newTestAuthService(t)
+	db := repository.Bind(context.Background(), s.db)
+
+	for _, affected := range []int64{1, 0} {
+		mock.ExpectExec(q("UPDATE recovery_codes SET used_at = ?")).
+			WithArgs(sqlmock.AnyArg(), 7, hashRecoveryCode("k7m2p-x9qrt")).
+			WillReturnResult(sqlmock.NewResult(0, affected))
+	}
+
+	if err := useRecoveryCode(db, 7, "K7M2P-X9QRT"); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := useRecoveryCode(db, 7, "k7m2p-x9qrt"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("second use: err = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+// expectMFAState expects LoginMFA to load user 7 with 2FA enabled.
+func expectMFAState(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(q("FROM users")).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(userRow).AddRow(7, "Ada", "ada@example.com", time.Now(), false, time.Now()))
+	mock.ExpectQuery(q("SELECT password, totp_secret, totp_enabled FROM users")).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"password", "totp_secret", "totp_enabled"}).AddRow("hash", testTOTPSecret, true))
+}
+
+func expectRecoveryCode(mock sqlmock.Sqlmock, affected int64) {
+	mock.ExpectExec(q("UPDATE recovery_codes SET used_at = ?")).
+		WithArgs(sqlmock.AnyArg(), 7, hashRecoveryCode("k7m2p-x9qrt")).
+		WillReturnResult(sqlmock.NewResult(0, affected))
+}
+
+func expectChallenge(mock sqlmock.Sqlmock, affected int64) {
+	mock.ExpectExec(q("UPDATE auth_tokens\n       SET used_at = ?")).
+		WithArgs(sqlmock.AnyArg(), "challenge", 7, purposeMFAChallenge, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, affected))
+}
+
+func newMFALogin(t *testing.T, s *AuthService) *models.MFALoginRequest {
+	now := time.Now()
+	token, err := s.signActionToken(7, purposeMFAChallenge, "challenge", now, now.Add(mfaChallengeTTL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.MFALoginRequest{MFAToken: token, RecoveryCode: "k7m2p-x9qrt"}
+}
+
+func TestLoginMFAWithRecoveryCode(t *testing.T) {
+	s, mock, _ := newTestAuthService(t)
+	req := newMFALogin(t, s)
+
+	expectMFAState(mock)
+	mock.ExpectBegin()
+	expectRecoveryCode(mock, 1)
+	expectChallenge(mock, 1)
+	mock.ExpectExec(q("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	auth, err := s.LoginMFA(context.Background(), req, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth.Token == "" || auth.User.ID != 7 {
+		t.Errorf("auth = %+v, want a session for user 7", auth)
+	}
+}
+
+// A recovery code sent with a challenge that was already used must stay
+// usable: it is burned in the transaction that fails to consume the
+// challenge, so it is rolled back with it.
+func TestLoginMFAKeepsRecoveryCodeForUsedChallenge(t *testing.T) {
+	s, mock, _ := newTestAuthService(t)
+	req := newMFALogin(t, s)
+
+	expectMFAState(mock)
+	mock.ExpectBegin()
+	expectRecoveryCode(mock, 1)
+	expectChallenge(mock, 0)
+	mock.ExpectRollback()
+
+	if _, err := s.LoginMFA(context.Background(), req, "10.0.0.1"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLoginMFAWrongRecoveryCodeCountsAsFailure(t *testing.T) {
+	s, mock, _ := newTestAuthService(t)
+	req := newMFALogin(t, s)
+
+	expectMFAState(mock)
+	mock.ExpectBegin()
+	expectRecoveryCode(mock, 0)
+	mock.ExpectRollback()
+	mock.ExpectExec(q("INSERT INTO login_failures")).
+		WithArgs("ada@example.com", "10.0.0.1", "invalid_mfa_code", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if _, err := s.LoginMFA(context.Background(), req, "10.0.0.1"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("err = %v, want ErrInvalidMFACode", err)
+	}
+}
@@ -1,91 +1,135 @@
 package services
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"time"
 
+	"task-manager-server/internal/audit"
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/lockout"
+	"task-manager-server/internal/logging"
+	"task-manager-server/internal/mailer"
+	"task-manager-server/internal/metrics"
 	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService struct {
-	db        *sql.DB
-	jwtSecret []byte
+	db             *sql.DB
+	uow            *repository.UnitOfWork
+	mailer         mailer.Mailer
+	accountLimiter *lockout.Limiter
+	ipLimiter      *lockout.Limiter
+	unlockLimiter  *lockout.Limiter
+	cfg            config.AuthConfig
+	jwtSecret      []byte
 }
 
-func NewAuthService(db *sql.DB) *AuthService {
+func NewAuthService(db *sql.DB, uow *repository.UnitOfWork, mailer mailer.Mailer, attempts lockout.Store, cfg config.AuthConfig) *AuthService {
 	return &AuthService{
-		db:        db,
-		jwtSecret: []byte("your-secret-key-change-in-production"),
+		db:             db,
+		uow:            uow,
+		mailer:         mailer,
+		accountLimiter: lockout.NewLimiter(attempts, "account:", cfg.AccountLockout),
+		ipLimiter:      lockout.NewLimiter(attempts, "ip:", cfg.IPLockout),
+		unlockLimiter:  lockout.NewLimiter(attempts, "unlock:", cfg.UnlockRequests),
+		cfg:            cfg,
+		jwtSecret:      []byte("your-secret-key-change-in-production"),
 	}
 }
 
-func (s *AuthService) Register(req *models.RegisterRequest) (*models.User, error) {
-	// Check if email already exists
-	var exists int
-	if err := s.db.QueryRow(
-		"SELECT 1 FROM users WHERE email = ? LIMIT 1",
-		req.Email,
-	).Scan(&exists); err != nil && err != sql.ErrNoRows {
-		return nil, err
-	}
-	if exists == 1 {
-		return nil, fmt.Errorf("email already registered")
-	}
+// Register creates an account. The user and its audit entry are stored in
+// one transaction; the verification email is sent once it committed. The
+// email is stored trimmed and lowercased, as every lookup normalizes it.
+func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Register")
+	defer span.End()
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
 
-	now := time.Now()
-
-	res, err := s.db.Exec(
-		"INSERT INTO users (name, email, password, created_at) VALUES (?, ?, ?, ?)",
-		req.Name,
-		req.Email,
-		string(hashedPassword),
-		now,
-	)
-	if err != nil {
-		return nil, err
+	user := models.User{
+		Name:     req.Name,
+		Email:    normalizeEmail(req.Email),
+		Password: string(hashedPassword),
 	}
-
-	id, err := res.LastInsertId()
+	err = s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		users := tx.Users()
+
+		// Check if email already exists
+		existing, err := users.GetByEmail(user.Email)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrEmailTaken
+		}
+
+		user.CreatedAt = time.Now()
+		if err := users.Create(&user); err != nil {
+			return err
+		}
+
+		changes, err := audit.Diff(nil, &user, "createdAt")
+		if err != nil {
+			return err
+		}
+		return auditUser(ctx, tx, &user.ID, AuditUserRegistered, user.ID, changes)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	user := models.User{
-		ID:        int(id),
-		Name:      req.Name,
-		Email:     req.Email,
-		Password:  string(hashedPassword),
-		CreatedAt: now,
+	// A failed email must not fail the registration; the user can ask for a
+	// new link later.
+	if err := s.sendVerificationEmail(ctx, &user); err != nil {
+		logging.FromContext(ctx).Error("Register: failed to send verification email", "userId", user.ID, "err", err)
 	}
 
 	return &user, nil
 }
 
-func (s *AuthService) Login(req *models.LoginRequest) (*models.AuthResponse, error) {
+// Login checks the credentials of a user. ip is the client address and is
+// used, together with the email, to throttle failed attempts.
+func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, ip string) (*models.AuthResponse, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Login")
+	defer span.End()
+
+	email := normalizeEmail(req.Email)
+	if err := s.checkLockout(ctx, email, ip); err != nil {
+		return nil, err
+	}
+
 	var user models.User
+	var verifiedAt sql.NullTime
+	var totpEnabled bool
 
 	// Fetch user by email
-	err := s.db.QueryRow(
-		"SELECT id, name, email, password, created_at FROM users WHERE email = ? LIMIT 1",
-		req.Email,
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT id, name, email, password, email_verified_at, is_admin, totp_enabled, created_at FROM users WHERE email = ? LIMIT 1",
+		email,
 	).Scan(
 		&user.ID,
 		&user.Name,
 		&user.Email,
 		&user.Password,
+		&verifiedAt,
+		&user.IsAdmin,
+		&totpEnabled,
 		&user.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("invalid email")
+		if err := s.recordLoginFailure(ctx, email, ip, "unknown_email"); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 	if err != nil {
 		return nil, err
@@ -93,17 +137,61 @@ func (s *AuthService) Login(req *models.LoginRequest) (*models.AuthResponse, err
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		// Keep the same message so frontend still shows "invalid email"
-		return nil, fmt.Errorf("invalid email")
+		if err := s.recordLoginFailure(ctx, email, ip, "invalid_password"); err != nil {
+			return nil, err
+		}
+		// Same error as an unknown email so accounts cannot be enumerated
+		return nil, ErrInvalidCredentials
 	}
 
-	// Generate JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	user.EmailVerified = verifiedAt.Valid
+	if s.cfg.RequireEmailVerification && !user.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	// With 2FA on, the password only earns a short-lived challenge that
+	// LoginMFA exchanges for a session.
+	if totpEnabled {
+		challenge, err := s.issueActionToken(ctx, user.ID, purposeMFAChallenge, mfaChallengeTTL)
+		if err != nil {
+			return nil, err
+		}
+		return &models.AuthResponse{
+			MFARequired: true,
+			MFAToken:    challenge,
+		}, nil
+	}
+
+	if err := s.accountLimiter.Reset(ctx, email); err != nil {
+		return nil, err
+	}
+
+	session, err := s.newSession(ctx, repository.Bind(ctx, s.db), &user)
+	if err != nil {
+		return nil, err
+	}
+	metrics.Logins.Inc()
+	return session, nil
+}
+
+// newSession issues the session JWT accepted by AuthMiddleware and audits
+// the login on q.
+func (s *AuthService) newSession(ctx context.Context, q dbtx, user *models.User) (*models.AuthResponse, error) {
+	if err := auditUser(ctx, q, &user.ID, AuditUserLoggedIn, user.ID, nil); err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{
 		"user_id": user.ID,
 		"email":   user.Email,
 		"name":    user.Name,
 		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-	})
+	}
+	if user.IsAdmin {
+		claims["scope"] = "admin"
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenString, err := token.SignedString(s.jwtSecret)
 	if err != nil {
@@ -115,3 +203,26 @@ func (s *AuthService) Login(req *models.LoginRequest) (*models.AuthResponse, err
 		Token: tokenString,
 	}, nil
 }
+
+func (s *AuthService) findUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return repository.NewUserRepository(repository.Bind(ctx, s.db)).GetByEmail(normalizeEmail(email))
+}
+
+// GetUser returns a user's profile.
+func (s *AuthService) GetUser(ctx context.Context, id int) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.GetUser")
+	defer span.End()
+
+	user, err := s.findUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *AuthService) findUserByID(ctx context.Context, id int) (*models.User, error) {
+	return repository.NewUserRepository(repository.Bind(ctx, s.db)).GetByID(id)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposeResetPassword = "reset_password"
+	purposeMFAChallenge  = "mfa_challenge"
+
+	verifyEmailTTL   = 24 * time.Hour
+	resetPasswordTTL = time.Hour
+	mfaChallengeTTL  = 5 * time.Minute
+)
+
+// actionClaims are carried by tokens that authorize a single action, such as
+// the links sent in emails or the MFA login challenge. They have no user_id
+// claim, so AuthMiddleware never accepts them as session tokens.
+type actionClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// issueActionToken signs a token for the given purpose and records its ID so
+// that it can be consumed exactly once.
+func (s *AuthService) issueActionToken(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	jti := hex.EncodeToString(buf)
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	if _, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO auth_tokens (jti, user_id, purpose, expires_at, created_at)
+       VALUES (?, ?, ?, ?, ?)`,
+		jti,
+		userID,
+		purpose,
+		expiresAt,
+		now,
+	); err != nil {
+		return "", err
+	}
+
+	return s.signActionToken(userID, purpose, jti, now, expiresAt)
+}
+
+func (s *AuthService) signActionToken(userID int, purpose, jti string, issuedAt, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, actionClaims{
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+
+	return token.SignedString(s.jwtSecret)
+}
+
+// parseActionToken verifies the signature, expiry and purpose of a token and
+// returns its claims along with the ID of the user it was issued to.
+func (s *AuthService) parseActionToken(tokenString, purpose string) (*actionClaims, int, error) {
+	var claims actionClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return s.jwtSecret, nil
+	})
+	if err != nil || claims.Purpose != purpose {
+		return nil, 0, ErrInvalidToken
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, 0, ErrInvalidToken
+	}
+
+	return &claims, userID, nil
+}
+
+// consumeActionToken parses a token issued by issueActionToken and marks it
+// as used, so a second attempt with the same token fails. Run on a
+// transaction, the token is only used up if the transaction commits.
+func (s *AuthService) consumeActionToken(q dbtx, tokenString, purpose string) (int, error) {
+	claims, userID, err := s.parseActionToken(tokenString, purpose)
+	if err != nil {
+		return 0, err
+	}
+	if claims.ID == "" {
+		return 0, ErrInvalidToken
+	}
+
+	res, err := q.Exec(
+		`UPDATE auth_tokens
+       SET used_at = ?
+       WHERE jti = ? AND user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?`,
+		time.Now(),
+		claims.ID,
+		userID,
+		purpose,
+		time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, ErrInvalidToken
+	}
+
+	return userID, nil
+}
+
+// revokeActionTokens invalidates every outstanding token of a purpose for a
+// user, e.g. older reset links once the password has been changed.
+func (s *AuthService) revokeActionTokens(q dbtx, userID int, purpose string) error {
+	_, err := q.Exec(
+		`UPDATE auth_tokens SET used_at = ? WHERE user_id = ? AND purpose = ? AND used_at IS NULL`,
+		time.Now(),
+		userID,
+		purpose,
+	)
+	return err
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+// Errors returned by the services. Handlers map them to HTTP responses, so
+// their messages are safe to show to clients; any other error is treated as
+// internal.
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrUserNotFound = errors.New("user not found")
+
+	ErrVersionConflict  = errors.New("task was changed by another client")
+	ErrInvalidSyncToken = errors.New("invalid sync token")
+	ErrParentNotFound   = errors.New("parent task not found")
+	ErrTaskCycle        = errors.New("a task cannot be moved under itself or its subtasks")
+	ErrTaskTooDeep      = errors.New("subtasks are nested too deeply")
+	ErrRevisionNotFound = errors.New("task revision not found")
+	ErrNothingToUndo    = errors.New("nothing to undo")
+	ErrNothingToRedo    = errors.New("nothing to redo")
+	ErrLabelNotFound    = errors.New("label not found")
+	ErrLabelTaken       = errors.New("a label with this name already exists")
+
+	ErrEmailTaken          = errors.New("email already registered")
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrInvalidPassword     = errors.New("invalid password")
+	ErrEmailNotVerified    = errors.New("email not verified")
+	ErrInvalidToken        = errors.New("invalid or expired token")
+	ErrUnlockTargetMissing = errors.New("email or ip is required")
+
+	ErrMFACodeRequired    = errors.New("verification code required")
+	ErrInvalidMFACode     = errors.New("invalid verification code")
+	ErrMFAAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled      = errors.New("two-factor authentication is not enabled")
+	ErrMFASetupNotStarted = errors.New("two-factor setup has not been started")
+
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+	ErrProjectNotFound  = errors.New("project not found")
+	ErrNotProjectOwner  = errors.New("only the project owner can change its members")
+	ErrOwnerCannotLeave = errors.New("the project owner cannot leave the project")
+)
+
+// LockedError is returned while an account or client IP is locked out after
+// too many failed logins.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return "too many failed login attempts, try again later"
+}
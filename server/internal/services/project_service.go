@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+)
+
+const projectColumns = `p.id, p.name, p.owner_id, p.created_at`
+
+// ProjectService manages projects and their members. Tasks are moved in and
+// out of projects by TaskService.SetTaskProject, since that is a change of
+// the task.
+type ProjectService struct {
+	db  *sql.DB
+	uow *repository.UnitOfWork
+}
+
+func NewProjectService(db *sql.DB, uow *repository.UnitOfWork) *ProjectService {
+	return &ProjectService{
+		db:  db,
+		uow: uow,
+	}
+}
+
+// ListProjects returns the projects the user is a member of, by name.
+func (s *ProjectService) ListProjects(ctx context.Context, userID int) ([]models.Project, error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.ListProjects")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT `+projectColumns+`
+       FROM projects p
+       JOIN project_members m ON m.project_id = p.id
+       WHERE m.user_id = ?
+       ORDER BY p.name, p.id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		var p models.Project
+		if err := rows.Scan(&p.ID, &p.Name, &p.OwnerID, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+// GetProject returns a project the user is a member of, with its members.
+func (s *ProjectService) GetProject(ctx context.Context, id, userID int) (*models.Project, error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.GetProject")
+	defer span.End()
+
+	var p models.Project
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT `+projectColumns+`
+       FROM projects p
+       JOIN project_members m ON m.project_id = p.id
+       WHERE p.id = ? AND m.user_id = ?`,
+		id,
+		userID,
+	).Scan(&p.ID, &p.Name, &p.OwnerID, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT m.user_id, u.name, m.added_at
+       FROM project_members m
+       JOIN users u ON u.id = m.user_id
+       WHERE m.project_id = ?
+       ORDER BY m.added_at, m.user_id`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p.Members = []models.ProjectMember{}
+	for rows.Next() {
+		var m models.ProjectMember
+		if err := rows.Scan(&m.UserID, &m.Name, &m.AddedAt); err != nil {
+			return nil, err
+		}
+		p.Members = append(p.Members, m)
+	}
+	return &p, rows.Err()
+}
+
+// CreateProject creates a project owned by the user, who becomes its first
+// member.
+func (s *ProjectService) CreateProject(ctx context.Context, req *models.CreateProjectRequest, userID int) (project *models.Project, err error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.CreateProject")
+	defer span.End()
+
+	err = s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		project = &models.Project{Name: req.Name, OwnerID: userID, CreatedAt: time.Now()}
+		res, err := tx.Exec(
+			`INSERT INTO projects (name, owner_id, created_at) VALUES (?, ?, ?)`,
+			project.Name,
+			project.OwnerID,
+			project.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		project.ID = int(id)
+
+		if _, err := tx.Exec(
+			`INSERT INTO project_members (project_id, user_id, added_at) VALUES (?, ?, ?)`,
+			project.ID,
+			userID,
+			project.CreatedAt,
+		); err != nil {
+			return err
+		}
+		return auditProject(ctx, tx, userID, AuditProjectCreated, project.ID, map[string]models.AuditChange{
+			"name": {To: project.Name},
+		})
+	})
+	return project, err
+}
+
+// AddMember shares a project with the user registered under email. Only
+// the owner can add members; adding a member twice changes nothing.
+func (s *ProjectService) AddMember(ctx context.Context, id, userID int, email string) (member *models.ProjectMember, err error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.AddMember")
+	defer span.End()
+
+	err = s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		if err := checkProjectOwner(tx, id, userID); err != nil {
+			return err
+		}
+
+		user, err := tx.Users().GetByEmail(email)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return ErrUserNotFound
+		}
+
+		member = &models.ProjectMember{UserID: user.ID, Name: user.Name, AddedAt: time.Now()}
+		res, err := tx.Exec(
+			`INSERT IGNORE INTO project_members (project_id, user_id, added_at) VALUES (?, ?, ?)`,
+			id,
+			member.UserID,
+			member.AddedAt,
+		)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// Already a member.
+			return nil
+		}
+		return auditProject(ctx, tx, userID, AuditProjectMemberAdded, id, map[string]models.AuditChange{
+			"userId": {To: member.UserID},
+		})
+	})
+	return member, err
+}
+
+// RemoveMember takes memberID out of a project. The owner can remove any
+// other member and members can remove themselves; the owner cannot leave.
+// The tasks the member put in the project stay in it.
+func (s *ProjectService) RemoveMember(ctx context.Context, id, userID, memberID int) error {
+	ctx, span := tracer.Start(ctx, "ProjectService.RemoveMember")
+	defer span.End()
+
+	return s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		ownerID, err := projectOwner(tx, id, userID)
+		if err != nil {
+			return err
+		}
+		if memberID == ownerID {
+			return ErrOwnerCannotLeave
+		}
+		if userID != ownerID && userID != memberID {
+			return ErrNotProjectOwner
+		}
+
+		res, err := tx.Exec(`DELETE FROM project_members WHERE project_id = ? AND user_id = ?`, id, memberID)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrUserNotFound
+		}
+		return auditProject(ctx, tx, userID, AuditProjectMemberRemoved, id, map[string]models.AuditChange{
+			"userId": {From: memberID},
+		})
+	})
+}
+
+// ProjectTasks returns the tasks of a project the user is a member of,
+// newest first, whoever owns them.
+func (s *ProjectService) ProjectTasks(ctx context.Context, id, userID int) ([]models.Task, error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.ProjectTasks")
+	defer span.End()
+
+	q := repository.Bind(ctx, s.db)
+	if _, err := projectOwner(q, id, userID); err != nil {
+		return nil, err
+	}
+	return repository.NewTaskRepository(q).GetInProject(id)
+}
+
+// CheckMember returns ErrProjectNotFound unless the user is a member of
+// project id.
+func (s *ProjectService) CheckMember(ctx context.Context, id, userID int) error {
+	ctx, span := tracer.Start(ctx, "ProjectService.CheckMember")
+	defer span.End()
+
+	_, err := projectOwner(repository.Bind(ctx, s.db), id, userID)
+	return err
+}
+
+// TaskIDs lists the tasks in a project without checking who asks, for
+// rechecking access to them after the members changed.
+func (s *ProjectService) TaskIDs(ctx context.Context, id int) ([]int, error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.TaskIDs")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM tasks WHERE project_id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var taskID int
+		if err := rows.Scan(&taskID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, taskID)
+	}
+	return ids, rows.Err()
+}
+
+// TasksInProjects returns the tasks of the given projects that the user is
+// a member of, newest first, keyed by project.
+func (s *ProjectService) TasksInProjects(ctx context.Context, userID int, ids []int) (map[int][]models.Task, error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.TasksInProjects")
+	defer span.End()
+
+	byProject := map[int][]models.Task{}
+	for chunk := range slices.Chunk(ids, embedChunk) {
+		rows, err := s.db.QueryContext(
+			ctx,
+			`SELECT `+repository.TaskColumns+`
+       FROM tasks
+       WHERE project_id IN (?`+strings.Repeat(", ?", len(chunk)-1)+`)
+         AND project_id IN (SELECT project_id FROM project_members WHERE user_id = ?)
+       ORDER BY created_at DESC`,
+			append(intArgs(chunk), userID)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			t, err := repository.ScanTask(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			byProject[*t.ProjectID] = append(byProject[*t.ProjectID], *t)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return byProject, nil
+}
+
+// ProjectsByID returns the projects with the given IDs that the user is a
+// member of, keyed by ID.
+func (s *ProjectService) ProjectsByID(ctx context.Context, userID int, ids []int) (map[int]models.Project, error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.ProjectsByID")
+	defer span.End()
+
+	return projectsByID(repository.Bind(ctx, s.db), userID, ids)
+}
+
+func projectsByID(q dbtx, userID int, ids []int) (map[int]models.Project, error) {
+	byID := map[int]models.Project{}
+	for chunk := range slices.Chunk(ids, embedChunk) {
+		rows, err := q.Query(
+			`SELECT `+projectColumns+`
+       FROM projects p
+       JOIN project_members m ON m.project_id = p.id
+       WHERE m.user_id = ? AND p.id IN (?`+strings.Repeat(", ?", len(chunk)-1)+`)`,
+			append([]any{userID}, intArgs(chunk)...)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var p models.Project
+			if err := rows.Scan(&p.ID, &p.Name, &p.OwnerID, &p.CreatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			byID[p.ID] = p
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return byID, nil
+}
+
+// projectOwner returns the owner of project id, or ErrProjectNotFound when
+// the user is not one of its members.
+func projectOwner(q dbtx, id, userID int) (int, error) {
+	var ownerID int
+	err := q.QueryRow(
+		`SELECT p.owner_id
+       FROM projects p
+       JOIN project_members m ON m.project_id = p.id
+       WHERE p.id = ? AND m.user_id = ?`,
+		id,
+		userID,
+	).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return 0, ErrProjectNotFound
+	}
+	return ownerID, err
+}
+
+// checkProjectOwner returns ErrNotProjectOwner for members other than the
+// owner.
+func checkProjectOwner(q dbtx, id, userID int) error {
+	ownerID, err := projectOwner(q, id, userID)
+	if err != nil {
+		return err
+	}
+	if ownerID != userID {
+		return ErrNotProjectOwner
+	}
+	return nil
+}
+
+func auditProject(ctx context.Context, q dbtx, actorID int, action string, id int, changes map[string]models.AuditChange) error {
+	return recordAudit(ctx, q, &models.AuditEntry{
+		ActorID:    &actorID,
+		Action:     action,
+		EntityType: EntityProject,
+		EntityID:   strconv.Itoa(id),
+		Changes:    changes,
+	})
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"task-manager-server/internal/models"
+)
+
+// Batch operation kinds.
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpMove   = "move"
+)
+
+// TaskOperation is one operation of a batch. Create is set for creates,
+// Update for updates; ParentID is the new parent of a move.
+type TaskOperation struct {
+	Op        string
+	ID        int
+	Create    *models.CreateTaskRequest
+	Update    *models.UpdateTaskRequest
+	ParentID  *int
+	IfVersion *int64
+}
+
+// TaskOperationResult is the outcome of the operation at the same index.
+// Task is nil for deletes and failed operations.
+type TaskOperationResult struct {
+	Task *models.Task
+	Err  error
+}
+
+// operationErrors are failures of a single operation. Any other error
+// aborts the whole batch.
+var operationErrors = []error{
+	ErrTaskNotFound,
+	ErrVersionConflict,
+	ErrParentNotFound,
+	ErrTaskCycle,
+	ErrTaskTooDeep,
+}
+
+// Batch runs ops in order in one transaction. When atomic, the first
+// failing operation rolls back the whole batch and the operations after it
+// are not run (their results are zero). Otherwise each failing operation is
+// rolled back on its own and the others are committed; when every one of
+// them fails nothing is committed. committed reports whether anything was
+// stored.
+func (s *TaskService) Batch(ctx context.Context, userID int, ops []TaskOperation, atomic bool) (results []TaskOperationResult, committed bool, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Batch")
+	defer span.End()
+
+	results = make([]TaskOperationResult, len(ops))
+	if len(ops) == 0 {
+		return results, false, nil
+	}
+	failed := false
+
+	err = s.inTx(ctx, userID, commandBatch, func(tx *taskTx) error {
+		// A retried transaction starts over.
+		clear(results)
+		applied := 0
+		for i, op := range ops {
+			if !atomic {
+				if _, err := tx.tx.Exec(`SAVEPOINT batch_op`); err != nil {
+					return err
+				}
+			}
+			published, journaled := len(tx.events), len(tx.changes)
+
+			task, err := tx.run(op)
+			if err != nil && !isOperationError(err) {
+				return err
+			}
+			results[i] = TaskOperationResult{Task: task, Err: err}
+			if err == nil {
+				applied++
+				continue
+			}
+
+			if atomic {
+				failed = true
+				return errBatchFailed
+			}
+			if _, err := tx.tx.Exec(`ROLLBACK TO SAVEPOINT batch_op`); err != nil {
+				return err
+			}
+			tx.events = tx.events[:published]
+			tx.changes = tx.changes[:journaled]
+		}
+		if applied == 0 {
+			failed = true
+			return errBatchFailed
+		}
+		return nil
+	})
+	if failed {
+		return results, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return results, true, nil
+}
+
+// errBatchFailed rolls back a batch with nothing to commit: an atomic one
+// after a failed operation, or a best-effort one in which every operation
+// failed.
+var errBatchFailed = errors.New("batch operation failed")
+
+func (t *taskTx) run(op TaskOperation) (*models.Task, error) {
+	switch op.Op {
+	case OpCreate:
+		return t.create(op.Create)
+	case OpUpdate:
+		return t.update(op.ID, op.Update, op.IfVersion)
+	case OpMove:
+		return t.move(op.ID, op.ParentID, op.IfVersion)
+	case OpDelete:
+		return nil, t.delete(op.ID, op.IfVersion)
+	}
+	return nil, errors.New("unknown batch operation " + op.Op)
+}
+
+func isOperationError(err error) bool {
+	for _, e := range operationErrors {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
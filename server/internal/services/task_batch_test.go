@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"task-manager-server/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectMissingTask(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(q("FOR UPDATE")).WillReturnRows(sqlmock.NewRows(taskRow))
+}
+
+func deleteOp(id int) TaskOperation {
+	return TaskOperation{Op: OpDelete, ID: id}
+}
+
+func retitleOp(id int, title string) TaskOperation {
+	return TaskOperation{Op: OpUpdate, ID: id, Update: &models.UpdateTaskRequest{Title: &title}}
+}
+
+func TestBatchBestEffortCommitsTheOperationsThatSucceed(t *testing.T) {
+	s, mock := newTestTaskService(t)
+	task := models.Task{ID: 3, Title: "a", UserID: 1, Version: 50, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(q("SAVEPOINT batch_op")).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectMissingTask(mock)
+	mock.ExpectExec(q("ROLLBACK TO SAVEPOINT batch_op")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(q("SAVEPOINT batch_op")).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTask(mock, task, true)
+	expectSave(mock, 51, "b")
+	mock.ExpectCommit()
+
+	results, committed, err := s.Batch(context.Background(), 1, []TaskOperation{deleteOp(9), retitleOp(3, "b")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !committed {
+		t.Error("committed = false, want true")
+	}
+	if !errors.Is(results[0].Err, ErrTaskNotFound) {
+		t.Errorf("operation 0: err = %v, want ErrTaskNotFound", results[0].Err)
+	}
+	if results[1].Err != nil || results[1].Task == nil || results[1].Task.Title != "b" {
+		t.Errorf("operation 1 = %+v, want the retitled task", results[1])
+	}
+}
+
+// A best-effort batch in which every operation fails has nothing to commit.
+func TestBatchBestEffortAllFailedIsNotCommitted(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	mock.ExpectBegin()
+	for range 2 {
+		mock.ExpectExec(q("SAVEPOINT batch_op")).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectMissingTask(mock)
+		mock.ExpectExec(q("ROLLBACK TO SAVEPOINT batch_op")).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectRollback()
+
+	results, committed, err := s.Batch(context.Background(), 1, []TaskOperation{deleteOp(8), deleteOp(9)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if committed {
+		t.Error("committed = true, want false")
+	}
+	for i, result := range results {
+		if !errors.Is(result.Err, ErrTaskNotFound) {
+			t.Errorf("operation %d: err = %v, want ErrTaskNotFound", i, result.Err)
+		}
+	}
+}
+
+func TestBatchWithoutOperationsRunsNoTransaction(t *testing.T) {
+	s, _ := newTestTaskService(t)
+
+	results, committed, err := s.Batch(context.Background(), 1, nil, false)
+	if err != nil || committed || len(results) != 0 {
+		t.Fatalf("Batch() = %v, %v, %v; want nothing run", results, committed, err)
+	}
+}
+
+func TestBatchAtomicStopsAtTheFirstFailure(t *testing.T) {
+	s, mock := newTestTaskService(t)
+	task := models.Task{ID: 3, Title: "a", UserID: 1, Version: 50, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	expectTask(mock, task, true)
+	expectSave(mock, 51, "b")
+	expectMissingTask(mock)
+	mock.ExpectRollback()
+
+	results, committed, err := s.Batch(context.Background(), 1, []TaskOperation{retitleOp(3, "b"), deleteOp(9), deleteOp(10)}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if committed {
+		t.Error("committed = true, want false")
+	}
+	if !errors.Is(results[1].Err, ErrTaskNotFound) {
+		t.Errorf("operation 1: err = %v, want ErrTaskNotFound", results[1].Err)
+	}
+	if results[2] != (TaskOperationResult{}) {
+		t.Errorf("operation 2 = %+v, want it not run", results[2])
+	}
+}
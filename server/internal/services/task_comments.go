@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+)
+
+// ListComments returns the comments on a task the user can see, oldest
+// first.
+func (s *TaskService) ListComments(ctx context.Context, taskID, userID int) ([]models.Comment, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.ListComments")
+	defer span.End()
+
+	if _, err := s.GetSharedTask(ctx, taskID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, task_id, user_id, body, created_at FROM task_comments WHERE task_id = ? ORDER BY id`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var c models.Comment
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.UserID, &c.Body, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// AddComment comments on a task the user owns or shares in a project.
+func (s *TaskService) AddComment(ctx context.Context, taskID, userID int, req *models.CreateCommentRequest) (comment *models.Comment, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.AddComment")
+	defer span.End()
+
+	err = s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		task, err := tx.Tasks().GetShared(taskID, userID)
+		if err != nil {
+			return err
+		}
+		if task == nil {
+			return ErrTaskNotFound
+		}
+
+		comment = &models.Comment{TaskID: taskID, UserID: userID, Body: req.Body, CreatedAt: time.Now()}
+		res, err := tx.Exec(
+			`INSERT INTO task_comments (task_id, user_id, body, created_at) VALUES (?, ?, ?, ?)`,
+			comment.TaskID,
+			comment.UserID,
+			comment.Body,
+			comment.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		if comment.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+
+		actorID := userID
+		return recordAudit(ctx, tx, &models.AuditEntry{
+			ActorID:    &actorID,
+			Action:     AuditTaskCommented,
+			EntityType: EntityTask,
+			EntityID:   strconv.Itoa(taskID),
+			Changes: map[string]models.AuditChange{
+				"commentId": {To: comment.ID},
+			},
+		})
+	})
+	return comment, err
+}
+
+// CommentCounts returns how many comments the given tasks have; tasks
+// without comments are left out. Callers check that the tasks are visible
+// to the user.
+func (s *TaskService) CommentCounts(ctx context.Context, taskIDs []int) (map[int]int, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.CommentCounts")
+	defer span.End()
+
+	counts := map[int]int{}
+	for chunk := range slices.Chunk(taskIDs, embedChunk) {
+		rows, err := s.db.QueryContext(
+			ctx,
+			`SELECT task_id, COUNT(*)
+       FROM task_comments
+       WHERE task_id IN (?`+strings.Repeat(", ?", len(chunk)-1)+`)
+       GROUP BY task_id`,
+			intArgs(chunk)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id, n int
+			if err := rows.Scan(&id, &n); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			counts[id] = n
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+)
+
+// defaultLabelColor is used for labels created without a color.
+const defaultLabelColor = "#808080"
+
+const labelColumns = `l.id, l.name, l.color, l.created_at`
+
+// ListLabels returns the user's labels by name.
+func (s *TaskService) ListLabels(ctx context.Context, userID int) ([]models.Label, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.ListLabels")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT `+labelColumns+` FROM labels l WHERE l.user_id = ? ORDER BY l.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := []models.Label{}
+	for rows.Next() {
+		var l models.Label
+		if err := rows.Scan(&l.ID, &l.Name, &l.Color, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		labels = append(labels, l)
+	}
+	return labels, rows.Err()
+}
+
+// CreateLabel creates a label; names are unique per user.
+func (s *TaskService) CreateLabel(ctx context.Context, req *models.CreateLabelRequest, userID int) (label *models.Label, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.CreateLabel")
+	defer span.End()
+
+	err = s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM labels WHERE user_id = ? AND name = ?`, userID, req.Name).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrLabelTaken
+		}
+
+		label = &models.Label{Name: req.Name, Color: req.Color, CreatedAt: time.Now()}
+		if label.Color == "" {
+			label.Color = defaultLabelColor
+		}
+		res, err := tx.Exec(
+			`INSERT INTO labels (user_id, name, color, created_at) VALUES (?, ?, ?, ?)`,
+			userID,
+			label.Name,
+			label.Color,
+			label.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		label.ID = int(id)
+		return nil
+	})
+	return label, err
+}
+
+// DeleteLabel removes a label from the user's tasks and deletes it.
+func (s *TaskService) DeleteLabel(ctx context.Context, id, userID int) error {
+	ctx, span := tracer.Start(ctx, "TaskService.DeleteLabel")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM labels WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLabelNotFound
+	}
+	return nil
+}
+
+// SetTaskLabels replaces the labels of one of the user's tasks with the
+// user's labels labelIDs and returns them by name. Labels are not part of
+// the versioned task, so the change is audited but not synced, journaled or
+// published.
+func (s *TaskService) SetTaskLabels(ctx context.Context, id, userID int, labelIDs []int) (labels []models.Label, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.SetTaskLabels")
+	defer span.End()
+
+	labelIDs = slices.Compact(slices.Sorted(slices.Values(labelIDs)))
+	err = s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+		if _, err := getTask(tx, id, userID, true); err != nil {
+			return err
+		}
+
+		if len(labelIDs) > 0 {
+			var n int
+			err := tx.QueryRow(
+				`SELECT COUNT(*) FROM labels WHERE user_id = ? AND id IN (?`+strings.Repeat(", ?", len(labelIDs)-1)+`)`,
+				append([]any{userID}, intArgs(labelIDs)...)...,
+			).Scan(&n)
+			if err != nil {
+				return err
+			}
+			if n != len(labelIDs) {
+				return ErrLabelNotFound
+			}
+		}
+
+		before, err := labelsOf(tx, []int{id})
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM task_labels WHERE task_id = ?`, id); err != nil {
+			return err
+		}
+		for _, labelID := range labelIDs {
+			if _, err := tx.Exec(`INSERT INTO task_labels (task_id, label_id) VALUES (?, ?)`, id, labelID); err != nil {
+				return err
+			}
+		}
+		after, err := labelsOf(tx, []int{id})
+		if err != nil {
+			return err
+		}
+		labels = after[id]
+		if labels == nil {
+			labels = []models.Label{}
+		}
+
+		actorID := userID
+		return recordAudit(ctx, tx, &models.AuditEntry{
+			ActorID:    &actorID,
+			Action:     AuditTaskLabeled,
+			EntityType: EntityTask,
+			EntityID:   strconv.Itoa(id),
+			Changes: map[string]models.AuditChange{
+				"labels": {From: labelNames(before[id]), To: labelNames(labels)},
+			},
+		})
+	})
+	return labels, err
+}
+
+// LabelsOf returns the labels of the given tasks by name, keyed by task.
+// Callers check that the tasks are visible to the user.
+func (s *TaskService) LabelsOf(ctx context.Context, taskIDs []int) (map[int][]models.Label, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.LabelsOf")
+	defer span.End()
+
+	return labelsOf(repository.Bind(ctx, s.db), taskIDs)
+}
+
+func labelsOf(q dbtx, taskIDs []int) (map[int][]models.Label, error) {
+	byTask := map[int][]models.Label{}
+	for chunk := range slices.Chunk(taskIDs, embedChunk) {
+		rows, err := q.Query(
+			`SELECT tl.task_id, `+labelColumns+`
+       FROM task_labels tl
+       JOIN labels l ON l.id = tl.label_id
+       WHERE tl.task_id IN (?`+strings.Repeat(", ?", len(chunk)-1)+`)
+       ORDER BY l.name`,
+			intArgs(chunk)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var (
+				taskID int
+				l      models.Label
+			)
+			if err := rows.Scan(&taskID, &l.ID, &l.Name, &l.Color, &l.CreatedAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			byTask[taskID] = append(byTask[taskID], l)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return byTask, nil
+}
+
+func labelNames(labels []models.Label) []string {
+	names := make([]string, len(labels))
+	for i, l := range labels {
+		names[i] = l.Name
+	}
+	return names
+}
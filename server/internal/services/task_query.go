@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+)
+
+// Relations a task query can embed.
+const (
+	IncludeSubtasks     = "subtasks"
+	IncludeParent       = "parent"
+	IncludeSubtaskCount = "subtaskCount"
+	IncludeLabels       = "labels"
+	IncludeCommentCount = "commentCount"
+	IncludeProject      = "project"
+)
+
+// TaskIncludes lists the relations a task query can embed.
+var TaskIncludes = []string{IncludeSubtasks, IncludeParent, IncludeSubtaskCount, IncludeLabels, IncludeCommentCount, IncludeProject}
+
+// taskFields maps the JSON names of the task fields to their columns, in
+// the order of repository.TaskColumns.
+var taskFields = []struct{ name, column string }{
+	{"id", "id"},
+	{"title", "title"},
+	{"description", "description"},
+	{"done", "done"},
+	{"userId", "user_id"},
+	{"parentId", "parent_id"},
+	{"projectId", "project_id"},
+	{"version", "change_seq"},
+	{"createdAt", "created_at"},
+	{"updatedAt", "updated_at"},
+}
+
+// embedChunk bounds the keys of one query loading embedded tasks.
+const embedChunk = 500
+
+// TaskFields lists the JSON names of the fields a task query can select.
+func TaskFields() []string {
+	names := make([]string, len(taskFields))
+	for i, f := range taskFields {
+		names[i] = f.name
+	}
+	return names
+}
+
+// TaskQuery selects what a task read returns. Fields holds JSON field names
+// and selects every field when empty; Include names relations to embed.
+// Only the columns and joins needed for them are queried, so fields that
+// were not asked for are left zero.
+type TaskQuery struct {
+	Fields  []string
+	Include []string
+}
+
+func (q TaskQuery) includes(relation string) bool {
+	return slices.Contains(q.Include, relation)
+}
+
+// selects returns the fields to select: those asked for plus the keys the
+// embedded relations are matched on.
+func (q TaskQuery) selects(keys ...string) []string {
+	if len(q.Fields) == 0 {
+		return TaskFields()
+	}
+	var names []string
+	for _, f := range taskFields {
+		if slices.Contains(q.Fields, f.name) || slices.Contains(keys, f.name) {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// keys lists the fields the embedded relations of the query need.
+func (q TaskQuery) keys() []string {
+	var keys []string
+	if q.includes(IncludeSubtasks) || q.includes(IncludeSubtaskCount) || q.includes(IncludeLabels) || q.includes(IncludeCommentCount) {
+		keys = append(keys, "id")
+	}
+	if q.includes(IncludeParent) {
+		keys = append(keys, "parentId")
+	}
+	if q.includes(IncludeProject) {
+		keys = append(keys, "projectId")
+	}
+	return keys
+}
+
+// QueryTasks returns the user's tasks, newest first, with the fields and
+// relations of q.
+func (s *TaskService) QueryTasks(ctx context.Context, userID int, q TaskQuery) ([]models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.QueryTasks")
+	defer span.End()
+
+	tasks, err := s.selectTasks(ctx, userID, q, q.selects(q.keys()...), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return tasks, s.embed(ctx, userID, tasks, q)
+}
+
+// QueryTask returns one of the user's tasks with the fields and relations
+// of q.
+func (s *TaskService) QueryTask(ctx context.Context, id, userID int, q TaskQuery) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.QueryTask")
+	defer span.End()
+
+	tasks, err := s.selectTasks(ctx, userID, q, q.selects(q.keys()...), "t.id = ?", []any{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, ErrTaskNotFound
+	}
+	return &tasks[0], s.embed(ctx, userID, tasks, q)
+}
+
+// embed loads the relations of q for tasks. Embedded tasks have the same
+// fields as the tasks they are embedded in. A task's project is only
+// embedded while the user is a member of it.
+func (s *TaskService) embed(ctx context.Context, userID int, tasks []models.Task, q TaskQuery) error {
+	if q.includes(IncludeSubtasks) {
+		ids := make([]any, len(tasks))
+		for i, t := range tasks {
+			ids[i] = t.ID
+		}
+		children, err := s.selectTasksIn(ctx, userID, q, q.selects("parentId"), "t.parent_id", ids)
+		if err != nil {
+			return err
+		}
+		byParent := map[int][]models.Task{}
+		for _, child := range children {
+			byParent[*child.ParentID] = append(byParent[*child.ParentID], child)
+		}
+		for i := range tasks {
+			tasks[i].Subtasks = byParent[tasks[i].ID]
+			if tasks[i].Subtasks == nil {
+				tasks[i].Subtasks = []models.Task{}
+			}
+		}
+	}
+
+	if q.includes(IncludeParent) {
+		var ids []any
+		seen := map[int]bool{}
+		for _, t := range tasks {
+			if t.ParentID != nil && !seen[*t.ParentID] {
+				seen[*t.ParentID] = true
+				ids = append(ids, *t.ParentID)
+			}
+		}
+		parents, err := s.selectTasksIn(ctx, userID, q, q.selects("id"), "t.id", ids)
+		if err != nil {
+			return err
+		}
+		byID := map[int]*models.Task{}
+		for i := range parents {
+			byID[parents[i].ID] = &parents[i]
+		}
+		for i := range tasks {
+			if tasks[i].ParentID != nil {
+				tasks[i].Parent = byID[*tasks[i].ParentID]
+			}
+		}
+	}
+
+	ids := make([]int, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	if q.includes(IncludeLabels) {
+		labels, err := s.LabelsOf(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for i := range tasks {
+			tasks[i].Labels = labels[tasks[i].ID]
+			if tasks[i].Labels == nil {
+				tasks[i].Labels = []models.Label{}
+			}
+		}
+	}
+	if q.includes(IncludeCommentCount) {
+		counts, err := s.CommentCounts(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for i := range tasks {
+			n := counts[tasks[i].ID]
+			tasks[i].CommentCount = &n
+		}
+	}
+
+	if q.includes(IncludeProject) {
+		var projectIDs []int
+		for _, t := range tasks {
+			if t.ProjectID != nil && !slices.Contains(projectIDs, *t.ProjectID) {
+				projectIDs = append(projectIDs, *t.ProjectID)
+			}
+		}
+		projects, err := projectsByID(repository.Bind(ctx, s.db), userID, projectIDs)
+		if err != nil {
+			return err
+		}
+		for i := range tasks {
+			if tasks[i].ProjectID == nil {
+				continue
+			}
+			if p, ok := projects[*tasks[i].ProjectID]; ok {
+				tasks[i].Project = &p
+			}
+		}
+	}
+	return nil
+}
+
+// selectTasksIn selects the tasks whose column is one of values, in chunks.
+func (s *TaskService) selectTasksIn(ctx context.Context, userID int, q TaskQuery, fields []string, column string, values []any) ([]models.Task, error) {
+	var tasks []models.Task
+	for chunk := range slices.Chunk(values, embedChunk) {
+		where := column + " IN (?" + strings.Repeat(", ?", len(chunk)-1) + ")"
+		found, err := s.selectTasks(ctx, userID, q, fields, where, chunk)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, found...)
+	}
+	return tasks, nil
+}
+
+// selectTasks runs a task select for fields, joining the subtask counts
+// only when q includes them.
+func (s *TaskService) selectTasks(ctx context.Context, userID int, q TaskQuery, fields []string, where string, args []any) ([]models.Task, error) {
+	cols := make([]string, len(fields))
+	for i, name := range fields {
+		for _, f := range taskFields {
+			if f.name == name {
+				cols[i] = "t." + f.column
+			}
+		}
+	}
+
+	counts := q.includes(IncludeSubtaskCount)
+	query := `SELECT ` + strings.Join(cols, ", ")
+	var queryArgs []any
+	if counts {
+		query += `, COALESCE(c.n, 0)
+       FROM tasks t
+       LEFT JOIN (
+         SELECT parent_id, COUNT(*) AS n
+         FROM tasks
+         WHERE user_id = ? AND parent_id IS NOT NULL
+         GROUP BY parent_id
+       ) c ON c.parent_id = t.id`
+		queryArgs = append(queryArgs, userID)
+	} else {
+		query += `
+       FROM tasks t`
+	}
+	query += `
+       WHERE t.user_id = ?`
+	queryArgs = append(queryArgs, userID)
+	if where != "" {
+		query += ` AND ` + where
+		queryArgs = append(queryArgs, args...)
+	}
+	query += `
+       ORDER BY t.created_at DESC`
+
+	rows, err := s.db.QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []models.Task{}
+	for rows.Next() {
+		t, err := scanTaskFields(rows, fields, counts)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *t)
+	}
+	return tasks, rows.Err()
+}
+
+// scanTaskFields reads a row selected by selectTasks.
+func scanTaskFields(row interface{ Scan(dest ...any) error }, fields []string, count bool) (*models.Task, error) {
+	var (
+		t         models.Task
+		parentID  sql.NullInt64
+		projectID sql.NullInt64
+		n         int
+	)
+	dest := make([]any, 0, len(fields)+1)
+	for _, name := range fields {
+		switch name {
+		case "id":
+			dest = append(dest, &t.ID)
+		case "title":
+			dest = append(dest, &t.Title)
+		case "description":
+			dest = append(dest, &t.Description)
+		case "done":
+			dest = append(dest, &t.Done)
+		case "userId":
+			dest = append(dest, &t.UserID)
+		case "parentId":
+			dest = append(dest, &parentID)
+		case "projectId":
+			dest = append(dest, &projectID)
+		case "version":
+			dest = append(dest, &t.Version)
+		case "createdAt":
+			dest = append(dest, &t.CreatedAt)
+		case "updatedAt":
+			dest = append(dest, &t.UpdatedAt)
+		}
+	}
+	if count {
+		dest = append(dest, &n)
+	}
+
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		t.ParentID = &id
+	}
+	if projectID.Valid {
+		id := int(projectID.Int64)
+		t.ProjectID = &id
+	}
+	if count {
+		t.SubtaskCount = &n
+	}
+	return &t, nil
+}
+
+// TasksByID returns the user's tasks with the given IDs, in any order.
+// IDs of other users' tasks and unknown IDs are left out.
+func (s *TaskService) TasksByID(ctx context.Context, userID int, ids []int) ([]models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.TasksByID")
+	defer span.End()
+
+	return s.selectTasksIn(ctx, userID, TaskQuery{}, TaskFields(), "t.id", intArgs(ids))
+}
+
+// SubtasksOf returns the direct subtasks of the given tasks, newest first.
+func (s *TaskService) SubtasksOf(ctx context.Context, userID int, parentIDs []int) ([]models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.SubtasksOf")
+	defer span.End()
+
+	return s.selectTasksIn(ctx, userID, TaskQuery{}, TaskFields(), "t.parent_id", intArgs(parentIDs))
+}
+
+func intArgs(ids []int) []any {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTaskQuerySelectsKeysOfRelations(t *testing.T) {
+	tests := []struct {
+		q    TaskQuery
+		want []string
+	}{
+		{TaskQuery{}, TaskFields()},
+		{TaskQuery{Fields: []string{"done", "title"}}, []string{"title", "done"}},
+		{TaskQuery{Fields: []string{"title"}, Include: []string{IncludeSubtasks}}, []string{"id", "title"}},
+		{TaskQuery{Fields: []string{"title"}, Include: []string{IncludeParent, IncludeProject}}, []string{"title", "parentId", "projectId"}},
+	}
+	for _, tt := range tests {
+		if got := tt.q.selects(tt.q.keys()...); !slices.Equal(got, tt.want) {
+			t.Errorf("%+v selects %v, want %v", tt.q, got, tt.want)
+		}
+	}
+}
+
+// Only the fields asked for, and the keys to match subtasks on, are
+// queried; the subtasks of every task come from one more query.
+func TestQueryTasksEmbedsSubtasks(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	mock.ExpectQuery(q("SELECT t.id, t.title\n       FROM tasks t\n       WHERE t.user_id = ?\n       ORDER BY")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow(3, "Groceries").AddRow(4, "Laundry"))
+	mock.ExpectQuery(q("SELECT t.title, t.parent_id\n       FROM tasks t\n       WHERE t.user_id = ? AND t.parent_id IN (?, ?)")).
+		WithArgs(1, 3, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"title", "parent_id"}).AddRow("Milk", 3).AddRow("Bread", 3))
+
+	tasks, err := s.QueryTasks(context.Background(), 1, TaskQuery{Fields: []string{"title"}, Include: []string{IncludeSubtasks}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 || len(tasks[0].Subtasks) != 2 || tasks[0].Subtasks[1].Title != "Bread" {
+		t.Fatalf("tasks = %+v, want task 3 with two subtasks", tasks)
+	}
+	if tasks[1].Subtasks == nil || len(tasks[1].Subtasks) != 0 {
+		t.Errorf("task 4 subtasks = %v, want an empty list", tasks[1].Subtasks)
+	}
+}
+
+func TestQueryTaskCountsSubtasks(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	mock.ExpectQuery(q("SELECT t.id, t.done, COALESCE(c.n, 0)")).
+		WithArgs(1, 1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "done", "n"}).AddRow(3, false, 2))
+
+	task, err := s.QueryTask(context.Background(), 3, 1, TaskQuery{Fields: []string{"id", "done"}, Include: []string{IncludeSubtaskCount}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.SubtaskCount == nil || *task.SubtaskCount != 2 {
+		t.Errorf("subtaskCount = %v, want 2", task.SubtaskCount)
+	}
+}
+
+func TestQueryTaskNotFound(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	mock.ExpectQuery(q("FROM tasks t")).WillReturnRows(sqlmock.NewRows([]string{"title"}))
+
+	if _, err := s.QueryTask(context.Background(), 3, 1, TaskQuery{Fields: []string{"title"}}); !errors.Is(err, ErrTaskNotFound) {
+		t.Fatalf("err = %v, want ErrTaskNotFound", err)
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"task-manager-server/internal/audit"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+	"task-manager-server/internal/textdiff"
+)
+
+const revisionColumns = `revision, title, description, done, parent_id, change_seq, created_at`
+
+const (
+	defaultRevisionPage = 50
+	maxRevisionPage     = 200
+)
+
+// TaskRevisions returns a page of the revisions of one of the user's tasks,
+// newest first, each compared with the one before. Only revisions older
+// than before are listed when it is positive.
+func (s *TaskService) TaskRevisions(ctx context.Context, id, userID, before, limit int) (*models.TaskRevisionsResponse, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.TaskRevisions")
+	defer span.End()
+
+	if _, err := getTask(repository.Bind(ctx, s.db), id, userID, false); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultRevisionPage
+	}
+	limit = min(limit, maxRevisionPage)
+
+	where, args := ` WHERE task_id = ?`, []any{id}
+	if before > 0 {
+		where += ` AND revision < ?`
+		args = append(args, before)
+	}
+	// One more than the page is compared with the oldest revision on it and
+	// tells whether there is a next page.
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT `+revisionColumns+` FROM task_revisions`+where+` ORDER BY revision DESC LIMIT `+strconv.Itoa(limit+1),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.TaskRevision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i+1 < len(revisions); i++ {
+		if err := compareRevisions(&revisions[i+1], &revisions[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	page := &models.TaskRevisionsResponse{Revisions: revisions}
+	if len(revisions) > limit {
+		page.Revisions = revisions[:limit]
+		page.Before = &revisions[limit-1].Revision
+	}
+	return page, nil
+}
+
+// RevertTask restores the title, description and done state of a task to
+// those of one of its revisions. The result is a change like any other, so
+// it becomes the newest revision and the history is kept. The task stays
+// where it is; moves are not reverted. ifVersion works as for UpdateTask.
+func (s *TaskService) RevertTask(ctx context.Context, id, userID, revision int, ifVersion *int64) (task *models.Task, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.RevertTask")
+	defer span.End()
+
+	err = s.inTx(ctx, userID, commandRevert, func(tx *taskTx) error {
+		task, err = tx.revert(id, revision, ifVersion)
+		return err
+	})
+	return task, err
+}
+
+func (t *taskTx) revert(id, revision int, ifVersion *int64) (*models.Task, error) {
+	task, err := t.lock(id, ifVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := scanRevision(t.tx.QueryRow(
+		`SELECT `+revisionColumns+` FROM task_revisions WHERE task_id = ? AND revision = ?`,
+		id,
+		revision,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Title == rev.Title && task.Description == rev.Description && task.Done == rev.Done {
+		return task, nil
+	}
+	wasDone := task.Done
+	task.Title, task.Description, task.Done = rev.Title, rev.Description, rev.Done
+	if err := t.save(task); err != nil {
+		return nil, err
+	}
+	t.completed(wasDone, task)
+	return task, nil
+}
+
+// snapshot stores task as its next revision. The task row is locked by the
+// transaction, so revisions are numbered without gaps or races.
+func (t *taskTx) snapshot(task *models.Task) error {
+	_, err := t.tx.Exec(
+		`INSERT INTO task_revisions (task_id, revision, title, description, done, parent_id, change_seq, created_at)
+       SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, ?, ?, ?, ?
+       FROM task_revisions
+       WHERE task_id = ?`,
+		task.ID,
+		task.Title,
+		task.Description,
+		task.Done,
+		task.ParentID,
+		task.Version,
+		task.UpdatedAt,
+		task.ID,
+	)
+	return err
+}
+
+// compareRevisions sets the changes of rev since prev.
+func compareRevisions(prev, rev *models.TaskRevision) error {
+	changes, err := audit.Diff(prev, rev, "revision", "version", "createdAt", "changes", "descriptionDiff")
+	if err != nil {
+		return err
+	}
+	rev.Changes = changes
+	if prev.Description != rev.Description {
+		for _, line := range textdiff.Lines(prev.Description, rev.Description) {
+			rev.DescriptionDiff = append(rev.DescriptionDiff, models.DiffLine{Op: string(line.Op), Text: line.Text})
+		}
+	}
+	return nil
+}
+
+func scanRevision(row interface{ Scan(dest ...any) error }) (*models.TaskRevision, error) {
+	var rev models.TaskRevision
+	var parentID sql.NullInt64
+	if err := row.Scan(
+		&rev.Revision,
+		&rev.Title,
+		&rev.Description,
+		&rev.Done,
+		&parentID,
+		&rev.Version,
+		&rev.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		rev.ParentID = &id
+	}
+	return &rev, nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/events"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newTestTaskService(t *testing.T) (*TaskService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	uow := repository.NewUnitOfWork(db, config.TxConfig{MaxAttempts: 1})
+	return NewTaskService(db, uow, events.NewBroker(16), config.UndoConfig{}), mock
+}
+
+var taskRow = []string{"id", "title", "description", "done", "user_id", "parent_id", "project_id", "change_seq", "created_at", "updated_at"}
+
+// expectTask expects task to be read, locked when forUpdate is set.
+func expectTask(mock sqlmock.Sqlmock, task models.Task, forUpdate bool) {
+	query := "FROM tasks"
+	if forUpdate {
+		query = "FOR UPDATE"
+	}
+	mock.ExpectQuery(q(query)).
+		WillReturnRows(sqlmock.NewRows(taskRow).AddRow(
+			task.ID, task.Title, task.Description, task.Done, task.UserID,
+			task.ParentID, task.ProjectID, task.Version, task.CreatedAt, task.UpdatedAt,
+		))
+}
+
+var revisionRow = []string{"revision", "title", "description", "done", "parent_id", "change_seq", "created_at"}
+
+func TestTaskRevisionsPages(t *testing.T) {
+	s, mock := newTestTaskService(t)
+	ctx := context.Background()
+	now := time.Now()
+	task := models.Task{ID: 3, Title: "Buy groceries", UserID: 1}
+
+	// Revisions 5 down to 3: two for the page, one to compare with.
+	expectTask(mock, task, false)
+	mock.ExpectQuery(q("FROM task_revisions WHERE task_id = ? ORDER BY revision DESC LIMIT 3")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(revisionRow).
+			AddRow(5, "Buy groceries", "Milk\nEggs\nBread", false, nil, 50, now).
+			AddRow(4, "Buy groceries", "Milk\nBread", false, nil, 40, now).
+			AddRow(3, "Groceries", "Milk\nBread", false, nil, 30, now))
+
+	page, err := s.TaskRevisions(ctx, 3, 1, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Revisions) != 2 || page.Revisions[0].Revision != 5 || page.Revisions[1].Revision != 4 {
+		t.Fatalf("revisions = %+v, want 5 and 4", page.Revisions)
+	}
+	if page.Before == nil || *page.Before != 4 {
+		t.Fatalf("before = %v, want 4", page.Before)
+	}
+	want := []models.DiffLine{{Op: "equal", Text: "Milk"}, {Op: "insert", Text: "Eggs"}, {Op: "equal", Text: "Bread"}}
+	if diff := page.Revisions[0].DescriptionDiff; !slices.Equal(diff, want) {
+		t.Errorf("revision 5 diff = %+v, want %+v", diff, want)
+	}
+	if _, ok := page.Revisions[1].Changes["title"]; !ok {
+		t.Errorf("revision 4 changes = %v, want the title compared with revision 3", page.Revisions[1].Changes)
+	}
+
+	// The last page: revision 1 has nothing before it.
+	expectTask(mock, task, false)
+	mock.ExpectQuery(q("FROM task_revisions WHERE task_id = ? AND revision < ? ORDER BY revision DESC LIMIT 3")).
+		WithArgs(3, 2).
+		WillReturnRows(sqlmock.NewRows(revisionRow).
+			AddRow(1, "Groceries", "", false, nil, 10, now))
+
+	page, err = s.TaskRevisions(ctx, 3, 1, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Revisions) != 1 || page.Before != nil || page.Revisions[0].Changes != nil {
+		t.Fatalf("page = %+v, want revision 1 alone without changes", page)
+	}
+}
+
+func TestTaskRevisionsCapsTheLimit(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	expectTask(mock, models.Task{ID: 3, UserID: 1}, false)
+	mock.ExpectQuery(q("ORDER BY revision DESC LIMIT 201")).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(revisionRow))
+
+	page, err := s.TaskRevisions(context.Background(), 3, 1, 0, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Revisions) != 0 || page.Before != nil {
+		t.Fatalf("page = %+v, want it empty", page)
+	}
+}
@@ -1,154 +1,467 @@
 package services
 
 import (
+	"context"
 	"database/sql"
-	"errors"
+	"strconv"
 	"time"
 
+	"task-manager-server/internal/audit"
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/events"
+	"task-manager-server/internal/metrics"
 	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
 )
 
+// dbtx runs queries on the pool or inside a transaction.
+type dbtx = repository.DBTX
+
+// maxTaskDepth bounds how deep subtasks nest.
+const maxTaskDepth = 32
+
 type TaskService struct {
-	db *sql.DB
+	db     *sql.DB
+	uow    *repository.UnitOfWork
+	events *events.Broker
+	undo   config.UndoConfig
 }
 
-func NewTaskService(db *sql.DB) *TaskService {
+// NewTaskService creates the service. Changes run as units of work of uow
+// and are published to broker once they are stored.
+func NewTaskService(db *sql.DB, uow *repository.UnitOfWork, broker *events.Broker, undo config.UndoConfig) *TaskService {
 	return &TaskService{
-		db: db,
+		db:     db,
+		uow:    uow,
+		events: broker,
+		undo:   undo,
 	}
 }
 
-func (s *TaskService) GetTasks(userID int) ([]models.Task, error) {
-	rows, err := s.db.Query(
-		`SELECT id, title, description, done, user_id, created_at, updated_at
-       FROM tasks
-       WHERE user_id = ?
-       ORDER BY created_at DESC`,
-		userID,
-	)
+// GetTasks returns every field of the user's tasks, newest first.
+func (s *TaskService) GetTasks(ctx context.Context, userID int) ([]models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.GetTasks")
+	defer span.End()
+
+	return s.QueryTasks(ctx, userID, TaskQuery{})
+}
+
+func (s *TaskService) GetTask(ctx context.Context, id, userID int) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.GetTask")
+	defer span.End()
+
+	return getTask(repository.Bind(ctx, s.db), id, userID, false)
+}
+
+func (s *TaskService) CreateTask(ctx context.Context, req *models.CreateTaskRequest, userID int) (task *models.Task, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.CreateTask")
+	defer span.End()
+
+	err = s.inTx(ctx, userID, commandCreate, func(tx *taskTx) error {
+		task, err = tx.create(req)
+		return err
+	})
+	return task, err
+}
+
+// UpdateTask changes the fields provided in req. When ifVersion is set the
+// task must still be at that version, otherwise ErrVersionConflict is
+// returned and nothing changes.
+func (s *TaskService) UpdateTask(ctx context.Context, id, userID int, req *models.UpdateTaskRequest, ifVersion *int64) (task *models.Task, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.UpdateTask")
+	defer span.End()
+
+	err = s.inTx(ctx, userID, commandUpdate, func(tx *taskTx) error {
+		task, err = tx.update(id, req, ifVersion)
+		return err
+	})
+	return task, err
+}
+
+// MoveTask makes a task a subtask of parentID, or a top-level task when
+// parentID is nil. ifVersion works as for UpdateTask.
+func (s *TaskService) MoveTask(ctx context.Context, id, userID int, parentID *int, ifVersion *int64) (task *models.Task, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.MoveTask")
+	defer span.End()
+
+	err = s.inTx(ctx, userID, commandMove, func(tx *taskTx) error {
+		task, err = tx.move(id, parentID, ifVersion)
+		return err
+	})
+	return task, err
+}
+
+// PatchTask changes a task with patch, which is given the current task and
+// sets the fields to change. It runs while the task is locked, so patches
+// that check the current values (like JSON Patch test operations) see the
+// state they change. Errors returned by patch are returned as is.
+func (s *TaskService) PatchTask(ctx context.Context, id, userID int, patch func(task *models.Task) error) (task *models.Task, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.PatchTask")
+	defer span.End()
+
+	err = s.inTx(ctx, userID, commandPatch, func(tx *taskTx) error {
+		task, err = tx.patch(id, patch)
+		return err
+	})
+	return task, err
+}
+
+// DeleteTask removes a task and leaves a tombstone so syncing clients learn
+// about the delete. Its subtasks become top-level tasks. ifVersion works as
+// for UpdateTask.
+func (s *TaskService) DeleteTask(ctx context.Context, id, userID int, ifVersion *int64) error {
+	ctx, span := tracer.Start(ctx, "TaskService.DeleteTask")
+	defer span.End()
+
+	return s.inTx(ctx, userID, commandDelete, func(tx *taskTx) error {
+		return tx.delete(id, ifVersion)
+	})
+}
+
+// GetSharedTask returns a task the user owns or that is in one of the
+// user's projects.
+func (s *TaskService) GetSharedTask(ctx context.Context, id, userID int) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.GetSharedTask")
+	defer span.End()
+
+	task, err := repository.NewTaskRepository(repository.Bind(ctx, s.db)).GetShared(id, userID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var tasks []models.Task
-	for rows.Next() {
-		var t models.Task
-		if err := rows.Scan(
-			&t.ID,
-			&t.Title,
-			&t.Description,
-			&t.Done,
-			&t.UserID,
-			&t.CreatedAt,
-			&t.UpdatedAt,
-		); err != nil {
+	if task == nil {
+		return nil, ErrTaskNotFound
+	}
+	return task, nil
+}
+
+// SetTaskProject shares one of the user's tasks in a project the user is a
+// member of, or takes it out of its project when projectID is nil. Subtasks
+// are not moved along. It is not an undoable command: undo would otherwise
+// hide a task from, or show it to, other users. ifVersion works as for
+// UpdateTask.
+func (s *TaskService) SetTaskProject(ctx context.Context, id, userID int, projectID *int, ifVersion *int64) (task *models.Task, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.SetTaskProject")
+	defer span.End()
+
+	err = s.inTx(ctx, userID, "", func(tx *taskTx) error {
+		task, err = tx.lock(id, ifVersion)
+		if err != nil {
+			return err
+		}
+		if projectID != nil {
+			if _, err := projectOwner(tx.tx, *projectID, userID); err != nil {
+				return err
+			}
+		}
+		if sameID(task.ProjectID, projectID) {
+			return nil
+		}
+		task.ProjectID = projectID
+		return tx.save(task)
+	})
+	return task, err
+}
+
+// inTx runs fn as a unit of work, recording its changes in the audit log
+// and, as one command, in the undo journal; undo and redo pass no command.
+// The events of the changes are published once it committed. fn may run
+// more than once when the transaction is retried, each time on a fresh
+// taskTx.
+func (s *TaskService) inTx(ctx context.Context, userID int, command string, fn func(tx *taskTx) error) error {
+	return s.uow.WithTx(ctx, func(sqlTx *repository.Tx) error {
+		tx := &taskTx{ctx: ctx, tx: sqlTx, userID: userID, before: map[int]models.Task{}}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if command != "" {
+			if err := s.journal(tx, command); err != nil {
+				return err
+			}
+		}
+
+		sqlTx.AfterCommit(func() {
+			for _, e := range tx.events {
+				switch e.typ {
+				case events.TaskCreated:
+					metrics.TasksCreated.Inc()
+				case events.TaskCompleted:
+					metrics.TasksCompleted.Inc()
+				}
+				s.events.Publish(userID, e.typ, e.data)
+			}
+		})
+		return nil
+	})
+}
+
+type taskEvent struct {
+	typ  string
+	data any
+}
+
+// taskTx changes one user's tasks inside a transaction. The events of the
+// changes are collected for publishing after the commit.
+type taskTx struct {
+	ctx    context.Context
+	tx     *repository.Tx
+	userID int
+	events []taskEvent
+	// before holds the stored state of the tasks loaded for changing, which
+	// their audit entries are diffed against.
+	before map[int]models.Task
+	// changes are kept for the undo journal.
+	changes []journalChange
+}
+
+func (t *taskTx) create(req *models.CreateTaskRequest) (*models.Task, error) {
+	if req.ParentID != nil {
+		if _, err := t.parent(*req.ParentID); err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, t)
-	}
-
-	return tasks, rows.Err()
-}
-
-func (s *TaskService) GetTask(id, userID int) (*models.Task, error) {
-	var t models.Task
-	err := s.db.QueryRow(
-		`SELECT id, title, description, done, user_id, created_at, updated_at
-       FROM tasks
-       WHERE id = ? AND user_id = ?
-       LIMIT 1`,
-		id,
-		userID,
-	).Scan(
-		&t.ID,
-		&t.Title,
-		&t.Description,
-		&t.Done,
-		&t.UserID,
-		&t.CreatedAt,
-		&t.UpdatedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, errors.New("task not found")
 	}
+
+	seq, err := t.tx.Tasks().NextVersion(t.userID)
 	if err != nil {
 		return nil, err
 	}
-	return &t, nil
-}
 
-func (s *TaskService) CreateTask(req *models.CreateTaskRequest, userID int) (*models.Task, error) {
 	now := time.Now()
+	task := &models.Task{
+		Title:       req.Title,
+		Description: req.Description,
+		Done:        req.Done,
+		UserID:      t.userID,
+		ParentID:    req.ParentID,
+		Version:     seq,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := t.tx.Tasks().Create(task); err != nil {
+		return nil, err
+	}
+	if err := t.record(events.TaskCreated, task.ID, nil, task); err != nil {
+		return nil, err
+	}
+	if err := t.snapshot(task); err != nil {
+		return nil, err
+	}
+	t.events = append(t.events, taskEvent{events.TaskCreated, task})
+	return task, nil
+}
 
-	res, err := s.db.Exec(
-		`INSERT INTO tasks (title, description, done, user_id, created_at, updated_at)
-       VALUES (?, ?, ?, ?, ?, ?)`,
-		req.Title,
-		req.Description,
-		req.Done,
-		userID,
-		now,
-		now,
-	)
+func (t *taskTx) update(id int, req *models.UpdateTaskRequest, ifVersion *int64) (*models.Task, error) {
+	// Lock the row so concurrent partial updates do not overwrite each
+	// other's fields.
+	task, err := t.lock(id, ifVersion)
 	if err != nil {
 		return nil, err
 	}
 
-	id, err := res.LastInsertId()
+	wasDone := task.Done
+	if req.Title != nil {
+		task.Title = *req.Title
+	}
+	if req.Description != nil {
+		task.Description = *req.Description
+	}
+	if req.Done != nil {
+		task.Done = *req.Done
+	}
+
+	if err := t.save(task); err != nil {
+		return nil, err
+	}
+	t.completed(wasDone, task)
+	return task, nil
+}
+
+func (t *taskTx) move(id int, parentID *int, ifVersion *int64) (*models.Task, error) {
+	task, err := t.lock(id, ifVersion)
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.Task{
-		ID:          int(id),
-		Title:       req.Title,
-		Description: req.Description,
-		Done:        req.Done,
-		UserID:      userID,
-		CreatedAt:   now,
-		UpdatedAt:   now,
-	}, nil
+	if err := t.checkParent(id, parentID); err != nil {
+		return nil, err
+	}
+
+	task.ParentID = parentID
+	return task, t.save(task)
 }
 
-// Optional helpers if you later want real DB updates/deletes:
+func (t *taskTx) patch(id int, patch func(task *models.Task) error) (*models.Task, error) {
+	task, err := t.lock(id, nil)
+	if err != nil {
+		return nil, err
+	}
 
-func (s *TaskService) UpdateTask(id, userID int, req *models.CreateTaskRequest) (*models.Task, error) {
-	now := time.Now()
+	before := *task
+	if err := patch(task); err != nil {
+		return nil, err
+	}
+
+	// Only the writable fields count; a patch that leaves them alone is
+	// not a change.
+	moved := !sameID(before.ParentID, task.ParentID)
+	if !moved && task.Title == before.Title && task.Description == before.Description && task.Done == before.Done {
+		return &before, nil
+	}
+	if moved {
+		if err := t.checkParent(id, task.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	task.ID, task.UserID, task.Version = before.ID, before.UserID, before.Version
+	task.CreatedAt = before.CreatedAt
+	if err := t.save(task); err != nil {
+		return nil, err
+	}
+	t.completed(before.Done, task)
+	return task, nil
+}
 
-	_, err := s.db.Exec(
-		`UPDATE tasks
-       SET title = ?, description = ?, done = ?, updated_at = ?
-       WHERE id = ? AND user_id = ?`,
-		req.Title,
-		req.Description,
-		req.Done,
-		now,
-		id,
-		userID,
-	)
+func (t *taskTx) delete(id int, ifVersion *int64) error {
+	task, err := t.lock(id, ifVersion)
+	if err != nil {
+		return err
+	}
+
+	// Promote the subtasks first; each one is a change of its own.
+	children, err := t.tx.Tasks().GetChildren(id, t.userID, true)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		t.before[child.ID] = *child
+		child.ParentID = nil
+		if err := t.save(child); err != nil {
+			return err
+		}
+	}
+
+	seq, err := t.tx.Tasks().NextVersion(t.userID)
+	if err != nil {
+		return err
+	}
+	if err := t.tx.Tasks().Delete(id, t.userID, seq, time.Now()); err != nil {
+		return err
+	}
+
+	if err := t.record(events.TaskDeleted, id, task, nil); err != nil {
+		return err
+	}
+	t.events = append(t.events, taskEvent{events.TaskDeleted, models.DeletedTask{ID: id, Version: seq, ProjectID: task.ProjectID}})
+	return nil
+}
+
+// checkParent checks that task id can become a subtask of parentID.
+func (t *taskTx) checkParent(id int, parentID *int) error {
+	// Walk up from the new parent: finding the task itself means it would
+	// end up under one of its own subtasks.
+	next := parentID
+	for depth := 0; next != nil; depth++ {
+		if *next == id {
+			return ErrTaskCycle
+		}
+		if depth >= maxTaskDepth {
+			return ErrTaskTooDeep
+		}
+		parent, err := t.parent(*next)
+		if err != nil {
+			return err
+		}
+		next = parent.ParentID
+	}
+	return nil
+}
+
+// sameID compares optional references to tasks or projects.
+func sameID(a, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// lock loads a task for changing it and checks its version.
+func (t *taskTx) lock(id int, ifVersion *int64) (*models.Task, error) {
+	task, err := getTask(t.tx, id, t.userID, true)
 	if err != nil {
 		return nil, err
 	}
+	if ifVersion != nil && *ifVersion != task.Version {
+		return nil, ErrVersionConflict
+	}
+	t.before[id] = *task
+	return task, nil
+}
 
-	return s.GetTask(id, userID)
+// parent loads a task that is to become a parent.
+func (t *taskTx) parent(id int) (*models.Task, error) {
+	task, err := getTask(t.tx, id, t.userID, false)
+	if err == ErrTaskNotFound {
+		return nil, ErrParentNotFound
+	}
+	return task, err
 }
 
-func (s *TaskService) DeleteTask(id, userID int) error {
-	res, err := s.db.Exec(
-		`DELETE FROM tasks WHERE id = ? AND user_id = ?`,
-		id,
-		userID,
-	)
+// save writes a changed task with the next change sequence.
+func (t *taskTx) save(task *models.Task) error {
+	seq, err := t.tx.Tasks().NextVersion(t.userID)
 	if err != nil {
 		return err
 	}
-	affected, err := res.RowsAffected()
-	if err != nil {
+	task.Version = seq
+	task.UpdatedAt = time.Now()
+	if err := t.tx.Tasks().Update(task); err != nil {
+		return err
+	}
+
+	before := t.before[task.ID]
+	if err := t.record(events.TaskUpdated, task.ID, &before, task); err != nil {
 		return err
 	}
-	if affected == 0 {
-		return errors.New("task not found")
+	if err := t.snapshot(task); err != nil {
+		return err
 	}
+	t.before[task.ID] = *task
+	t.events = append(t.events, taskEvent{events.TaskUpdated, task})
 	return nil
-}
\ No newline at end of file
+}
+
+// record adds the change of task id to the audit log; before is nil for a
+// creation and after for a delete.
+func (t *taskTx) record(action string, id int, before, after *models.Task) error {
+	changes, err := audit.Diff(before, after, "version", "createdAt", "updatedAt")
+	if err != nil {
+		return err
+	}
+	t.changes = append(t.changes, journalChange{TaskID: id, Before: journalState(before), After: journalState(after)})
+
+	actorID := t.userID
+	return recordAudit(t.ctx, t.tx, &models.AuditEntry{
+		ActorID:    &actorID,
+		Action:     action,
+		EntityType: EntityTask,
+		EntityID:   strconv.Itoa(id),
+		Changes:    changes,
+	})
+}
+
+// completed records a task.completed event when a saved task became done.
+func (t *taskTx) completed(wasDone bool, task *models.Task) {
+	if !wasDone && task.Done {
+		t.events = append(t.events, taskEvent{events.TaskCompleted, task})
+	}
+}
+
+// getTask loads one of the user's tasks. forUpdate locks the row until the
+// transaction ends.
+func getTask(q dbtx, id, userID int, forUpdate bool) (*models.Task, error) {
+	t, err := repository.NewTaskRepository(q).GetByID(id, userID, forUpdate)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrTaskNotFound
+	}
+	return t, nil
+}
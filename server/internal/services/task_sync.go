@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+)
+
+// syncPageSize bounds the tasks and tombstones returned by one sync.
+const syncPageSize = 500
+
+// Changes returns the user's tasks changed and deleted after the sync token
+// since. since 0 asks for every task. Tokens from before the oldest
+// retained tombstone also get every task, since deletes may have been
+// missed; Full tells the client to replace its copy.
+func (s *TaskService) Changes(ctx context.Context, userID int, since int64) (*models.SyncResponse, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Changes")
+	defer span.End()
+
+	// One snapshot for the sequence and the rows, so the token returned
+	// covers exactly what is sent.
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	tasks := repository.NewTaskRepository(repository.Bind(ctx, tx))
+
+	current, purged, err := tasks.GetSequence(userID)
+	if err != nil {
+		return nil, err
+	}
+	if since < 0 || since > current {
+		return nil, ErrInvalidSyncToken
+	}
+
+	full := since == 0 || since < purged
+	if full {
+		since = 0
+	}
+
+	changed, err := tasks.GetChangedSince(userID, since, syncPageSize+1)
+	if err != nil {
+		return nil, err
+	}
+	deleted := []models.DeletedTask{}
+	if !full {
+		if deleted, err = tasks.GetDeletedSince(userID, since, syncPageSize+1); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &models.SyncResponse{
+		Tasks:   []models.Task{},
+		Deleted: []models.DeletedTask{},
+		Full:    full,
+	}
+	token := current
+
+	// Both lists are ordered by sequence; take the first syncPageSize
+	// changes across them.
+	i, j := 0, 0
+	for i+j < syncPageSize && (i < len(changed) || j < len(deleted)) {
+		if j == len(deleted) || (i < len(changed) && changed[i].Version < deleted[j].Version) {
+			res.Tasks = append(res.Tasks, changed[i])
+			i++
+		} else {
+			res.Deleted = append(res.Deleted, deleted[j])
+			j++
+		}
+	}
+	if i < len(changed) || j < len(deleted) {
+		res.HasMore = true
+		token = max(lastVersion(res.Tasks), lastDeleted(res.Deleted))
+	}
+	res.Token = strconv.FormatInt(token, 10)
+
+	return res, tx.Commit()
+}
+
+func lastVersion(tasks []models.Task) int64 {
+	if len(tasks) == 0 {
+		return 0
+	}
+	return tasks[len(tasks)-1].Version
+}
+
+func lastDeleted(deleted []models.DeletedTask) int64 {
+	if len(deleted) == 0 {
+		return 0
+	}
+	return deleted[len(deleted)-1].Version
+}
+
+// PurgeTombstones drops the tombstones of tasks deleted before cutoff and
+// records, per user, how far the sequence was purged, so older sync tokens
+// get a full resync instead of silently missing deletes. Each user is purged
+// in a transaction of its own, which only holds up that user's writes.
+func (s *TaskService) PurgeTombstones(cutoff time.Time) (purged int64, err error) {
+	ctx := context.Background()
+	expired, err := repository.NewTaskRepository(repository.Bind(ctx, s.db)).GetExpiredTombstones(cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, userID := range slices.Sorted(maps.Keys(expired)) {
+		err := s.uow.WithTx(ctx, func(tx *repository.Tx) error {
+			n, err := tx.Tasks().PurgeTombstones(userID, expired[userID])
+			if err == nil {
+				purged += n
+			}
+			return err
+		})
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// RunTombstonePurge purges tombstones older than retention every interval.
+// It runs until the process exits.
+func (s *TaskService) RunTombstonePurge(retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := s.PurgeTombstones(time.Now().Add(-retention))
+		if err != nil {
+			slog.Error("Sync: purging tombstones", "err", err)
+			continue
+		}
+		if n > 0 {
+			slog.Info("Sync: purged tombstones", "count", n)
+		}
+	}
+}
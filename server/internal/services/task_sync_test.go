@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectSequence(mock sqlmock.Sqlmock, current, purged int64) {
+	mock.ExpectQuery(q("SELECT value, purged_through FROM change_sequences WHERE user_id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"value", "purged_through"}).AddRow(current, purged))
+}
+
+// changedRows returns task rows stamped with versions.
+func changedRows(versions ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows(taskRow)
+	for _, v := range versions {
+		rows.AddRow(int(v), "t", "", false, 1, nil, nil, v, time.Now(), time.Now())
+	}
+	return rows
+}
+
+func deletedRows(versions ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"task_id", "change_seq"})
+	for _, v := range versions {
+		rows.AddRow(int(v), v)
+	}
+	return rows
+}
+
+func TestChangesMergesTasksAndTombstones(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	mock.ExpectBegin()
+	expectSequence(mock, 45, 10)
+	mock.ExpectQuery(q("FROM tasks\n\t\tWHERE user_id = ? AND change_seq > ?")).
+		WithArgs(1, 40, syncPageSize+1).
+		WillReturnRows(changedRows(42, 44))
+	mock.ExpectQuery(q("FROM task_tombstones")).
+		WithArgs(1, 40, syncPageSize+1).
+		WillReturnRows(deletedRows(43))
+	mock.ExpectCommit()
+
+	res, err := s.Changes(context.Background(), 1, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Full || res.HasMore || res.Token != "45" {
+		t.Errorf("full %v, hasMore %v, token %s; want an incremental, complete sync to 45", res.Full, res.HasMore, res.Token)
+	}
+	if len(res.Tasks) != 2 || len(res.Deleted) != 1 || res.Deleted[0].ID != 43 {
+		t.Errorf("tasks %+v, deleted %+v; want tasks 42 and 44 and tombstone 43", res.Tasks, res.Deleted)
+	}
+}
+
+// A token older than the purged tombstones may have missed deletes, so the
+// client gets every task and replaces its copy.
+func TestChangesFullAfterPurge(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	mock.ExpectBegin()
+	expectSequence(mock, 45, 30)
+	mock.ExpectQuery(q("FROM tasks\n\t\tWHERE user_id = ? AND change_seq > ?")).
+		WithArgs(1, 0, syncPageSize+1).
+		WillReturnRows(changedRows(44))
+	mock.ExpectCommit()
+
+	res, err := s.Changes(context.Background(), 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Full || len(res.Tasks) != 1 || len(res.Deleted) != 0 {
+		t.Errorf("got %+v, want a full sync of one task", res)
+	}
+}
+
+func TestChangesPagesInSequenceOrder(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	// Deletes take the odd versions and changes the even ones; one more of
+	// each than fits a page is returned.
+	var changed, deleted []int64
+	for v := int64(1); v <= 2*(syncPageSize+1); v++ {
+		if v%2 == 0 {
+			changed = append(changed, v)
+		} else {
+			deleted = append(deleted, v)
+		}
+	}
+
+	mock.ExpectBegin()
+	expectSequence(mock, 5000, 0)
+	mock.ExpectQuery(q("FROM tasks")).WillReturnRows(changedRows(changed...))
+	mock.ExpectQuery(q("FROM task_tombstones")).WillReturnRows(deletedRows(deleted...))
+	mock.ExpectCommit()
+
+	res, err := s.Changes(context.Background(), 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.HasMore || len(res.Tasks)+len(res.Deleted) != syncPageSize {
+		t.Fatalf("hasMore %v with %d changes, want a full page and more", res.HasMore, len(res.Tasks)+len(res.Deleted))
+	}
+	if res.Token != "500" {
+		t.Errorf("token = %s, want 500, the last change sent", res.Token)
+	}
+}
+
+func TestChangesRejectsUnknownToken(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	for _, since := range []int64{46, -1} {
+		mock.ExpectBegin()
+		expectSequence(mock, 45, 0)
+		mock.ExpectRollback()
+
+		if _, err := s.Changes(context.Background(), 1, since); !errors.Is(err, ErrInvalidSyncToken) {
+			t.Errorf("since %d: err = %v, want ErrInvalidSyncToken", since, err)
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"slices"
+	"time"
+
+	"task-manager-server/internal/models"
+)
+
+// Commands of the undo journal, one per transaction of task changes.
+const (
+	commandCreate = "create"
+	commandUpdate = "update"
+	commandMove   = "move"
+	commandPatch  = "patch"
+	commandDelete = "delete"
+	commandRevert = "revert"
+	commandBatch  = "batch"
+)
+
+// journalTask is the part of a task that undo and redo restore.
+type journalTask struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Done        bool   `json:"done"`
+	ParentID    *int   `json:"parentId"`
+}
+
+func journalState(task *models.Task) *journalTask {
+	if task == nil {
+		return nil
+	}
+	return &journalTask{
+		Title:       task.Title,
+		Description: task.Description,
+		Done:        task.Done,
+		ParentID:    task.ParentID,
+	}
+}
+
+// journalChange is one task changed by a command; Before is nil when it was
+// created and After when it was deleted.
+type journalChange struct {
+	TaskID int          `json:"taskId"`
+	Before *journalTask `json:"before"`
+	After  *journalTask `json:"after"`
+}
+
+// journal records the changes of tx as the user's newest command. A new
+// command ends what could be redone, and only the newest JournalDepth
+// commands are kept.
+func (s *TaskService) journal(tx *taskTx, command string) error {
+	if s.undo.JournalDepth <= 0 || len(tx.changes) == 0 {
+		return nil
+	}
+	changes, err := json.Marshal(tx.changes)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.tx.Exec(`DELETE FROM task_journal WHERE user_id = ? AND undone = 1`, tx.userID); err != nil {
+		return err
+	}
+	if _, err := tx.tx.Exec(
+		`INSERT INTO task_journal (user_id, command, changes, undone, created_at) VALUES (?, ?, ?, 0, ?)`,
+		tx.userID,
+		command,
+		string(changes),
+		time.Now(),
+	); err != nil {
+		return err
+	}
+	// The derived table lets MySQL read the table it deletes from.
+	_, err = tx.tx.Exec(
+		`DELETE FROM task_journal
+       WHERE user_id = ? AND id <= (
+         SELECT id FROM (
+           SELECT id FROM task_journal WHERE user_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+         ) AS oldest
+       )`,
+		tx.userID,
+		tx.userID,
+		s.undo.JournalDepth,
+	)
+	return err
+}
+
+// maxUndoSteps bounds the commands one undo or redo goes through.
+const maxUndoSteps = 20
+
+// Undo reverts the user's newest steps commands that are not undone yet,
+// newest first, in one transaction. Fields changed again since, and tasks
+// deleted since, are left as they are and reported as conflicts, so later
+// edits are never lost. steps is at least 1 and at most 20; it stops early
+// when the journal runs out.
+func (s *TaskService) Undo(ctx context.Context, userID, steps int) (res *models.UndoResponse, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Undo")
+	defer span.End()
+
+	err = s.inTx(ctx, userID, "", func(tx *taskTx) error {
+		res, err = tx.steps(true, steps)
+		return err
+	})
+	return res, err
+}
+
+// Redo applies again the steps commands undone last, oldest first, with the
+// same care and bounds as Undo. Any new command ends what can be redone.
+func (s *TaskService) Redo(ctx context.Context, userID, steps int) (res *models.UndoResponse, err error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Redo")
+	defer span.End()
+
+	err = s.inTx(ctx, userID, "", func(tx *taskTx) error {
+		res, err = tx.steps(false, steps)
+		return err
+	})
+	return res, err
+}
+
+// steps undoes or redoes up to n commands and merges what they did. Only
+// running out of commands on the first step is an error.
+func (t *taskTx) steps(undo bool, n int) (*models.UndoResponse, error) {
+	n = min(max(n, 1), maxUndoSteps)
+
+	merged := &models.UndoResponse{Commands: []string{}, Tasks: []models.Task{}, DeletedTaskIDs: []int{}}
+	touched := map[int]models.Task{}
+	deleted := map[int]bool{}
+	for i := range n {
+		res, err := t.step(undo)
+		if i > 0 && (err == ErrNothingToUndo || err == ErrNothingToRedo) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		merged.Commands = append(merged.Commands, res.Command)
+		merged.Conflicts = append(merged.Conflicts, res.Conflicts...)
+		for _, task := range res.Tasks {
+			touched[task.ID] = task
+			delete(deleted, task.ID)
+		}
+		for _, id := range res.DeletedTaskIDs {
+			delete(touched, id)
+			deleted[id] = true
+		}
+	}
+
+	merged.Command = merged.Commands[0]
+	for _, id := range slices.Sorted(maps.Keys(touched)) {
+		merged.Tasks = append(merged.Tasks, touched[id])
+	}
+	merged.DeletedTaskIDs = append(merged.DeletedTaskIDs, slices.Sorted(maps.Keys(deleted))...)
+	return merged, nil
+}
+
+// step undoes or redoes one command of the journal.
+func (t *taskTx) step(undo bool) (*models.UndoResponse, error) {
+	query := `SELECT id, command, changes FROM task_journal
+       WHERE user_id = ? AND undone = 0 ORDER BY id DESC LIMIT 1 FOR UPDATE`
+	if !undo {
+		query = `SELECT id, command, changes FROM task_journal
+       WHERE user_id = ? AND undone = 1 ORDER BY id LIMIT 1 FOR UPDATE`
+	}
+	var id int64
+	var command, data string
+	err := t.tx.QueryRow(query, t.userID).Scan(&id, &command, &data)
+	if err == sql.ErrNoRows {
+		if undo {
+			return nil, ErrNothingToUndo
+		}
+		return nil, ErrNothingToRedo
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []journalChange
+	if err := json.Unmarshal([]byte(data), &changes); err != nil {
+		return nil, err
+	}
+	if undo {
+		slices.Reverse(changes)
+	}
+
+	res, ids, err := t.replay(changes, undo)
+	if err != nil {
+		return nil, err
+	}
+	res.Command = command
+
+	if _, err := t.tx.Exec(`UPDATE task_journal SET undone = ? WHERE id = ?`, undo, id); err != nil {
+		return nil, err
+	}
+	// Recreated tasks have new IDs, which the journal must follow.
+	if len(ids) > 0 {
+		if err := t.remapJournal(ids); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// replay takes the tasks of changes from one of their states to the other:
+// from After to Before when undoing. A task is only changed where it is
+// still in the state being left. It returns the IDs of the tasks it had to
+// recreate, old to new.
+func (t *taskTx) replay(changes []journalChange, undo bool) (*models.UndoResponse, map[int]int, error) {
+	res := &models.UndoResponse{Tasks: []models.Task{}, DeletedTaskIDs: []int{}}
+	ids := map[int]int{}
+	remap := func(id *int) *int {
+		if id != nil {
+			if n, ok := ids[*id]; ok {
+				return &n
+			}
+		}
+		return id
+	}
+	touched := map[int]*models.Task{}
+
+	for _, c := range changes {
+		from, to := c.Before, c.After
+		if undo {
+			from, to = to, from
+		}
+		id := *remap(&c.TaskID)
+
+		switch {
+		case from == nil:
+			// Recreated under its parent if that still exists.
+			parentID := remap(to.ParentID)
+			if parentID != nil {
+				if _, err := t.parent(*parentID); err == ErrParentNotFound {
+					parentID = nil
+				} else if err != nil {
+					return nil, nil, err
+				}
+			}
+			task, err := t.create(&models.CreateTaskRequest{
+				Title:       to.Title,
+				Description: to.Description,
+				Done:        to.Done,
+				ParentID:    parentID,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			ids[c.TaskID] = task.ID
+			touched[task.ID] = task
+
+		case to == nil:
+			task, err := t.lock(id, nil)
+			if err == ErrTaskNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			if fields := changedFields(task, from, remap); len(fields) > 0 {
+				res.Conflicts = append(res.Conflicts, models.UndoConflict{TaskID: id, Reason: models.ConflictChanged, Fields: fields})
+				continue
+			}
+			if err := t.delete(id, nil); err != nil {
+				return nil, nil, err
+			}
+			delete(touched, id)
+			res.DeletedTaskIDs = append(res.DeletedTaskIDs, id)
+
+		default:
+			task, err := t.lock(id, nil)
+			if err == ErrTaskNotFound {
+				res.Conflicts = append(res.Conflicts, models.UndoConflict{TaskID: id, Reason: models.ConflictDeleted})
+				continue
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			changed, conflicts, err := t.restore(task, from, to, remap)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(conflicts) > 0 {
+				res.Conflicts = append(res.Conflicts, models.UndoConflict{TaskID: id, Reason: models.ConflictChanged, Fields: conflicts})
+			}
+			if changed {
+				touched[id] = task
+			}
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(touched)) {
+		res.Tasks = append(res.Tasks, *touched[id])
+	}
+	return res, ids, nil
+}
+
+// restore moves the fields of task that are still as in from to their
+// value in to, and saves it. It returns whether it changed anything and the
+// fields it left alone because they changed since.
+func (t *taskTx) restore(task *models.Task, from, to *journalTask, remap func(*int) *int) (bool, []string, error) {
+	var conflicts []string
+	wasDone := task.Done
+	changed := false
+	// A field the command did not change is skipped; one still at its
+	// from value is restored; one at neither value changed since.
+	field := func(name string, unchanged, atFrom, atTo bool, set func()) {
+		switch {
+		case unchanged:
+		case atFrom:
+			set()
+			changed = true
+		case !atTo:
+			conflicts = append(conflicts, name)
+		}
+	}
+
+	field("title", from.Title == to.Title, task.Title == from.Title, task.Title == to.Title, func() { task.Title = to.Title })
+	field("description", from.Description == to.Description, task.Description == from.Description, task.Description == to.Description, func() { task.Description = to.Description })
+	field("done", from.Done == to.Done, task.Done == from.Done, task.Done == to.Done, func() { task.Done = to.Done })
+
+	fromParent, toParent := remap(from.ParentID), remap(to.ParentID)
+	if !sameID(fromParent, toParent) {
+		switch {
+		case sameID(task.ParentID, fromParent):
+			// The old parent may be gone or now below the task.
+			err := t.checkParent(task.ID, toParent)
+			if err == nil {
+				task.ParentID = toParent
+				changed = true
+			} else if isOperationError(err) {
+				conflicts = append(conflicts, "parentId")
+			} else {
+				return false, nil, err
+			}
+		case !sameID(task.ParentID, toParent):
+			conflicts = append(conflicts, "parentId")
+		}
+	}
+
+	if !changed {
+		return false, conflicts, nil
+	}
+	if err := t.save(task); err != nil {
+		return false, nil, err
+	}
+	t.completed(wasDone, task)
+	return true, conflicts, nil
+}
+
+// changedFields lists the fields of task that differ from state.
+func changedFields(task *models.Task, state *journalTask, remap func(*int) *int) []string {
+	var fields []string
+	if task.Title != state.Title {
+		fields = append(fields, "title")
+	}
+	if task.Description != state.Description {
+		fields = append(fields, "description")
+	}
+	if task.Done != state.Done {
+		fields = append(fields, "done")
+	}
+	if !sameID(task.ParentID, remap(state.ParentID)) {
+		fields = append(fields, "parentId")
+	}
+	return fields
+}
+
+// remapJournal replaces task IDs throughout the user's journal.
+func (t *taskTx) remapJournal(ids map[int]int) error {
+	rows, err := t.tx.Query(`SELECT id, changes FROM task_journal WHERE user_id = ? FOR UPDATE`, t.userID)
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		id      int64
+		changes []journalChange
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		var data string
+		if err := rows.Scan(&e.id, &data); err != nil {
+			rows.Close()
+			return err
+		}
+		if err := json.Unmarshal([]byte(data), &e.changes); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	swap := func(id *int) bool {
+		if id == nil {
+			return false
+		}
+		n, ok := ids[*id]
+		if ok {
+			*id = n
+		}
+		return ok
+	}
+	for _, e := range entries {
+		changed := false
+		for i := range e.changes {
+			c := &e.changes[i]
+			changed = swap(&c.TaskID) || changed
+			for _, state := range []*journalTask{c.Before, c.After} {
+				if state != nil {
+					changed = swap(state.ParentID) || changed
+				}
+			}
+		}
+		if !changed {
+			continue
+		}
+		data, err := json.Marshal(e.changes)
+		if err != nil {
+			return err
+		}
+		if _, err := t.tx.Exec(`UPDATE task_journal SET changes = ? WHERE id = ?`, string(data), e.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"task-manager-server/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var journalRow = []string{"id", "command", "changes"}
+
+// expectJournal expects the next command to undo to be read; empty changes
+// mean there is none left.
+func expectJournal(mock sqlmock.Sqlmock, id int64, command, changes string) {
+	rows := sqlmock.NewRows(journalRow)
+	if changes != "" {
+		rows.AddRow(id, command, changes)
+	}
+	mock.ExpectQuery(q("FROM task_journal\n       WHERE user_id = ? AND undone = 0")).
+		WithArgs(1).
+		WillReturnRows(rows)
+}
+
+// expectSave expects task to be stored with a new change sequence.
+func expectSave(mock sqlmock.Sqlmock, seq int64, title string) {
+	mock.ExpectExec(q("INSERT INTO change_sequences")).WithArgs(1).WillReturnResult(sqlmock.NewResult(seq, 1))
+	mock.ExpectExec(q("UPDATE tasks")).
+		WithArgs(title, "", false, nil, nil, seq, sqlmock.AnyArg(), 3, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q("INSERT INTO audit_log")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(q("INSERT INTO task_revisions")).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectUndone(mock sqlmock.Sqlmock, id int64) {
+	mock.ExpectExec(q("UPDATE task_journal SET undone = ? WHERE id = ?")).
+		WithArgs(true, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func retitled(from, to string) string {
+	return `[{"taskId": 3, "before": {"title": "` + from + `", "description": "", "done": false, "parentId": null},
+	          "after": {"title": "` + to + `", "description": "", "done": false, "parentId": null}}]`
+}
+
+func TestUndoStepsInOneTransaction(t *testing.T) {
+	s, mock := newTestTaskService(t)
+	task := models.Task{ID: 3, UserID: 1, Version: 50, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	expectJournal(mock, 2, commandUpdate, retitled("b", "c"))
+	task.Title = "c"
+	expectTask(mock, task, true)
+	expectSave(mock, 51, "b")
+	expectUndone(mock, 2)
+	expectJournal(mock, 1, commandUpdate, retitled("a", "b"))
+	task.Title = "b"
+	expectTask(mock, task, true)
+	expectSave(mock, 52, "a")
+	expectUndone(mock, 1)
+	mock.ExpectCommit()
+
+	res, err := s.Undo(context.Background(), 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Commands, []string{"update", "update"}) || res.Command != "update" {
+		t.Errorf("commands = %v, %q; want two updates", res.Commands, res.Command)
+	}
+	if len(res.Tasks) != 1 || res.Tasks[0].Title != "a" || res.Tasks[0].Version != 52 {
+		t.Errorf("tasks = %+v, want task 3 back at its first title", res.Tasks)
+	}
+	if len(res.Conflicts) != 0 {
+		t.Errorf("conflicts = %+v", res.Conflicts)
+	}
+}
+
+func TestUndoStopsWhenTheJournalRunsOut(t *testing.T) {
+	s, mock := newTestTaskService(t)
+	task := models.Task{ID: 3, UserID: 1, Title: "b", Version: 50, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	expectJournal(mock, 1, commandUpdate, retitled("a", "b"))
+	expectTask(mock, task, true)
+	expectSave(mock, 51, "a")
+	expectUndone(mock, 1)
+	expectJournal(mock, 0, "", "")
+	mock.ExpectCommit()
+
+	res, err := s.Undo(context.Background(), 1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Commands) != 1 {
+		t.Errorf("commands = %v, want the one journaled", res.Commands)
+	}
+}
+
+func TestUndoWithEmptyJournal(t *testing.T) {
+	s, mock := newTestTaskService(t)
+
+	mock.ExpectBegin()
+	expectJournal(mock, 0, "", "")
+	mock.ExpectRollback()
+
+	if _, err := s.Undo(context.Background(), 1, 3); !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("err = %v, want ErrNothingToUndo", err)
+	}
+}
+
+// A failing step rolls back the steps before it.
+func TestUndoRollsBackEveryStep(t *testing.T) {
+	s, mock := newTestTaskService(t)
+	task := models.Task{ID: 3, UserID: 1, Title: "c", Version: 50, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	broken := errors.New("connection lost")
+
+	mock.ExpectBegin()
+	expectJournal(mock, 2, commandUpdate, retitled("b", "c"))
+	expectTask(mock, task, true)
+	expectSave(mock, 51, "b")
+	expectUndone(mock, 2)
+	mock.ExpectQuery(q("FROM task_journal")).WithArgs(1).WillReturnError(broken)
+	mock.ExpectRollback()
+
+	if _, err := s.Undo(context.Background(), 1, 2); !errors.Is(err, broken) {
+		t.Fatalf("err = %v, want %v", err, broken)
+	}
+}
+
+func TestUndoStepsAreBounded(t *testing.T) {
+	s, mock := newTestTaskService(t)
+	task := models.Task{ID: 3, UserID: 1, Version: 50, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+
+	mock.ExpectBegin()
+	for i := range maxUndoSteps {
+		id := int64(maxUndoSteps - i)
+		from, to := string(rune('a'+i+1)), string(rune('a'+i))
+		expectJournal(mock, id, commandUpdate, retitled(to, from))
+		task.Title = from
+		expectTask(mock, task, true)
+		expectSave(mock, int64(51+i), to)
+		expectUndone(mock, id)
+	}
+	mock.ExpectCommit()
+
+	res, err := s.Undo(context.Background(), 1, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Commands) != maxUndoSteps {
+		t.Errorf("went through %d commands, want %d", len(res.Commands), maxUndoSteps)
+	}
+}
@@ -0,0 +1,8 @@
+package services
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts a span for each service method called with a context, named
+// after the service and the method, e.g. "TaskService.CreateTask". The
+// statements the method runs with that context become its children.
+var tracer = otel.Tracer("task-manager-server/internal/services")
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"task-manager-server/internal/events"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+)
+
+// deliveryBatch is how many due deliveries one instance claims at a time.
+const deliveryBatch = 20
+
+// maxDeliveryError bounds the error kept for an attempt.
+const maxDeliveryError = 1024
+
+// Relay queues a delivery per matching webhook for the task events of
+// broker. It runs until the process exits.
+func (s *WebhookService) Relay(broker *events.Broker) {
+	for {
+		sub := broker.SubscribeAll()
+		for e := range sub.C {
+			if !slices.Contains(WebhookEvents, e.Type) {
+				continue
+			}
+			if err := s.Enqueue(e); err != nil {
+				slog.Error("Webhooks: queueing event", "event", e.Type, "eventId", e.ID, "err", err)
+			}
+		}
+		slog.Warn("Webhooks: relay fell behind the event broker, resubscribing")
+	}
+}
+
+// Enqueue queues e for every active webhook of its user subscribed to it.
+func (s *WebhookService) Enqueue(e events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = s.db.Exec(
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, attempts, next_attempt_at, error, created_at)
+       SELECT id, ?, ?, ?, ?, 0, ?, '', ?
+       FROM webhooks
+       WHERE user_id = ? AND active = 1 AND FIND_IN_SET(?, events) > 0`,
+		e.ID,
+		e.Type,
+		string(payload),
+		models.DeliveryPending,
+		now,
+		now,
+		e.UserID,
+		e.Type,
+	)
+	return err
+}
+
+// RunDeliveries sends the due deliveries every poll interval. It runs until
+// the process exits.
+func (s *WebhookService) RunDeliveries() {
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Keep going while full batches were due.
+		for {
+			n, err := s.DeliverDue()
+			if err != nil {
+				slog.Error("Webhooks: delivering", "err", err)
+			}
+			if err != nil || n < deliveryBatch {
+				break
+			}
+		}
+	}
+}
+
+// dueDelivery is a claimed delivery with what is needed to send it.
+type dueDelivery struct {
+	id        int
+	webhookID int
+	event     string
+	payload   []byte
+	attempts  int
+	url       string
+	secret    string
+}
+
+// DeliverDue claims the deliveries that are due and sends them. Claiming
+// pushes their next attempt past the time sending can take, so other
+// instances skip them, and a crash only delays them.
+func (s *WebhookService) DeliverDue() (int, error) {
+	due, err := s.claim()
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, err := s.send(d)
+			if err := s.record(d, status, err); err != nil {
+				slog.Error("Webhooks: recording delivery", "deliveryId", d.id, "err", err)
+			}
+		}()
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+func (s *WebhookService) claim() (due []dueDelivery, err error) {
+	err = s.uow.WithTx(context.Background(), func(tx *repository.Tx) error {
+		now := time.Now()
+		rows, err := tx.Query(
+			`SELECT d.id, d.webhook_id, d.event, d.payload, d.attempts, w.url, w.secret
+         FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+         WHERE d.status = ? AND d.next_attempt_at <= ? AND w.active = 1
+         ORDER BY d.next_attempt_at
+         LIMIT ?
+         FOR UPDATE OF d SKIP LOCKED`,
+			models.DeliveryPending,
+			now,
+			deliveryBatch,
+		)
+		if err != nil {
+			return err
+		}
+		due = nil
+		for rows.Next() {
+			var d dueDelivery
+			var payload string
+			if err := rows.Scan(&d.id, &d.webhookID, &d.event, &payload, &d.attempts, &d.url, &d.secret); err != nil {
+				rows.Close()
+				return err
+			}
+			d.payload = []byte(payload)
+			due = append(due, d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		lease := now.Add(3 * s.cfg.Timeout)
+		for _, d := range due {
+			if _, err := tx.Exec(`UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?`, lease, d.id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// send makes one attempt. It returns the response status, if any, and an
+// error unless the receiver answered with a 2xx.
+func (s *WebhookService) send(d dueDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GoTaskPro-Webhooks/1")
+	req.Header.Set("X-Webhook-ID", strconv.Itoa(d.id))
+	req.Header.Set("X-Webhook-Event", d.event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", SignWebhook(d.secret, timestamp, d.payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the X-Webhook-Signature of a delivery: the hex
+// HMAC-SHA256, keyed with the webhook secret, of the timestamp, a dot and
+// the body.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// record stores the outcome of an attempt. Failed attempts are retried
+// with exponential backoff until MaxAttempts, and count against the
+// webhook, which is disabled after DisableAfter failures in a row.
+func (s *WebhookService) record(d dueDelivery, status int, sendErr error) error {
+	return s.uow.WithTx(context.Background(), func(tx *repository.Tx) error {
+		return s.recordTx(tx, d, status, sendErr)
+	})
+}
+
+func (s *WebhookService) recordTx(tx *repository.Tx, d dueDelivery, status int, sendErr error) error {
+	now := time.Now()
+	attempts := d.attempts + 1
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+
+	if sendErr == nil {
+		if _, err := tx.Exec(
+			`UPDATE webhook_deliveries
+         SET status = ?, attempts = ?, next_attempt_at = NULL, response_status = ?, error = '', delivered_at = ?
+         WHERE id = ?`,
+			models.DeliverySucceeded,
+			attempts,
+			responseStatus,
+			now,
+			d.id,
+		); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE webhooks SET failures = 0 WHERE id = ?`, d.webhookID)
+		return err
+	}
+
+	deliveryStatus := models.DeliveryPending
+	var next *time.Time
+	if attempts >= s.cfg.MaxAttempts {
+		deliveryStatus = models.DeliveryFailed
+	} else {
+		at := now.Add(s.backoff(attempts))
+		next = &at
+	}
+	msg := sendErr.Error()
+	if len(msg) > maxDeliveryError {
+		msg = msg[:maxDeliveryError]
+	}
+	if _, err := tx.Exec(
+		`UPDATE webhook_deliveries
+       SET status = ?, attempts = ?, next_attempt_at = ?, response_status = ?, error = ?
+       WHERE id = ?`,
+		deliveryStatus,
+		attempts,
+		next,
+		responseStatus,
+		msg,
+		d.id,
+	); err != nil {
+		return err
+	}
+
+	var failures int
+	var active bool
+	if err := tx.QueryRow(
+		`SELECT failures, active FROM webhooks WHERE id = ? FOR UPDATE`,
+		d.webhookID,
+	).Scan(&failures, &active); err != nil {
+		return err
+	}
+	failures++
+	disable := active && s.cfg.DisableAfter > 0 && failures >= s.cfg.DisableAfter
+	var err error
+	if disable {
+		_, err = tx.Exec(`UPDATE webhooks SET failures = ?, active = 0, disabled_at = ? WHERE id = ?`, failures, now, d.webhookID)
+	} else {
+		_, err = tx.Exec(`UPDATE webhooks SET failures = ? WHERE id = ?`, failures, d.webhookID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if disable {
+		tx.AfterCommit(func() {
+			slog.Warn("Webhooks: disabled webhook after failed deliveries in a row", "webhookId", d.webhookID, "failures", failures)
+		})
+	}
+	return nil
+}
+
+// backoff is the wait before the attempt after the given number of failed
+// ones.
+func (s *WebhookService) backoff(failed int) time.Duration {
+	delay := s.cfg.BaseDelay
+	for i := 1; i < failed && delay < s.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.MaxDelay)
+}
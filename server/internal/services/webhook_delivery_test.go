@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// receiver is a webhook endpoint answering every request with status.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	rcv := &receiver{status: status}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rcv.mu.Lock()
+		rcv.requests = append(rcv.requests, receivedRequest{header: r.Header.Clone(), body: body})
+		status := rcv.status
+		rcv.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *receiver) received() []receivedRequest {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return slices.Clone(rcv.requests)
+}
+
+func testWebhookConfig() config.WebhookConfig {
+	return config.WebhookConfig{
+		MaxAttempts:  3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		DisableAfter: 5,
+		Timeout:      2 * time.Second,
+		PollInterval: time.Second,
+		// The receiver listens on loopback.
+		AllowPrivateNetworks: true,
+	}
+}
+
+func newTestWebhookService(t *testing.T, cfg config.WebhookConfig) (*WebhookService, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	uow := repository.NewUnitOfWork(db, config.TxConfig{MaxAttempts: 1})
+	return NewWebhookService(db, uow, cfg), mock
+}
+
+// around matches a time within a few seconds of want.
+type around time.Time
+
+func (a around) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && t.Sub(time.Time(a)).Abs() < 5*time.Second
+}
+
+func q(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+func expectClaim(mock sqlmock.Sqlmock, d dueDelivery) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(q("FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event", "payload", "attempts", "url", "secret"}).
+			AddRow(d.id, d.webhookID, d.event, string(d.payload), d.attempts, d.url, d.secret))
+	mock.ExpectExec(q("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?")).
+		WithArgs(sqlmock.AnyArg(), d.id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// expectFailure expects a failed attempt to be stored with status and next,
+// and the webhook to go from failures to failures+1.
+func expectFailure(mock sqlmock.Sqlmock, d dueDelivery, status string, next any, responseStatus any, failures int, disable bool) {
+	mock.ExpectBegin()
+	mock.ExpectExec(q("UPDATE webhook_deliveries\n       SET status = ?, attempts = ?, next_attempt_at = ?, response_status = ?, error = ?")).
+		WithArgs(status, d.attempts+1, next, responseStatus, sqlmock.AnyArg(), d.id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(q("SELECT failures, active FROM webhooks WHERE id = ? FOR UPDATE")).
+		WithArgs(d.webhookID).
+		WillReturnRows(sqlmock.NewRows([]string{"failures", "active"}).AddRow(failures, true))
+	if disable {
+		mock.ExpectExec(q("UPDATE webhooks SET failures = ?, active = 0, disabled_at = ? WHERE id = ?")).
+			WithArgs(failures+1, around(time.Now()), d.webhookID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	} else {
+		mock.ExpectExec(q("UPDATE webhooks SET failures = ? WHERE id = ?")).
+			WithArgs(failures+1, d.webhookID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func testDelivery(url string) dueDelivery {
+	return dueDelivery{
+		id:        11,
+		webhookID: 3,
+		event:     "task.created",
+		payload:   []byte(`{"type":"task.created","data":{"id":1}}`),
+		url:       url,
+		secret:    "whsec_test",
+	}
+}
+
+func TestSignatureVerifiesAgainstTimestampAndBody(t *testing.T) {
+	rcv := newReceiver(t, http.StatusNoContent)
+	s, mock := newTestWebhookService(t, testWebhookConfig())
+	d := testDelivery(rcv.URL)
+
+	expectClaim(mock, d)
+	mock.ExpectBegin()
+	mock.ExpectExec(q("UPDATE webhook_deliveries\n         SET status = ?")).
+		WithArgs(models.DeliverySucceeded, 1, http.StatusNoContent, around(time.Now()), d.id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q("UPDATE webhooks SET failures = 0 WHERE id = ?")).
+		WithArgs(d.webhookID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if n, err := s.DeliverDue(); err != nil || n != 1 {
+		t.Fatalf("DeliverDue() = %d, %v; want 1, nil", n, err)
+	}
+
+	reqs := rcv.received()
+	if len(reqs) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(reqs))
+	}
+	req := reqs[0]
+	if string(req.body) != string(d.payload) {
+		t.Errorf("body = %s, want %s", req.body, d.payload)
+	}
+	if got := req.header.Get("X-Webhook-Event"); got != d.event {
+		t.Errorf("X-Webhook-Event = %q, want %q", got, d.event)
+	}
+
+	// Verify as a receiver would, without SignWebhook.
+	timestamp := req.header.Get("X-Webhook-Timestamp")
+	mac := hmac.New(sha256.New, []byte(d.secret))
+	mac.Write([]byte(timestamp + "." + string(req.body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	got := req.header.Get("X-Webhook-Signature")
+	if !hmac.Equal([]byte(got), []byte(want)) {
+		t.Errorf("X-Webhook-Signature = %q, want %q", got, want)
+	}
+	if got != SignWebhook(d.secret, timestamp, req.body) {
+		t.Errorf("SignWebhook disagrees with the signature sent")
+	}
+	if SignWebhook(d.secret, timestamp, []byte(`{"tampered":true}`)) == got {
+		t.Errorf("signature does not cover the body")
+	}
+}
+
+func TestFailedAttemptIsRescheduledWithBackoff(t *testing.T) {
+	rcv := newReceiver(t, http.StatusInternalServerError)
+	s, mock := newTestWebhookService(t, testWebhookConfig())
+	d := testDelivery(rcv.URL)
+	d.attempts = 1
+
+	expectClaim(mock, d)
+	next := time.Now().Add(s.backoff(2))
+	expectFailure(mock, d, models.DeliveryPending, around(next), http.StatusInternalServerError, 0, false)
+
+	if _, err := s.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rcv.received()) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(rcv.received()))
+	}
+}
+
+func TestBackoffDoublesUpToMaxDelay(t *testing.T) {
+	s, _ := newTestWebhookService(t, testWebhookConfig())
+	for failed, want := range map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		3:  4 * time.Minute,
+		7:  time.Hour,
+		50: time.Hour,
+	} {
+		if got := s.backoff(failed); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", failed, got, want)
+		}
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	rcv := newReceiver(t, http.StatusBadGateway)
+	cfg := testWebhookConfig()
+	s, mock := newTestWebhookService(t, cfg)
+	d := testDelivery(rcv.URL)
+	d.attempts = cfg.MaxAttempts - 1
+
+	expectClaim(mock, d)
+	expectFailure(mock, d, models.DeliveryFailed, nil, http.StatusBadGateway, 1, false)
+
+	if _, err := s.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookDisabledAfterFailuresInARow(t *testing.T) {
+	rcv := newReceiver(t, http.StatusNotFound)
+	cfg := testWebhookConfig()
+	s, mock := newTestWebhookService(t, cfg)
+	d := testDelivery(rcv.URL)
+
+	expectClaim(mock, d)
+	expectFailure(mock, d, models.DeliveryPending, around(time.Now().Add(cfg.BaseDelay)), http.StatusNotFound, cfg.DisableAfter-1, true)
+
+	if _, err := s.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	s, mock := newTestWebhookService(t, testWebhookConfig())
+	d := testDelivery(rcv.URL)
+	d.attempts = 2
+
+	expectClaim(mock, d)
+	mock.ExpectBegin()
+	mock.ExpectExec(q("UPDATE webhook_deliveries\n         SET status = ?, attempts = ?, next_attempt_at = NULL")).
+		WithArgs(models.DeliverySucceeded, 3, http.StatusOK, around(time.Now()), d.id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q("UPDATE webhooks SET failures = 0 WHERE id = ?")).
+		WithArgs(d.webhookID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := s.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedeliverQueuesThePayloadAgain(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	s, mock := newTestWebhookService(t, testWebhookConfig())
+	const userID, webhookID, deliveryID = 7, 3, 11
+	payload := `{"type":"task.updated","data":{"id":1}}`
+	created := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery(q("FROM webhooks WHERE id = ? AND user_id = ?")).
+		WithArgs(webhookID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "events", "active", "failures", "disabled_at", "created_at"}).
+			AddRow(webhookID, rcv.URL, "task.updated", true, 0, nil, created))
+	mock.ExpectQuery(q("FROM webhook_deliveries WHERE id = ? AND webhook_id = ?")).
+		WithArgs(deliveryID, webhookID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event_id", "event", "payload", "status", "attempts",
+			"next_attempt_at", "response_status", "error", "created_at", "delivered_at"}).
+			AddRow(deliveryID, webhookID, "evt-1", "task.updated", payload, models.DeliveryFailed, 3,
+				nil, 500, "receiver answered 500", created, nil))
+	mock.ExpectExec(q("INSERT INTO webhook_deliveries")).
+		WithArgs(webhookID, "evt-1", "task.updated", payload, models.DeliveryPending, around(time.Now()), around(time.Now())).
+		WillReturnResult(sqlmock.NewResult(12, 1))
+
+	d, err := s.Redeliver(context.Background(), webhookID, deliveryID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ID != 12 || d.Status != models.DeliveryPending || d.Attempts != 0 || d.NextAttemptAt == nil {
+		t.Fatalf("Redeliver() = %+v, want a new pending delivery due now", d)
+	}
+	if string(d.Payload) != payload || d.EventID != "evt-1" {
+		t.Fatalf("Redeliver() payload = %s, event %s; want the original", d.Payload, d.EventID)
+	}
+
+	// The next run sends it like any other delivery.
+	due := dueDelivery{id: d.ID, webhookID: webhookID, event: d.Event, payload: d.Payload, url: rcv.URL, secret: "whsec_test"}
+	expectClaim(mock, due)
+	mock.ExpectBegin()
+	mock.ExpectExec(q("SET status = ?, attempts = ?, next_attempt_at = NULL")).
+		WithArgs(models.DeliverySucceeded, 1, http.StatusOK, around(time.Now()), d.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(q("UPDATE webhooks SET failures = 0 WHERE id = ?")).
+		WithArgs(webhookID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := s.DeliverDue(); err != nil {
+		t.Fatal(err)
+	}
+	reqs := rcv.received()
+	if len(reqs) != 1 || string(reqs[0].body) != payload {
+		t.Fatalf("receiver got %d requests, want the original payload once", len(reqs))
+	}
+}
+
+func TestPrivateAddressesAreRefused(t *testing.T) {
+	rcv := newReceiver(t, http.StatusOK)
+	cfg := testWebhookConfig()
+	cfg.AllowPrivateNetworks = false
+	s, _ := newTestWebhookService(t, cfg)
+
+	status, err := s.send(testDelivery(rcv.URL))
+	if err == nil || !strings.Contains(err.Error(), "private addresses") {
+		t.Fatalf("send() to %s = %d, %v; want the private address error", rcv.URL, status, err)
+	}
+	if n := len(rcv.received()); n != 0 {
+		t.Fatalf("receiver got %d requests, want none", n)
+	}
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"task-manager-server/internal/config"
+	"task-manager-server/internal/events"
+	"task-manager-server/internal/models"
+	"task-manager-server/internal/repository"
+	"task-manager-server/internal/validation"
+)
+
+// WebhookEvents are the events webhooks can subscribe to.
+var WebhookEvents = []string{events.TaskCreated, events.TaskUpdated, events.TaskCompleted, events.TaskDeleted}
+
+// maxDeliveryPage bounds the deliveries listed at once.
+const maxDeliveryPage = 100
+
+const webhookColumns = `id, url, events, active, failures, disabled_at, created_at`
+
+const deliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, next_attempt_at,
+       response_status, error, created_at, delivered_at`
+
+// WebhookService manages the webhooks of users and delivers their events.
+// Deliveries are queued in the database, so they survive restarts and are
+// shared by every instance.
+type WebhookService struct {
+	db     *sql.DB
+	uow    *repository.UnitOfWork
+	cfg    config.WebhookConfig
+	client *http.Client
+}
+
+func NewWebhookService(db *sql.DB, uow *repository.UnitOfWork, cfg config.WebhookConfig) *WebhookService {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		// Checked on the resolved address, so DNS names pointing inside
+		// the network are refused too.
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, _ := net.SplitHostPort(address)
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errors.New("webhooks may not target private addresses")
+			}
+			return nil
+		}
+	}
+
+	return &WebhookService{
+		db:  db,
+		uow: uow,
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			// A redirect is an answer other than a 2xx, so it fails the
+			// attempt instead of sending the payload elsewhere.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context, userID int) ([]models.Webhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.ListWebhooks")
+	defer span.End()
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE user_id = ? ORDER BY id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id, userID int) (*models.Webhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.GetWebhook")
+	defer span.End()
+
+	webhook, err := scanWebhook(s.db.QueryRowContext(
+		ctx,
+		`SELECT `+webhookColumns+` FROM webhooks WHERE id = ? AND user_id = ?`,
+		id,
+		userID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+// CreateWebhook registers a webhook with a new secret, which is only
+// returned here.
+func (s *WebhookService) CreateWebhook(ctx context.Context, req *models.CreateWebhookRequest, userID int) (*models.Webhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.CreateWebhook")
+	defer span.End()
+
+	if err := checkWebhook(req.URL, req.Events, true); err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &models.Webhook{
+		URL:       req.URL,
+		Events:    slices.Compact(slices.Sorted(slices.Values(req.Events))),
+		Secret:    secret,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO webhooks (user_id, url, events, secret, active, failures, created_at) VALUES (?, ?, ?, ?, 1, 0, ?)`,
+		userID,
+		webhook.URL,
+		strings.Join(webhook.Events, ","),
+		webhook.Secret,
+		webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	webhook.ID = int(id)
+	return webhook, nil
+}
+
+// UpdateWebhook changes the fields provided in req. Re-enabling a webhook
+// clears its failures; its pending deliveries resume.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id, userID int, req *models.UpdateWebhookRequest) (*models.Webhook, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.UpdateWebhook")
+	defer span.End()
+
+	webhook, err := s.GetWebhook(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		webhook.URL = *req.URL
+	}
+	if req.Events != nil {
+		webhook.Events = slices.Compact(slices.Sorted(slices.Values(req.Events)))
+	}
+	if err := checkWebhook(webhook.URL, webhook.Events, req.Events != nil); err != nil {
+		return nil, err
+	}
+	if req.Active != nil && *req.Active != webhook.Active {
+		webhook.Active = *req.Active
+		if webhook.Active {
+			webhook.Failures = 0
+			webhook.DisabledAt = nil
+		} else {
+			now := time.Now()
+			webhook.DisabledAt = &now
+		}
+	}
+
+	_, err = s.db.ExecContext(
+		ctx,
+		`UPDATE webhooks SET url = ?, events = ?, active = ?, failures = ?, disabled_at = ? WHERE id = ? AND user_id = ?`,
+		webhook.URL,
+		strings.Join(webhook.Events, ","),
+		webhook.Active,
+		webhook.Failures,
+		webhook.DisabledAt,
+		id,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook with its deliveries.
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id, userID int) error {
+	ctx, span := tracer.Start(ctx, "WebhookService.DeleteWebhook")
+	defer span.End()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Deliveries returns the most recent deliveries of a webhook, newest first.
+func (s *WebhookService) Deliveries(ctx context.Context, webhookID, userID, limit int) ([]models.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.Deliveries")
+	defer span.End()
+
+	if _, err := s.GetWebhook(ctx, webhookID, userID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxDeliveryPage {
+		limit = maxDeliveryPage
+	}
+
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`,
+		webhookID,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Redeliver queues the payload of a past delivery again, as a new delivery
+// that is due right away.
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, deliveryID, userID int) (*models.WebhookDelivery, error) {
+	ctx, span := tracer.Start(ctx, "WebhookService.Redeliver")
+	defer span.End()
+
+	if _, err := s.GetWebhook(ctx, webhookID, userID); err != nil {
+		return nil, err
+	}
+
+	d, err := scanDelivery(s.db.QueryRowContext(
+		ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = ? AND webhook_id = ?`,
+		deliveryID,
+		webhookID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	redelivery := &models.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventID:       d.EventID,
+		Event:         d.Event,
+		Payload:       d.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+	res, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, attempts, next_attempt_at, error, created_at)
+       VALUES (?, ?, ?, ?, ?, 0, ?, '', ?)`,
+		webhookID,
+		redelivery.EventID,
+		redelivery.Event,
+		string(redelivery.Payload),
+		redelivery.Status,
+		now,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	redelivery.ID = int(id)
+	return redelivery, nil
+}
+
+// checkWebhook validates the URL and events of a webhook. The validation
+// tags cannot express either.
+func checkWebhook(rawURL string, subscribed []string, checkEvents bool) error {
+	var errs validation.Errors
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, validation.FieldError{
+			Field:   "url",
+			Code:    validation.CodeInvalidValue,
+			Message: "url must be an absolute http or https URL",
+		})
+	}
+	if checkEvents {
+		if len(subscribed) == 0 {
+			errs = append(errs, validation.FieldError{
+				Field:   "events",
+				Code:    validation.CodeRequired,
+				Message: "events is required",
+			})
+		}
+		for _, e := range subscribed {
+			if !slices.Contains(WebhookEvents, e) {
+				errs = append(errs, validation.FieldError{
+					Field:   "events",
+					Code:    validation.CodeInvalidValue,
+					Message: "events must be some of " + strings.Join(WebhookEvents, ", "),
+				})
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+func scanWebhook(row interface{ Scan(dest ...any) error }) (*models.Webhook, error) {
+	var w models.Webhook
+	var subscribed string
+	var disabledAt sql.NullTime
+	if err := row.Scan(&w.ID, &w.URL, &subscribed, &w.Active, &w.Failures, &disabledAt, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+	w.Events = strings.Split(subscribed, ",")
+	if disabledAt.Valid {
+		w.DisabledAt = &disabledAt.Time
+	}
+	return &w, nil
+}
+
+func scanDelivery(row interface{ Scan(dest ...any) error }) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+	var nextAttemptAt, deliveredAt sql.NullTime
+	var responseStatus sql.NullInt64
+	if err := row.Scan(
+		&d.ID,
+		&d.WebhookID,
+		&d.EventID,
+		&d.Event,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&nextAttemptAt,
+		&responseStatus,
+		&d.Error,
+		&d.CreatedAt,
+		&deliveredAt,
+	); err != nil {
+		return nil, err
+	}
+	d.Payload = []byte(payload)
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		d.ResponseStatus = &status
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return &d, nil
+}
@@ -0,0 +1,148 @@
+// Package textdiff compares texts line by line.
+package textdiff
+
+import (
+	"slices"
+	"strings"
+)
+
+// Op says what happened to a line.
+type Op string
+
+// Operations of a line diff.
+const (
+	Equal  Op = "equal"
+	Delete Op = "delete"
+	Insert Op = "insert"
+)
+
+// Line is one line of a diff: kept, deleted from the old text or inserted
+// from the new one.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// maxEdits bounds the number of deleted and inserted lines the comparison
+// looks for. The search keeps about maxEdits² integers; texts that differ in
+// more lines are shown as replaced wholesale.
+const maxEdits = 500
+
+// Lines returns the edit script turning a into b: every line of both, in
+// order, marked as kept, deleted from a or inserted from b. It is a shortest
+// edit script, found with Myers' algorithm, so unchanged lines stay matched.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// Lines shared at both ends need no comparing.
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	var diff []Line
+	for _, line := range x[:prefix] {
+		diff = append(diff, Line{Op: Equal, Text: line})
+	}
+	diff = append(diff, middle(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])...)
+	for _, line := range x[len(x)-suffix:] {
+		diff = append(diff, Line{Op: Equal, Text: line})
+	}
+	return diff
+}
+
+// middle compares x and y with Myers' O(ND) algorithm ("An O(ND) Difference
+// Algorithm and Its Variations", 1986).
+func middle(x, y []string) []Line {
+	n, m := len(x), len(y)
+	limit := min(n+m, maxEdits)
+
+	// v[offset+k] is the furthest x reached on diagonal k = x - y. trace[d]
+	// keeps diagonals -d..d of v after d edits, for walking back.
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+				return backtrack(x, y, trace)
+			}
+		}
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+	}
+
+	diff := make([]Line, 0, n+m)
+	for _, line := range x {
+		diff = append(diff, Line{Op: Delete, Text: line})
+	}
+	for _, line := range y {
+		diff = append(diff, Line{Op: Insert, Text: line})
+	}
+	return diff
+}
+
+// backtrack walks the paths recorded in trace back from the end of both
+// texts and returns the edit script in order.
+func backtrack(x, y []string, trace [][]int) []Line {
+	var diff []Line
+	i, j := len(x), len(y)
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		at := func(k int) int { return prev[k+d-1] }
+
+		k := i - j
+		var pk int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		pi := at(pk)
+		pj := pi - pk
+
+		for i > pi && j > pj {
+			i--
+			j--
+			diff = append(diff, Line{Op: Equal, Text: x[i]})
+		}
+		if i == pi {
+			j--
+			diff = append(diff, Line{Op: Insert, Text: y[j]})
+		} else {
+			i--
+			diff = append(diff, Line{Op: Delete, Text: x[i]})
+		}
+	}
+	for i > 0 {
+		i--
+		diff = append(diff, Line{Op: Equal, Text: x[i]})
+	}
+
+	slices.Reverse(diff)
+	return diff
+}
+
+// split returns the lines of s; an empty text has none.
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+}
@@ -0,0 +1,122 @@
+package textdiff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// sides rebuilds the old and the new text from a diff.
+func sides(diff []Line) (a, b []string) {
+	for _, l := range diff {
+		if l.Op != Insert {
+			a = append(a, l.Text)
+		}
+		if l.Op != Delete {
+			b = append(b, l.Text)
+		}
+	}
+	return a, b
+}
+
+func edits(diff []Line) int {
+	n := 0
+	for _, l := range diff {
+		if l.Op != Equal {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Line
+	}{
+		{"both empty", "", "", nil},
+		{"added text", "", "a\nb", []Line{{Insert, "a"}, {Insert, "b"}}},
+		{"removed text", "a\nb\n", "", []Line{{Delete, "a"}, {Delete, "b"}}},
+		{"unchanged", "a\nb", "a\r\nb\n", []Line{{Equal, "a"}, {Equal, "b"}}},
+		{
+			"inserted line",
+			"Milk\nBread", "Milk\nEggs\nBread",
+			[]Line{{Equal, "Milk"}, {Insert, "Eggs"}, {Equal, "Bread"}},
+		},
+		{
+			"replaced line",
+			"a\nb\nc", "a\nx\nc",
+			[]Line{{Equal, "a"}, {Delete, "b"}, {Insert, "x"}, {Equal, "c"}},
+		},
+		{
+			"moved line",
+			"a\nb\nc\nd", "b\nc\nd\na",
+			[]Line{{Delete, "a"}, {Equal, "b"}, {Equal, "c"}, {Equal, "d"}, {Insert, "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Lines(tt.a, tt.b); !slices.Equal(got, tt.want) {
+				t.Errorf("Lines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The example of Myers' paper: ABCABBA to CBABAC takes five edits.
+func TestLinesIsShortest(t *testing.T) {
+	a := strings.Join(strings.Split("ABCABBA", ""), "\n")
+	b := strings.Join(strings.Split("CBABAC", ""), "\n")
+	diff := Lines(a, b)
+
+	x, y := sides(diff)
+	if strings.Join(x, "\n") != a || strings.Join(y, "\n") != b {
+		t.Fatalf("diff %v does not rebuild both texts", diff)
+	}
+	if n := edits(diff); n != 5 {
+		t.Errorf("%d edits, want 5: %v", n, diff)
+	}
+}
+
+func TestLinesRebuildsBothTexts(t *testing.T) {
+	var a, b []string
+	for i := range 300 {
+		if i%3 != 0 {
+			a = append(a, fmt.Sprint("line ", i))
+		}
+		if i%5 != 0 {
+			b = append(b, fmt.Sprint("line ", i))
+		}
+	}
+	diff := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+
+	x, y := sides(diff)
+	if !slices.Equal(x, a) || !slices.Equal(y, b) {
+		t.Fatal("diff does not rebuild both texts")
+	}
+	// Lines divisible by 3 but not 5 are inserted, by 5 but not 3 deleted.
+	if n, want := edits(diff), 80+40; n != want {
+		t.Errorf("%d edits, want %d", n, want)
+	}
+}
+
+func TestLinesReplacesWholesalePastTheBound(t *testing.T) {
+	var a, b []string
+	for i := range maxEdits {
+		a = append(a, fmt.Sprint("old ", i))
+		b = append(b, fmt.Sprint("new ", i))
+	}
+	a = append(a, "shared")
+	b = append([]string{"shared"}, b...)
+
+	diff := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	if len(diff) != len(a)+len(b) || edits(diff) != len(diff) {
+		t.Fatalf("got %d lines with %d edits, want every line replaced", len(diff), edits(diff))
+	}
+	x, y := sides(diff)
+	if !slices.Equal(x, a) || !slices.Equal(y, b) {
+		t.Fatal("diff does not rebuild both texts")
+	}
+}
@@ -0,0 +1,223 @@
+// Package validation checks request models against rules declared in
+// `validate` struct tags, for example:
+//
+//	Email string `json:"email" validate:"required,email,max=100"`
+//
+// Supported rules:
+//
+//	required    the value must be present and not blank
+//	notblank    when present, the value must not be blank
+//	email       a bare email address such as john@example.com
+//	min=N       at least N characters
+//	max=N       at most N characters (VARCHAR(N) columns)
+//	maxbytes=N  at most N bytes once UTF-8 encoded (TEXT columns)
+//	len=N       exactly N characters
+//	numeric     digits only
+//	password    at least 8 characters with a letter and a digit
+//	ip          an IPv4 or IPv6 address
+//	oneof=A B   one of the space separated values
+//
+// Only string and *string fields are checked. A nil pointer or an empty
+// string skips every rule but required, so optional fields stay optional.
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Machine readable error codes.
+const (
+	CodeRequired     = "required"
+	CodeBlank        = "blank"
+	CodeInvalidEmail = "invalid_email"
+	CodeTooShort     = "too_short"
+	CodeTooLong      = "too_long"
+	CodeInvalidLen   = "invalid_length"
+	CodeNotNumeric   = "not_numeric"
+	CodeWeakPassword = "weak_password"
+	CodeInvalidIP    = "invalid_ip"
+	CodeInvalidValue = "invalid_value"
+)
+
+const minPasswordLength = 8
+
+// FieldError describes one invalid field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Errors lists every invalid field of a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks v, a pointer to a struct, and returns Errors when at least
+// one field breaks its rules.
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs Errors
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+
+		value, present := stringValue(rv.Field(i))
+		if fe := checkField(fieldName(field), value, present, strings.Split(tag, ",")); fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkField applies rules in order and stops at the first failure, so each
+// field reports a single error.
+func checkField(name, value string, present bool, rules []string) *FieldError {
+	blank := !present || strings.TrimSpace(value) == ""
+	for _, rule := range rules {
+		rule, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		if rule == "required" {
+			if blank {
+				return &FieldError{name, CodeRequired, fmt.Sprintf("%s is required", name)}
+			}
+			continue
+		}
+		if rule == "notblank" {
+			if present && blank {
+				return &FieldError{name, CodeBlank, fmt.Sprintf("%s must not be blank", name)}
+			}
+			continue
+		}
+		if blank {
+			continue
+		}
+
+		if fe := checkRule(name, value, rule, arg); fe != nil {
+			return fe
+		}
+	}
+	return nil
+}
+
+func checkRule(name, value, rule, arg string) *FieldError {
+	n, _ := strconv.Atoi(arg)
+
+	switch rule {
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		if err != nil || addr.Address != value {
+			return &FieldError{name, CodeInvalidEmail, fmt.Sprintf("%s must be a valid email address", name)}
+		}
+	case "min":
+		if utf8.RuneCountInString(value) < n {
+			return &FieldError{name, CodeTooShort, fmt.Sprintf("%s must be at least %d characters", name, n)}
+		}
+	case "max":
+		if utf8.RuneCountInString(value) > n {
+			return &FieldError{name, CodeTooLong, fmt.Sprintf("%s must be at most %d characters", name, n)}
+		}
+	case "maxbytes":
+		if len(value) > n {
+			return &FieldError{name, CodeTooLong, fmt.Sprintf("%s must be at most %d bytes", name, n)}
+		}
+	case "len":
+		if utf8.RuneCountInString(value) != n {
+			return &FieldError{name, CodeInvalidLen, fmt.Sprintf("%s must be exactly %d characters", name, n)}
+		}
+	case "numeric":
+		for _, r := range value {
+			if r < '0' || r > '9' {
+				return &FieldError{name, CodeNotNumeric, fmt.Sprintf("%s must only contain digits", name)}
+			}
+		}
+	case "password":
+		if !strongPassword(value) {
+			return &FieldError{name, CodeWeakPassword, fmt.Sprintf(
+				"%s must be at least %d characters and contain a letter and a digit", name, minPasswordLength)}
+		}
+	case "ip":
+		if net.ParseIP(value) == nil {
+			return &FieldError{name, CodeInvalidIP, fmt.Sprintf("%s must be a valid IP address", name)}
+		}
+	case "oneof":
+		if !slices.Contains(strings.Fields(arg), value) {
+			return &FieldError{name, CodeInvalidValue, fmt.Sprintf("%s must be one of: %s", name, strings.Join(strings.Fields(arg), ", "))}
+		}
+	default:
+		panic(fmt.Sprintf("validation: unknown rule %q on field %s", rule, name))
+	}
+	return nil
+}
+
+func strongPassword(p string) bool {
+	if utf8.RuneCountInString(p) < minPasswordLength {
+		return false
+	}
+	var letter, digit bool
+	for _, r := range p {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return letter && digit
+}
+
+// stringValue reads a string or *string field. present is false for nil
+// pointers.
+func stringValue(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.String {
+		return "", false
+	}
+	return v.String(), true
+}
+
+// fieldName reports fields by their JSON name, which is what clients know.
+func fieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type signup struct {
+	Email    string  `json:"email" validate:"required,email,max=100"`
+	Password string  `json:"password" validate:"required,password"`
+	Name     *string `json:"name,omitempty" validate:"notblank,max=5"`
+	Code     string  `json:"code" validate:"len=6,numeric"`
+	IP       string  `json:"ip" validate:"ip"`
+	Mode     string  `json:"mode" validate:"oneof=atomic best_effort"`
+	Notes    string  `json:"notes" validate:"maxbytes=4"`
+	Untagged string
+	internal string `validate:"required"`
+}
+
+func valid() signup {
+	return signup{Email: "john@example.com", Password: "s3cretpass"}
+}
+
+func ptr(s string) *string { return &s }
+
+func TestValidateAcceptsValidRequest(t *testing.T) {
+	s := valid()
+	s.Name = ptr("Ada")
+	s.Code = "123456"
+	s.IP = "2001:db8::1"
+	s.Mode = "best_effort"
+	s.Notes = "abcd"
+	if err := Validate(&s); err != nil {
+		t.Fatalf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		change    func(*signup)
+		wantField string
+		wantCode  string
+	}{
+		{"missing", func(s *signup) { s.Email = "" }, "email", CodeRequired},
+		{"whitespace is missing", func(s *signup) { s.Email = "  " }, "email", CodeRequired},
+		{"email with a name", func(s *signup) { s.Email = "John <john@example.com>" }, "email", CodeInvalidEmail},
+		{"too long", func(s *signup) { s.Email = strings.Repeat("a", 90) + "@example.com" }, "email", CodeTooLong},
+		{"password without digit", func(s *signup) { s.Password = "onlyletters" }, "password", CodeWeakPassword},
+		{"short password", func(s *signup) { s.Password = "ab1" }, "password", CodeWeakPassword},
+		{"blank pointer", func(s *signup) { s.Name = ptr(" ") }, "name", CodeBlank},
+		{"characters, not bytes", func(s *signup) { s.Name = ptr("ééééé!") }, "name", CodeTooLong},
+		{"wrong length", func(s *signup) { s.Code = "12345" }, "code", CodeInvalidLen},
+		{"not numeric", func(s *signup) { s.Code = "12345a" }, "code", CodeNotNumeric},
+		{"not an ip", func(s *signup) { s.IP = "10.0.0.256" }, "ip", CodeInvalidIP},
+		{"not one of", func(s *signup) { s.Mode = "atomically" }, "mode", CodeInvalidValue},
+		{"bytes, not characters", func(s *signup) { s.Notes = "ééé" }, "notes", CodeTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.change(&s)
+
+			var errs Errors
+			if !errors.As(Validate(&s), &errs) || len(errs) != 1 {
+				t.Fatalf("Validate() = %v, want one field error", errs)
+			}
+			if errs[0].Field != tt.wantField || errs[0].Code != tt.wantCode {
+				t.Errorf("got %s/%s, want %s/%s", errs[0].Field, errs[0].Code, tt.wantField, tt.wantCode)
+			}
+			if errs[0].Message == "" {
+				t.Error("the error has no message")
+			}
+		})
+	}
+}
+
+// Every invalid field is reported, each with its first failing rule.
+func TestValidateReportsEveryField(t *testing.T) {
+	var errs Errors
+	if !errors.As(Validate(&signup{Code: "12a"}), &errs) {
+		t.Fatal("Validate() accepted an empty request")
+	}
+	var got []string
+	for _, fe := range errs {
+		got = append(got, fe.Field+"/"+fe.Code)
+	}
+	want := "email/required password/required code/invalid_length"
+	if strings.Join(got, " ") != want {
+		t.Errorf("errors = %v, want %s", got, want)
+	}
+}
+
+func TestValidateSkipsAbsentOptionalFields(t *testing.T) {
+	s := valid()
+	s.Name = nil
+	if err := Validate(&s); err != nil {
+		t.Fatalf("Validate() = %v, want nil for a nil optional field", err)
+	}
+	if err := Validate((*signup)(nil)); err != nil {
+		t.Fatalf("Validate(nil) = %v, want nil", err)
+	}
+}
+
+func TestValidatePanicsOnUnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("an unknown rule did not panic")
+		}
+	}()
+	Validate(&struct {
+		A string `validate:"uppercase"`
+	}{A: "x"})
+}